@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"sentinel/modules/config"
+	"sentinel/modules/database"
+	"sentinel/modules/importer"
+	"sentinel/modules/registry"
+	"sentinel/modules/reporting"
+	"sentinel/modules/utils"
+
+	"github.com/fatih/color"
+)
+
+// Exit codes returned by the non-interactive CLI.
+const (
+	exitOK       = 0 // command completed successfully
+	exitFailure  = 1 // a module or command failed
+	exitUsage    = 2 // invalid invocation
+	exitFindings = 3 // findings at or above the --fail-on threshold exist
+)
+
+// cliResult is the machine-readable summary printed to stdout after every CLI command.
+type cliResult struct {
+	Command   string         `json:"command"`
+	Args      []string       `json:"args,omitempty"`
+	Workspace string         `json:"workspace,omitempty"`
+	Status    string         `json:"status"` // "ok", "failed" or "findings"
+	Error     string         `json:"error,omitempty"`
+	Duration  float64        `json:"duration_seconds"`
+	Findings  map[string]int `json:"findings,omitempty"`
+	Data      any            `json:"data,omitempty"`
+}
+
+// stringList is a repeatable string flag (e.g. --target a.com --target b.com).
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s = append(*s, v)
+		}
+	}
+	return nil
+}
+
+// repeatedFlag is a repeatable string flag whose values may contain commas.
+type repeatedFlag []string
+
+func (r *repeatedFlag) String() string { return strings.Join(*r, " ") }
+
+func (r *repeatedFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// cliFlags holds the options shared by every CLI subcommand.
+type cliFlags struct {
+	workspace      string
+	targets        stringList
+	failOn         string
+	format         string
+	resume         bool
+	status         bool
+	once           bool
+	output         string
+	filters        repeatedFlag
+	includeRemoved bool
+	includeFP      bool
+	source         string
+	reveal         bool
+	force          bool
+	columns        stringList
+	sort           string
+	limit          int
+	page           int
+	note           string
+	assignee       string
+}
+
+func newFlagSet(name string, opts *cliFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.StringVar(&opts.workspace, "workspace", "", "Workspace to use instead of the one in config.yaml")
+	fs.Var(&opts.targets, "target", "Target to use for this run instead of the stored ones (repeatable or comma separated)")
+	fs.StringVar(&opts.failOn, "fail-on", "", "Exit with code 3 if findings at or above this severity exist (info|low|medium|high|critical)")
+	fs.StringVar(&opts.format, "format", "", "Report format override (md|json|html|sarif)")
+	fs.BoolVar(&opts.resume, "resume", false, "Resume the latest unfinished run of the module")
+	fs.BoolVar(&opts.once, "once", false, "Run every monitor schedule once and exit (monitor)")
+	fs.BoolVar(&opts.status, "status", false, "Only show the migration status (db migrate)")
+	fs.StringVar(&opts.output, "output", "", "File to export to instead of stdout (export)")
+	fs.Var(&opts.filters, "filter", "Only export rows matching an expression such as status=200 or tech~nginx (export, show, repeatable)")
+	fs.BoolVar(&opts.includeRemoved, "include-removed", false, "Also include assets that are no longer present (export, show)")
+	fs.BoolVar(&opts.includeFP, "include-false-positives", false, "Also include findings triaged as false positives (export, show)")
+	fs.StringVar(&opts.source, "source", "", "Label recorded as the source of imported assets (import)")
+	fs.BoolVar(&opts.reveal, "reveal", false, "Decrypt and show the value of a secret (show secret)")
+	fs.Var(&opts.columns, "columns", "Columns to show, in order (show, comma separated)")
+	fs.StringVar(&opts.sort, "sort", "", "Column to sort by, descending with a leading - (show)")
+	fs.IntVar(&opts.limit, "limit", defaultPageSize, "Rows per page, 0 for all (show)")
+	fs.IntVar(&opts.page, "page", 1, "Page to show (show)")
+	fs.BoolVar(&opts.force, "force", false, "Confirm the deletion of a workspace (workspace delete)")
+	fs.StringVar(&opts.note, "note", "", "Note to add to the triaged findings (triage)")
+	fs.StringVar(&opts.assignee, "assignee", "", "Assign the triaged findings, or unassign them with - (triage)")
+	return fs
+}
+
+// parseInterspersed parses flags that may appear before, between or after positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printCLIUsage() {
+	fmt.Fprintln(os.Stderr, `Usage: sentinel [command] [arguments] [flags]
+
+Run without arguments to start the interactive shell.
+
+Commands:
+  run <module>               Run a module (recon, crawl, secrets, jsextract, params, fuzz, scan, visual, exploit, report, all)
+  report                     Generate the findings report (same as 'run report')
+  add <target|exclude> <v>   Add a value to config.yaml
+  remove <target|exclude> <v> Remove a value from config.yaml
+  show                       Include the current configuration in the JSON summary
+  show <table> [filters]     List subdomains, ips, ports, urls, vulns, secrets, params or
+                             sourcemaps as a table with per-target counts
+                             (e.g. show urls status=200 tech~nginx --sort -last_seen)
+  show secret [id] [--reveal] List the stored secrets redacted, or decrypt one with --reveal
+  triage [<vuln|secret> <id>... [status]]
+                             Count findings per status, or show or set the status of
+                             findings: new, confirmed, false-positive, accepted-risk, fixed
+  workspace <action> [args]  Manage workspaces: list (with asset counts), create <name>,
+                             switch <name>, rename <old> <new>, delete <name> --force,
+                             archive <name> (packs it into archives/ and removes it)
+  monitor [--once]           Run the schedules in config.yaml until interrupted, or each once
+  notify test [sink]         Send a test notification to every sink, or only the named one
+  diff [from] [to]           List assets added or removed between two runs (IDs) or dates;
+                             defaults to the last two completed runs
+  db migrate [--status]      Apply pending schema migrations, or only list them
+  doctor                     Check the installed tools, the configuration and which tools
+                             can send their traffic through the configured proxy
+  export sarif [--output f]  Export vulnerabilities and secrets as SARIF 2.1.0
+  export <table> [flags]     Export subdomains, ips, ports, urls, vulns, secrets, params or
+                             sourcemaps as csv, jsonl or txt (e.g. export urls --filter status=200)
+  import <format> <file>     Import nmap XML, Burp XML, HAR, host/URL lists or nuclei JSONL
+                             (formats: nmap, burp, har, list, nuclei)
+  help                       Show this help
+
+Flags:
+  --workspace <name>         Workspace to use instead of the one in config.yaml
+  --target <host>            Target to use for this run only (repeatable)
+  --fail-on <severity>       Exit with code 3 when findings at or above this severity exist
+  --format <fmt>             Report format (md|json|html|sarif) or export format (csv|jsonl|txt)
+  --output <file>            Write exported data to a file instead of stdout
+  --filter <expr>            Export only matching rows: <column><op><value> with op one of
+                             = != ~ !~ > < >= <= (repeatable, e.g. --filter tech~nginx)
+  --include-removed          Also export assets that are no longer present
+  --include-false-positives  Also export or show findings triaged as false positives
+  --source <label>           Label imported assets as import:<label> (default: the format)
+  --columns <a,b>            Columns 'show' prints, in order
+  --sort [-]<column>         Sort 'show' by a column, descending with a leading -
+  --limit <n>                Rows per page of 'show' (default 50, 0 for all)
+  --page <n>                 Page of 'show' to print
+  --force                    Confirm 'workspace delete'
+  --note <text>              Add a dated note to the findings given to 'triage'
+  --assignee <name|->        Assign the findings given to 'triage', or unassign them with -
+  --reveal                   Decrypt the secret given to 'show secret' (needs the secrets key)
+  --resume                   Resume the latest unfinished run, skipping completed stages
+
+Exit codes: 0 ok, 1 module failure, 2 usage error, 3 findings above the --fail-on threshold.
+A JSON summary of every command is written to stdout; logs go to stderr. When 'export'
+writes its data to stdout, the summary is written to stderr instead.`)
+}
+
+// isRunOption reports whether name is a module accepted by 'run'.
+func isRunOption(name string) bool {
+	if name == "all" {
+		return true
+	}
+	_, ok := registry.Get(name)
+	return ok
+}
+
+// runCLI executes a single non-interactive command and returns the process exit code.
+func runCLI(args []string) int {
+	// Keep stdout clean for the JSON summary by sending all human-oriented output to stderr.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = os.Stderr
+	dataOut = stdout
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printCLIUsage()
+		return exitOK
+	}
+
+	var opts cliFlags
+	fs := newFlagSet("sentinel", &opts)
+	fs.Usage = printCLIUsage
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) == 0 {
+		printCLIUsage()
+		return exitUsage
+	}
+
+	command := positional[0]
+	result := cliResult{Command: command, Args: positional[1:]}
+	start := time.Now()
+	code := executeCLI(command, positional[1:], &opts, &result)
+	result.Duration = time.Since(start).Seconds()
+	if appConfig != nil {
+		result.Workspace = appConfig.Workspace
+	}
+	switch code {
+	case exitOK:
+		result.Status = "ok"
+	case exitFindings:
+		result.Status = "findings"
+	default:
+		result.Status = "failed"
+	}
+
+	summaryOut := stdout
+	if command == "export" && (opts.output == "" || opts.output == "-") {
+		summaryOut = os.Stderr
+	}
+	enc := json.NewEncoder(summaryOut)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "could not encode result: %v\n", err)
+	}
+	return code
+}
+
+func executeCLI(command string, args []string, opts *cliFlags, result *cliResult) int {
+	fail := func(code int, err error) int {
+		result.Error = err.Error()
+		color.Red("%v", err)
+		return code
+	}
+
+	if opts.failOn != "" && utils.SeverityRank(opts.failOn) < 0 {
+		return fail(exitUsage, fmt.Errorf("invalid --fail-on severity '%s'", opts.failOn))
+	}
+
+	switch command {
+	case "run", "report":
+		if command == "report" {
+			args = append([]string{"report"}, args...)
+		}
+		if len(args) != 1 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel run <module> [flags]"))
+		}
+		if !isRunOption(args[0]) {
+			return fail(exitUsage, fmt.Errorf("unknown module: %s", args[0]))
+		}
+		if opts.format != "" && !reporting.IsFormat(opts.format) {
+			return fail(exitUsage, fmt.Errorf("invalid --format '%s' (expected md, json, html or sarif)", opts.format))
+		}
+	case "add", "remove":
+		if len(args) < 2 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel %s <target|exclude> <value>", command))
+		}
+	case "db":
+		if len(args) != 1 || args[0] != "migrate" {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel db migrate [--status]"))
+		}
+	case "notify":
+		if len(args) == 0 || args[0] != "test" || len(args) > 2 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel notify test [sink]"))
+		}
+	case "monitor":
+		if len(args) != 0 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel monitor [--once]"))
+		}
+	case "export":
+		if len(args) != 1 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel "+exportUsage))
+		}
+	case "import":
+		if len(args) != 2 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel "+importUsage))
+		}
+	case "diff":
+		if len(args) > 2 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel diff [<run-id|date> [<run-id|date>]]"))
+		}
+	case "workspace":
+		if err := checkWorkspaceArgs(args); err != nil {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel "+workspaceUsage))
+		}
+	case "triage":
+		if _, err := parseTriageArgs(args, opts.note, opts.assignee); err != nil {
+			return fail(exitUsage, err)
+		}
+	case "doctor":
+		if len(args) != 0 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel doctor"))
+		}
+	case "show":
+		if len(args) > 0 && args[0] == "secret" && len(args) > 2 {
+			return fail(exitUsage, fmt.Errorf("usage: sentinel "+showSecretUsage))
+		}
+		if len(args) > 0 && args[0] != "secret" && database.ViewColumns(args[0]) == nil {
+			return fail(exitUsage, fmt.Errorf("unknown table '%s' (expected secret or one of: %s)", args[0], strings.Join(database.ViewNames(), ", ")))
+		}
+	default:
+		printCLIUsage()
+		return fail(exitUsage, fmt.Errorf("unknown command: %s", command))
+	}
+
+	if err := loadConfig(); err != nil {
+		return fail(exitFailure, err)
+	}
+	if opts.workspace != "" {
+		appConfig.Workspace = opts.workspace
+	}
+	if command == "workspace" {
+		// Workspace commands open the databases they need themselves.
+		data, err := runWorkspace(args, opts.force)
+		if db != nil {
+			db.Close()
+		}
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		result.Data = data
+		return exitOK
+	}
+	if opts.format != "" && command != "export" {
+		appConfig.Reporting.Format = opts.format
+	}
+
+	if command == "show" && len(args) == 0 {
+		result.Data = appConfig
+		return exitOK
+	}
+	if command == "doctor" {
+		report, err := runDoctor()
+		result.Data = report
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		return exitOK
+	}
+
+	var err error
+	db, err = database.InitDB(appConfig)
+	if err != nil {
+		return fail(exitFailure, fmt.Errorf("could not initialize database: %w", err))
+	}
+	defer db.Close()
+
+	switch command {
+	case "show":
+		var data any
+		if args[0] == "secret" {
+			data, err = runShowSecret(args[1:], opts.reveal)
+		} else {
+			data, err = runShowTable(args[0], args[1:], opts)
+		}
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		result.Data = data
+		return exitOK
+	case "triage":
+		data, err := runTriage(args, opts)
+		result.Data = data
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		return exitOK
+	case "db":
+		status, err := runMigrate(opts.status)
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		result.Data = status
+		return exitOK
+	case "notify":
+		reached, err := testNotify(context.Background(), strings.Join(args[1:], ""))
+		result.Data = reached
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		return exitOK
+	case "monitor":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		results, err := runMonitor(ctx, opts.once)
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		result.Data = results
+		failed := 0
+		for _, r := range results {
+			if r.Status != "ok" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fail(exitFailure, fmt.Errorf("%d scheduled run(s) failed", failed))
+		}
+		return exitOK
+	case "export":
+		exported, err := runExport(args[0], opts)
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		result.Data = exported
+		return exitOK
+	case "import":
+		imported, err := importer.Import(appConfig, db, args[0], args[1], opts.source)
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		result.Data = imported
+		return exitOK
+	case "diff":
+		diff, err := runDiff(args)
+		if err != nil {
+			return fail(exitFailure, err)
+		}
+		result.Data = diff
+		return exitOK
+	case "add", "remove":
+		if command == "add" {
+			err = addValue(args[0], strings.Join(args[1:], " "))
+		} else {
+			err = removeValue(args[0], strings.Join(args[1:], " "))
+		}
+		if err != nil {
+			return fail(exitUsage, err)
+		}
+		// Unlike the shell, there is no 'exit' to persist changes, so save straight away.
+		// A --workspace override only applies to this invocation, so config.yaml is left alone.
+		if opts.workspace == "" {
+			if err := config.SaveConfig(appConfig); err != nil {
+				return fail(exitFailure, fmt.Errorf("could not save config: %w", err))
+			}
+		}
+		return exitOK
+	}
+
+	// command is "run" from here on. --target only applies to this run, so
+	// the targets are neither stored nor saved to config.yaml.
+	for _, value := range opts.targets {
+		target, err := parseTarget(value)
+		if err != nil {
+			return fail(exitUsage, err)
+		}
+		runTargets = append(removeStringFromSlice(runTargets, target), target)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := runModule(ctx, args[0], opts.resume); err != nil {
+		return fail(exitFailure, err)
+	}
+
+	counts, err := database.GetVulnerabilityCounts(db)
+	if err != nil {
+		return fail(exitFailure, err)
+	}
+	result.Findings = counts
+
+	if opts.failOn != "" {
+		threshold := utils.SeverityRank(opts.failOn)
+		for severity, count := range counts {
+			if count > 0 && utils.SeverityRank(severity) >= threshold {
+				result.Error = fmt.Sprintf("findings at or above '%s' severity exist", opts.failOn)
+				return exitFindings
+			}
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sentinel/modules/database"
+
+	"github.com/fatih/color"
+)
+
+// diffPoint is one end of a diff: a run or a point in time.
+type diffPoint struct {
+	Label string    `json:"label"`
+	Time  time.Time `json:"time"`
+}
+
+// diffResult is what 'diff' prints, and what the CLI returns as data.
+type diffResult struct {
+	From    diffPoint                 `json:"from"`
+	To      diffPoint                 `json:"to"`
+	Changes []database.AssetChange    `json:"changes"`
+	Summary map[string]map[string]int `json:"summary"`
+}
+
+var diffSections = []struct{ assetType, title string }{
+	{database.AssetSubdomain, "Subdomains"},
+	{database.AssetIP, "IPs"},
+	{database.AssetPort, "Ports"},
+	{database.AssetURL, "Live URLs"},
+	{database.AssetVulnerability, "Vulnerabilities"},
+}
+
+// runDiff compares the workspace between two runs or dates. With no arguments
+// it compares the two most recent completed runs; with one, that point and now.
+func runDiff(args []string) (*diffResult, error) {
+	var from, to diffPoint
+	var err error
+	switch len(args) {
+	case 0:
+		runs, err := database.GetRecentRuns(db, database.RunCompleted, 2)
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) < 2 {
+			return nil, fmt.Errorf("need at least two completed runs to diff; pass a run ID or date instead")
+		}
+		from, to = runPoint(runs[1]), runPoint(runs[0])
+	case 1, 2:
+		if from, err = parseDiffPoint(args[0]); err != nil {
+			return nil, err
+		}
+		to = diffPoint{Label: "now", Time: time.Now()}
+		if len(args) == 2 {
+			if to, err = parseDiffPoint(args[1]); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("usage: diff [<run-id|date> [<run-id|date>]]")
+	}
+	if to.Time.Before(from.Time) {
+		from, to = to, from
+	}
+
+	changes, err := database.DiffAssets(db, from.Time, to.Time)
+	if err != nil {
+		return nil, err
+	}
+	res := &diffResult{From: from, To: to, Changes: changes, Summary: make(map[string]map[string]int)}
+	for _, c := range changes {
+		if res.Summary[c.Type] == nil {
+			res.Summary[c.Type] = make(map[string]int)
+		}
+		res.Summary[c.Type][c.Change]++
+	}
+	printDiff(res)
+	return res, nil
+}
+
+// parseDiffPoint accepts a run ID, a relative age such as 7d or 12h, or a
+// local date/time such as 2024-05-01 or 2024-05-01T15:04.
+func parseDiffPoint(s string) (diffPoint, error) {
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		run, err := database.GetRun(db, id)
+		if err != nil {
+			return diffPoint{}, err
+		}
+		return runPoint(*run), nil
+	}
+
+	if n, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && strings.HasSuffix(s, "d") {
+		return diffPoint{Label: s + " ago", Time: time.Now().AddDate(0, 0, -n)}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return diffPoint{Label: s + " ago", Time: time.Now().Add(-d)}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return diffPoint{Label: s, Time: t}, nil
+		}
+	}
+	return diffPoint{}, fmt.Errorf("'%s' is not a run ID, age (e.g. 7d) or date (e.g. 2024-05-01)", s)
+}
+
+// runPoint refers to the state of the workspace when a run ended.
+func runPoint(r database.Run) diffPoint {
+	p := diffPoint{Label: fmt.Sprintf("run %d (%s)", r.ID, r.Pipeline), Time: time.Now()}
+	if r.FinishedAt.Valid {
+		p.Time = r.FinishedAt.Time
+	}
+	return p
+}
+
+func printDiff(res *diffResult) {
+	cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+	const layout = "2006-01-02 15:04:05"
+	fmt.Printf("\n%s %s [%s] -> %s [%s]\n", cyan("Changes:"),
+		res.From.Label, res.From.Time.Local().Format(layout), res.To.Label, res.To.Time.Local().Format(layout))
+	if len(res.Changes) == 0 {
+		fmt.Println("  No assets were added or removed.")
+		fmt.Println()
+		return
+	}
+
+	for _, section := range diffSections {
+		counts := res.Summary[section.assetType]
+		if len(counts) == 0 {
+			continue
+		}
+		fmt.Printf("\n  %s (%s, %s)\n", cyan(section.title),
+			color.GreenString("+%d", counts[database.EventAdded]), color.RedString("-%d", counts[database.EventRemoved]))
+		for _, c := range res.Changes {
+			if c.Type != section.assetType {
+				continue
+			}
+			if c.Change == database.EventAdded {
+				fmt.Printf("    %s %s\n", color.GreenString("+"), c.Asset)
+			} else {
+				fmt.Printf("    %s %s\n", color.RedString("-"), c.Asset)
+			}
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sentinel/modules/registry"
+	"sentinel/modules/scope"
+	"sentinel/modules/utils"
+	"sentinel/modules/vault"
+
+	"github.com/fatih/color"
+)
+
+// proxyDialTimeout bounds the reachability check of the configured proxy.
+const proxyDialTimeout = 3 * time.Second
+
+// doctorTool is the state of one external tool.
+type doctorTool struct {
+	Name      string   `json:"name"`
+	Installed bool     `json:"installed"`
+	Modules   []string `json:"modules"`
+	// Optional tools are replaced by built-in code when they are missing.
+	Optional bool `json:"optional,omitempty"`
+	// Proxy says how the tool uses the configured proxy, or why it cannot.
+	Proxy        string `json:"proxy,omitempty"`
+	ProxyCapable bool   `json:"proxy_capable,omitempty"`
+}
+
+// doctorReport is what 'doctor' prints, and what the CLI returns as data.
+// Problems make the command fail; warnings do not.
+type doctorReport struct {
+	Tools    []doctorTool `json:"tools"`
+	Proxy    string       `json:"proxy,omitempty"`
+	Problems []string     `json:"problems,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+// runDoctor checks the installed tools and the configuration, including
+// whether every tool can send its traffic through the configured proxy.
+func runDoctor() (*doctorReport, error) {
+	report := &doctorReport{Proxy: appConfig.Proxy}
+
+	byTool := make(map[string]*doctorTool)
+	var names []string
+	add := func(m registry.Module, tool string, optional bool) {
+		t, ok := byTool[tool]
+		if !ok {
+			t = &doctorTool{Name: tool, Installed: utils.CommandExists(tool), Optional: optional}
+			byTool[tool] = t
+			names = append(names, tool)
+		}
+		t.Optional = t.Optional && optional
+		t.Modules = append(t.Modules, m.Name())
+	}
+	for _, m := range registry.All() {
+		for _, tool := range m.RequiredTools() {
+			add(m, tool, false)
+		}
+		for _, tool := range registry.OptionalTools(m) {
+			add(m, tool, true)
+		}
+	}
+	sort.Strings(names)
+
+	proxyValid := false
+	if appConfig.Proxy != "" {
+		if u, err := utils.ParseProxy(appConfig.Proxy); err != nil {
+			report.Problems = append(report.Problems, err.Error())
+		} else {
+			proxyValid = true
+			conn, err := net.DialTimeout("tcp", u.Host, proxyDialTimeout)
+			if err != nil {
+				report.Problems = append(report.Problems, fmt.Sprintf("the proxy %s is not reachable: %v", u.Host, err))
+			} else {
+				conn.Close()
+			}
+		}
+	}
+
+	var bypass []string
+	for _, name := range names {
+		t := byTool[name]
+		switch {
+		case !t.Installed && t.Optional:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s is not installed; %s will use built-in code instead", name, strings.Join(t.Modules, ", ")))
+		case !t.Installed:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s is not installed; unavailable modules: %s", name, strings.Join(t.Modules, ", ")))
+		}
+		if proxyValid {
+			t.ProxyCapable, t.Proxy = utils.ProxySupport(name, appConfig.Proxy)
+			if !t.ProxyCapable {
+				bypass = append(bypass, name)
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s cannot use the proxy (%s); its traffic will not be proxied", name, t.Proxy))
+			}
+		}
+		report.Tools = append(report.Tools, *t)
+	}
+
+	if _, err := scope.New(appConfig); err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("invalid scope configuration: %v", err))
+	}
+	if !vault.Configured() {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("no secrets key is set; secrets are encrypted with the key generated in %s, which travels with the workspace (set %s or %s to keep it apart)", filepath.Join(appConfig.Workspace, vault.KeyFile), vault.PassphraseEnv, vault.KeyEnv))
+	}
+	if len(appConfig.Targets) == 0 {
+		report.Warnings = append(report.Warnings, "no targets are configured; add one with 'add target <domain>'")
+	}
+
+	printDoctor(report, bypass)
+	if len(report.Problems) > 0 {
+		return report, fmt.Errorf("doctor found %d problem(s)", len(report.Problems))
+	}
+	return report, nil
+}
+
+func printDoctor(report *doctorReport, bypass []string) {
+	utils.Banner("Sentinel Doctor")
+	color.New(color.FgYellow).Println("[*] Tools:")
+	for _, t := range report.Tools {
+		line := fmt.Sprintf("%s (%s)", t.Name, strings.Join(t.Modules, ", "))
+		if t.Proxy != "" {
+			line += " - proxy: " + t.Proxy
+		}
+		switch {
+		case !t.Installed && t.Optional:
+			color.Yellow("  [~] %s: not installed (optional)", line)
+		case !t.Installed:
+			color.Red("  [!] %s: not installed", line)
+		case report.Proxy != "" && t.Proxy != "" && !t.ProxyCapable:
+			color.Yellow("  [~] %s", line)
+		default:
+			color.Green("  [✔] %s", line)
+		}
+	}
+	if report.Proxy != "" {
+		fmt.Println()
+		color.New(color.FgYellow).Printf("[*] Proxy: %s\n", report.Proxy)
+		if len(bypass) > 0 {
+			color.Yellow("  Traffic of %s bypasses the proxy.", strings.Join(bypass, ", "))
+		}
+	}
+	fmt.Println()
+	for _, p := range report.Problems {
+		color.Red("[!] %s", p)
+	}
+	for _, w := range report.Warnings {
+		color.Yellow("[~] %s", w)
+	}
+	if len(report.Problems) == 0 && len(report.Warnings) == 0 {
+		color.Green("[✔] No problems found.")
+	}
+	fmt.Println()
+}
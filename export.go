@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sentinel/modules/database"
+	"sentinel/modules/export"
+	"sentinel/modules/reporting"
+	"sentinel/modules/utils"
+)
+
+// dataOut receives exported data when no --output file is given. The CLI points
+// it at the real stdout, which it otherwise reserves for the JSON summary.
+var dataOut io.Writer = os.Stdout
+
+// exportResult is what the CLI returns as data for 'export'.
+type exportResult struct {
+	Kind   string `json:"kind"`
+	Format string `json:"format"`
+	Output string `json:"output"`
+	Rows   int    `json:"rows,omitempty"`
+	Bytes  int    `json:"bytes"`
+}
+
+const exportUsage = "export <sarif|subdomains|ips|ports|urls|vulns|secrets|params> [--format csv|jsonl|txt] [--filter <expr>] [--include-removed] [--include-false-positives] [--output <file>]"
+
+// exportKinds lists what can be exported: SARIF and every database view.
+func exportKinds() []string {
+	return append([]string{"sarif"}, database.ViewNames()...)
+}
+
+// runExport writes workspace data in a machine-readable format to the --output
+// file, or to dataOut when there is none (or it is "-").
+func runExport(kind string, opts *cliFlags) (*exportResult, error) {
+	res := &exportResult{Kind: kind, Format: opts.format}
+	var buf bytes.Buffer
+	if kind == "sarif" {
+		if opts.format != "" && opts.format != "sarif" {
+			return nil, fmt.Errorf("sarif exports do not take --format")
+		}
+		if len(opts.filters) > 0 {
+			return nil, fmt.Errorf("sarif exports do not take --filter")
+		}
+		if opts.includeFP {
+			return nil, fmt.Errorf("sarif exports always leave out false positives; accepted risks are exported as suppressed results")
+		}
+		res.Format = "sarif"
+		content, err := reporting.Render(db, appConfig.Workspace, "sarif")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(content)
+	} else {
+		if database.ViewColumns(kind) == nil {
+			return nil, fmt.Errorf("unknown export '%s' (expected one of: %s)", kind, strings.Join(exportKinds(), ", "))
+		}
+		if res.Format == "" {
+			res.Format = "csv"
+		}
+		if !export.IsFormat(res.Format) {
+			return nil, fmt.Errorf("unknown export format '%s' (expected %s)", res.Format, strings.Join(export.Formats, ", "))
+		}
+		q := database.ViewQuery{IncludeRemoved: opts.includeRemoved, IncludeFalsePositives: opts.includeFP}
+		for _, expr := range opts.filters {
+			f, err := database.ParseFilter(expr)
+			if err != nil {
+				return nil, err
+			}
+			q.Filters = append(q.Filters, f)
+		}
+		table, err := database.QueryView(db, kind, q)
+		if err != nil {
+			return nil, err
+		}
+		res.Rows = len(table.Rows)
+		if err := export.Write(&buf, table, res.Format); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Bytes = buf.Len()
+	output := opts.output
+	if output == "" || output == "-" {
+		res.Output = "stdout"
+		_, err := dataOut.Write(buf.Bytes())
+		return res, err
+	}
+	res.Output = output
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	utils.Success(fmt.Sprintf("Exported %s to %s", kind, output))
+	return res, nil
+}
@@ -4,27 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"sentinel/modules/checkpoint"
 	"sentinel/modules/config"
-	"sentinel/modules/crawling"
 	"sentinel/modules/database"
-	"sentinel/modules/exploit"
-	"sentinel/modules/fuzzing"
-	"sentinel/modules/params"
-	"sentinel/modules/reconnaissance"
-	"sentinel/modules/reporting"
-	"sentinel/modules/scanning"
-	"sentinel/modules/secrets"
+	"sentinel/modules/importer"
+	"sentinel/modules/monitor"
+	"sentinel/modules/notify"
+	"sentinel/modules/pipeline"
+	"sentinel/modules/registry"
+	"sentinel/modules/scope"
 	"sentinel/modules/utils"
-	"sentinel/modules/visual"
 
 	"github.com/c-bata/go-prompt"
 	"github.com/fatih/color"
@@ -33,34 +34,48 @@ import (
 var appConfig *config.Config
 var db *sql.DB
 
+// runTargets are the targets given with --target. They replace the stored
+// targets for the run they were given for and are not saved.
+var runTargets []string
+
 // Command and option suggestions for the completer
 var commands = []prompt.Suggest{
 	{Text: "help", Description: "Show the help menu"},
 	{Text: "add", Description: "Add a value to a configuration list (e.g. add target example.com)"},
 	{Text: "remove", Description: "Remove a value from a list (e.g. remove target example.com)"},
-	{Text: "show", Description: "Show the current configuration from config.yaml"},
+	{Text: "show", Description: "Show the configuration, or browse results (e.g. 'show urls status=200 --sort -last_seen')"},
 	{Text: "run", Description: "Run a module (e.g. 'run recon')"},
+	{Text: "monitor", Description: "Run the schedules from config.yaml until stopped (e.g. 'monitor', 'monitor --once')"},
+	{Text: "notify", Description: "Send a test notification to the configured sinks (e.g. 'notify test slack')"},
+	{Text: "diff", Description: "Show assets added or removed between two runs or dates (e.g. 'diff 7d')"},
+	{Text: "export", Description: "Export findings or assets for other tools (e.g. 'export urls --format txt --filter status=200')"},
+	{Text: "import", Description: "Import results of other tools (e.g. 'import nmap scan.xml --source colleague')"},
+	{Text: "triage", Description: "Mark findings confirmed, false positive, accepted risk or fixed (e.g. 'triage vuln 12 false-positive')"},
+	{Text: "workspace", Description: "List, create, switch, rename, delete or archive workspaces (e.g. 'workspace switch acme')"},
+	{Text: "db", Description: "Manage the workspace database (e.g. 'db migrate --status')"},
+	{Text: "doctor", Description: "Check the installed tools, the configuration and proxy support"},
 	{Text: "banner", Description: "Display the Sentinel banner"},
 	{Text: "clear", Description: "Clear the screen"},
 	{Text: "exit", Description: "Exit Sentinel"},
 }
 
+const importUsage = "import <nmap|burp|har|list|nuclei> <file> [--source <label>]"
+
 var addRemoveOptions = []prompt.Suggest{
 	{Text: "target", Description: "A root domain or IP to include in scope"},
 	{Text: "exclude", Description: "A domain or IP to exclude from scope"},
 }
 
-var runOptions = []prompt.Suggest{
-	{Text: "recon", Description: "Perform asset discovery and reconnaissance for all targets"},
-	{Text: "crawl", Description: "Crawl discovered web services to find more endpoints"},
-	{Text: "secrets", Description: "Scan JavaScript files for hardcoded secrets and credentials"},
-	{Text: "params", Description: "Discover hidden parameters on known endpoints"},
-	{Text: "fuzz", Description: "Discover hidden content and directories with ffuf"},
-	{Text: "scan", Description: "Run vulnerability scans on discovered web services"},
-	{Text: "visual", Description: "Take screenshots of all live web services"},
-	{Text: "exploit", Description: "Research public exploits for vulnerabilities found"},
-	{Text: "report", Description: "Generate a summary report of all findings"},
-	{Text: "all", Description: "Run all modules in sequence: recon -> crawl -> secrets -> params -> fuzz -> scan -> exploit -> report"},
+// runOptions builds the 'run' suggestions from the module registry.
+func runOptions() []prompt.Suggest {
+	var options []prompt.Suggest
+	for _, m := range registry.All() {
+		options = append(options, prompt.Suggest{Text: m.Name(), Description: m.Description()})
+	}
+	return append(options, prompt.Suggest{
+		Text:        "all",
+		Description: "Run every module as a dependency-ordered pipeline, in parallel where possible",
+	})
 }
 
 func printBanner() {
@@ -129,123 +144,445 @@ func executor(in string) {
 	case "clear":
 		clearScreen()
 	case "show":
-		showOptions()
-	case "run":
 		if len(args) == 0 {
-			color.Red("Usage: run <module>")
+			showOptions()
 			return
 		}
-		module := args[0]
-		switch module {
-		case "recon":
-			reconnaissance.RunReconnaissance(ctx, appConfig, db)
-		case "crawl":
-			crawling.RunCrawl(ctx, appConfig, db)
-		case "secrets":
-			secrets.RunSecrets(ctx, appConfig, db)
-		case "params":
-			params.RunParams(ctx, appConfig, db)
-		case "fuzz":
-			fuzzing.RunFuzzing(ctx, appConfig, db)
-		case "scan":
-			scanning.RunScan(ctx, appConfig, db)
-		case "visual":
-			visual.RunVisual(ctx, appConfig, db)
-		case "exploit":
-			exploit.RunExploitResearch(ctx, appConfig, db)
-		case "report":
-			reporting.GenerateReport(appConfig, db)
-		case "all":
-			// Fix: Get targets from DB for 'run all'
-			targets, err := database.GetTargetStrings(db)
-			if err != nil {
-				color.Red("Could not get targets from database for 'run all': %v", err)
-				return
-			}
-			if len(targets) == 0 {
-				color.Yellow("No targets in scope. Use 'add target <domain>' to add one.")
-				return
-			}
-			appConfig.Targets = targets // Ensure the config state is aligned with DB for this run.
-
-			reconnaissance.RunReconnaissance(ctx, appConfig, db)
-			crawling.RunCrawl(ctx, appConfig, db)
-			secrets.RunSecrets(ctx, appConfig, db)
-			params.RunParams(ctx, appConfig, db)
-			fuzzing.RunFuzzing(ctx, appConfig, db)
-			scanning.RunScan(ctx, appConfig, db)
-			exploit.RunExploitResearch(ctx, appConfig, db)
-			reporting.GenerateReport(appConfig, db)
-		default:
-			color.Red("Unknown module: %s", module)
+		var opts cliFlags
+		fs := newFlagSet("show", &opts)
+		positional, err := parseInterspersed(fs, args)
+		if err != nil || len(positional) == 0 {
+			color.Red("Usage: " + showUsage)
+			return
+		}
+		if positional[0] == "secret" {
+			_, err = runShowSecret(positional[1:], opts.reveal)
+		} else {
+			_, err = runShowTable(positional[0], positional[1:], &opts)
+		}
+		if err != nil {
+			color.Red("%v", err)
+		}
+	case "run":
+		resume, format, ok := parseRunFlags(args)
+		if !ok {
+			color.Red("Usage: run <module> [--resume] [--format md|json|html|sarif]")
+			return
+		}
+		if format != "" {
+			// The override only applies to this run; the saved config is left alone.
+			saved := appConfig.Reporting.Format
+			appConfig.Reporting.Format = format
+			defer func() { appConfig.Reporting.Format = saved }()
+		}
+		if err := runModule(ctx, args[0], resume); err != nil {
+			color.Red("Module '%s' failed: %v", args[0], err)
 		}
 	case "add":
 		if len(args) < 2 {
 			color.Red("Usage: add <type> <value> (e.g., add target example.com)")
 			return
 		}
-		addType, value := args[0], strings.Join(args[1:], " ")
-		switch addType {
-		case "target":
-			// --- Intelligent Target Parsing ---
-			// Normalize the input by adding a default scheme if one isn't present.
-			var normalizedInput = value
-			if !strings.HasPrefix(normalizedInput, "http://") && !strings.HasPrefix(normalizedInput, "https://") {
-				normalizedInput = "http://" + normalizedInput
+		if err := addValue(args[0], strings.Join(args[1:], " ")); err != nil {
+			color.Red("%v", err)
+			return
+		}
+		if args[0] == "target" {
+			color.Yellow("Hint: Use 'run recon' to start discovery.")
+		}
+	case "remove":
+		if len(args) < 2 {
+			color.Red("Usage: remove <type> <value>")
+			return
+		}
+		if err := removeValue(args[0], strings.Join(args[1:], " ")); err != nil {
+			color.Red("%v", err)
+		}
+	case "db":
+		if len(args) == 0 || args[0] != "migrate" || (len(args) > 1 && args[1] != "--status") {
+			color.Red("Usage: db migrate [--status]")
+			return
+		}
+		if _, err := runMigrate(len(args) > 1); err != nil {
+			color.Red("%v", err)
+		}
+	case "diff":
+		if _, err := runDiff(args); err != nil {
+			color.Red("%v", err)
+		}
+	case "export":
+		var opts cliFlags
+		fs := newFlagSet("export", &opts)
+		positional, err := parseInterspersed(fs, args)
+		if err != nil || len(positional) != 1 {
+			color.Red("Usage: " + exportUsage)
+			return
+		}
+		if _, err := runExport(positional[0], &opts); err != nil {
+			color.Red("%v", err)
+		}
+	case "import":
+		var opts cliFlags
+		fs := newFlagSet("import", &opts)
+		positional, err := parseInterspersed(fs, args)
+		if err != nil || len(positional) != 2 {
+			color.Red("Usage: " + importUsage)
+			return
+		}
+		if _, err := importer.Import(appConfig, db, positional[0], positional[1], opts.source); err != nil {
+			color.Red("%v", err)
+		}
+	case "notify":
+		if len(args) == 0 || args[0] != "test" || len(args) > 2 {
+			color.Red("Usage: notify test [sink]")
+			return
+		}
+		if _, err := testNotify(ctx, strings.Join(args[1:], "")); err != nil {
+			color.Red("%v", err)
+		}
+	case "triage":
+		var opts cliFlags
+		fs := newFlagSet("triage", &opts)
+		positional, err := parseInterspersed(fs, args)
+		if err != nil {
+			color.Red("Usage: " + triageUsage)
+			return
+		}
+		if _, err := runTriage(positional, &opts); err != nil {
+			color.Red("%v", err)
+		}
+	case "workspace":
+		var opts cliFlags
+		fs := newFlagSet("workspace", &opts)
+		positional, err := parseInterspersed(fs, args)
+		if err != nil || len(positional) == 0 {
+			color.Red("Usage: " + workspaceUsage)
+			return
+		}
+		if _, err := runWorkspace(positional, opts.force); err != nil {
+			color.Red("%v", err)
+		}
+	case "doctor":
+		if _, err := runDoctor(); err != nil {
+			color.Red("%v", err)
+		}
+	case "monitor":
+		if len(args) > 1 || (len(args) == 1 && args[0] != "--once") {
+			color.Red("Usage: monitor [--once]")
+			return
+		}
+		if _, err := runMonitor(ctx, len(args) == 1); err != nil {
+			color.Red("%v", err)
+		}
+
+	default:
+		color.Red("Unknown command: %s", command)
+	}
+}
+
+// runModule dispatches a single module (or every module for "all") as a
+// pipeline recorded in the runs table. With resume set, the latest unfinished
+// run of the same module is continued instead of starting over.
+// It is shared by the interactive shell and the non-interactive CLI.
+func runModule(ctx context.Context, module string, resume bool) error {
+	_, err := runPipeline(ctx, module, []string{module}, resume)
+	return err
+}
+
+// parseRunFlags parses the flags following 'run <module>' in the shell.
+func parseRunFlags(args []string) (resume bool, format string, ok bool) {
+	if len(args) == 0 {
+		return false, "", false
+	}
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--resume":
+			resume = true
+		case "--format":
+			if i+1 == len(args) {
+				return false, "", false
 			}
+			i++
+			format = args[i]
+		default:
+			return false, "", false
+		}
+	}
+	return resume, format, true
+}
 
-			parsedURL, err := url.Parse(normalizedInput)
-			if err != nil {
-				color.Red("Invalid target format: %v", err)
-				return
+// testNotify sends a test notification to every sink, or only to the named one.
+func testNotify(ctx context.Context, sink string) ([]string, error) {
+	n, err := notify.New(appConfig)
+	if err != nil {
+		return nil, err
+	}
+	reached, err := n.Test(ctx, sink)
+	for _, name := range reached {
+		color.Green("[+] Test notification sent to '%s'.", name)
+	}
+	return reached, err
+}
+
+// runMonitor runs the schedules from config.yaml until ctx is cancelled, or
+// each of them once when once is set.
+func runMonitor(ctx context.Context, once bool) ([]monitor.Result, error) {
+	for _, s := range appConfig.Monitor.Schedules {
+		for _, module := range s.Modules {
+			if !isRunOption(module) {
+				return nil, fmt.Errorf("schedule '%s': unknown module: %s", s.Name, module)
 			}
+		}
+		// A misspelt workspace would otherwise be created empty and scanned with no targets.
+		if s.Workspace != "" && !isWorkspace(s.Workspace) {
+			return nil, fmt.Errorf("schedule '%s': no workspace '%s' (create it with 'workspace create %s')", s.Name, s.Workspace, s.Workspace)
+		}
+	}
+	run := func(ctx context.Context, jobDB *sql.DB, workspace, name string, modules []string) (int64, error) {
+		if jobDB == db {
+			return runPipeline(ctx, name, modules, false)
+		}
+		// Other workspaces are run in without switching to them: their database
+		// and targets stand in for the current ones until the run is over.
+		targets, err := database.GetTargetStrings(jobDB)
+		if err != nil {
+			return 0, fmt.Errorf("could not get the targets of workspace '%s': %w", workspace, err)
+		}
+		cfg := *appConfig
+		cfg.Workspace, cfg.Targets = workspace, targets
+		prevDB, prevConfig := db, appConfig
+		db, appConfig = jobDB, &cfg
+		defer func() { db, appConfig = prevDB, prevConfig }()
+		return runPipeline(ctx, name, modules, false)
+	}
+	return monitor.Run(ctx, appConfig, db, run, once)
+}
 
-			// We only want the hostname for our tools.
-			hostname := parsedURL.Hostname()
-			if hostname == "" {
-				color.Red("Could not extract a valid domain/IP from '%s'", value)
-				return
+// runPipeline runs modules as one pipeline recorded under name in the runs
+// table and returns the run ID. "all" stands for every registered module.
+func runPipeline(ctx context.Context, name string, modules []string, resume bool) (int64, error) {
+	if len(runTargets) > 0 {
+		appConfig.Targets = append([]string(nil), runTargets...)
+	}
+	var names []string
+	for _, module := range modules {
+		if module != "all" {
+			names = append(names, module)
+			continue
+		}
+		// Fix: Get targets from DB for 'run all', unless --target replaced them.
+		if len(runTargets) == 0 {
+			targets, err := database.GetTargetStrings(db)
+			if err != nil {
+				return 0, fmt.Errorf("could not get targets from database for 'run all': %w", err)
+			}
+			if len(targets) == 0 {
+				color.Yellow("No targets in scope. Use 'add target <domain>' to add one.")
+				return 0, nil
 			}
+			appConfig.Targets = targets // Ensure the config state is aligned with DB for this run.
+		}
 
-			// Check for duplicates before adding.
-			for _, t := range appConfig.Targets {
-				if t == hostname {
-					color.Yellow("Target '%s' is already in scope.", hostname)
-					return
-				}
+		// Modules whose tools are missing are left out rather than failing the
+		// run; asking for one by name still reports the missing tools.
+		for _, m := range registry.All() {
+			if missing := registry.MissingTools(m); len(missing) > 0 {
+				utils.Warn(fmt.Sprintf("Leaving '%s' out of 'all': missing required tools: %s", m.Name(), strings.Join(missing, ", ")))
+				continue
 			}
+			names = append(names, m.Name())
+		}
+	}
 
-			appConfig.Targets = append(appConfig.Targets, hostname)
-			database.AddTarget(db, hostname) // Also add to DB
-			color.Green("Parsed and added '%s' to targets.", hostname)
-			color.Yellow("Hint: Use 'run recon' to start discovery.")
+	p, err := pipeline.Build(names)
+	if err != nil {
+		return 0, err
+	}
 
-		case "exclude":
-			appConfig.Exclude = append(appConfig.Exclude, value)
-			color.Green("Added '%s' to exclusions.", value)
-		default:
-			color.Red("Unknown type '%s'. Can only add 'target' or 'exclude'.", addType)
+	runID, err := startRun(name, resume)
+	if err != nil {
+		return 0, err
+	}
+	ctx = checkpoint.WithRun(ctx, db, runID)
+
+	// Rate limits apply to each tool process, so stages run one at a time to
+	// keep the total rate within them.
+	concurrency := appConfig.Pipeline.Concurrency
+	if (utils.Options{RateLimit: appConfig.RateLimit}).RateLimited() && concurrency != 1 && len(names) > 1 {
+		utils.Log("Rate limits are configured: running pipeline stages one at a time.")
+		concurrency = 1
+	}
+
+	start := time.Now()
+	results := p.Run(ctx, appConfig, db, concurrency)
+	if len(results) > 1 {
+		pipeline.PrintSummary(results, time.Since(start))
+	}
+
+	failed := pipeline.Failed(results)
+	status := database.RunCompleted
+	switch {
+	case ctx.Err() != nil:
+		status = database.RunInterrupted
+		if len(modules) == 1 && modules[0] == name {
+			color.Yellow("Run %d was interrupted. Use 'run %s --resume' to continue where it stopped.", runID, name)
+		} else {
+			color.Yellow("Run %d was interrupted.", runID)
 		}
-	case "remove":
-		if len(args) < 2 {
-			color.Red("Usage: remove <type> <value>")
-			return
+	case len(failed) > 0:
+		status = database.RunFailed
+	}
+	if err := database.FinishRun(db, runID, status); err != nil {
+		utils.Warn(fmt.Sprintf("Could not record the end of run %d: %v", runID, err))
+	}
+
+	if len(failed) == 0 {
+		return runID, nil
+	}
+	if len(results) == 1 {
+		return runID, results[0].Err
+	}
+	return runID, fmt.Errorf("stages did not complete: %s", strings.Join(failed, ", "))
+}
+
+func startRun(module string, resume bool) (int64, error) {
+	if resume {
+		runID, err := database.GetResumableRun(db, module)
+		if err != nil {
+			return 0, err
 		}
-		removeType, value := args[0], strings.Join(args[1:], " ")
-		switch removeType {
-		case "target":
-			appConfig.Targets = removeStringFromSlice(appConfig.Targets, value)
-			color.Green("Removed '%s' from targets.", value)
-		case "exclude":
-			appConfig.Exclude = removeStringFromSlice(appConfig.Exclude, value)
-			color.Green("Removed '%s' from exclusions.", value)
-		default:
-			color.Red("Unknown type '%s'. Can only remove 'target' or 'exclude'.", removeType)
+		if runID != 0 {
+			color.Cyan("[*] Resuming run %d of '%s'.", runID, module)
+			return runID, database.ResumeRun(db, runID)
+		}
+		color.Yellow("No unfinished run of '%s' to resume. Starting a new one.", module)
+	}
+	return database.StartRun(db, module)
+}
+
+// runMigrate applies pending schema migrations (unless statusOnly is set) and
+// prints the migration status of the workspace database.
+func runMigrate(statusOnly bool) ([]database.MigrationStatus, error) {
+	if !statusOnly {
+		if err := database.Migrate(db); err != nil {
+			return nil, err
+		}
+	}
+	status, err := database.GetMigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+
+	cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+	fmt.Println("\n" + cyan("Schema Migrations:"))
+	for _, m := range status {
+		applied := color.YellowString("pending")
+		if m.AppliedAt != nil {
+			applied = color.GreenString("applied %s", m.AppliedAt.Format("2006-01-02 15:04:05"))
 		}
+		fmt.Printf("  %3d  %-32s %s\n", m.Version, m.Description, applied)
+	}
+	fmt.Println()
+	return status, nil
+}
 
+// addValue adds a target or exclusion to the in-memory configuration.
+func addValue(addType, value string) error {
+	switch addType {
+	case "target":
+		hostname, err := parseTarget(value)
+		if err != nil {
+			return err
+		}
+
+		// Check for duplicates before adding.
+		for _, t := range appConfig.Targets {
+			if t == hostname {
+				color.Yellow("Target '%s' is already in scope.", hostname)
+				return nil
+			}
+		}
+
+		appConfig.Targets = append(appConfig.Targets, hostname)
+		if _, err := database.AddTarget(db, hostname); err != nil { // Also add to DB
+			return fmt.Errorf("could not store target '%s': %w", hostname, err)
+		}
+		color.Green("Parsed and added '%s' to targets.", hostname)
+
+	case "exclude":
+		appConfig.Exclude = append(appConfig.Exclude, value)
+		color.Green("Added '%s' to exclusions.", value)
 	default:
-		color.Red("Unknown command: %s", command)
+		return fmt.Errorf("unknown type '%s'. Can only add 'target' or 'exclude'", addType)
 	}
+	return nil
+}
+
+// parseTarget turns what the user typed as a target into the form it is
+// stored in. Scope rules (see the scope package) are kept as typed once the
+// scope accepts them; a plain host or URL is cut down to its host name.
+func parseTarget(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if isScopeRule(value) {
+		if _, err := scope.New(&config.Config{Targets: []string{value}}); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+
+	// --- Intelligent Target Parsing ---
+	// Normalize the input by adding a default scheme if one isn't present.
+	var normalizedInput = value
+	if !strings.HasPrefix(normalizedInput, "http://") && !strings.HasPrefix(normalizedInput, "https://") {
+		normalizedInput = "http://" + normalizedInput
+	}
+
+	parsedURL, err := url.Parse(normalizedInput)
+	if err != nil {
+		return "", fmt.Errorf("invalid target format: %w", err)
+	}
+
+	// We only want the hostname for our tools.
+	hostname := parsedURL.Hostname()
+	if hostname == "" {
+		return "", fmt.Errorf("could not extract a valid domain/IP from '%s'", value)
+	}
+	return hostname, nil
+}
+
+// isScopeRule reports whether a target is more than a host: a regular
+// expression, a CIDR range, a wildcard, or a host narrowed to a port or path.
+func isScopeRule(value string) bool {
+	if strings.HasPrefix(value, "re:") || strings.HasPrefix(value, "*.") {
+		return true
+	}
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	if i := strings.Index(value, "://"); i != -1 {
+		value = value[i+3:]
+	}
+	hostPort, path, _ := strings.Cut(value, "/")
+	if path != "" {
+		return true
+	}
+	_, port, err := net.SplitHostPort(hostPort)
+	return err == nil && port != ""
+}
+
+// removeValue removes a target or exclusion from the in-memory configuration.
+func removeValue(removeType, value string) error {
+	switch removeType {
+	case "target":
+		appConfig.Targets = removeStringFromSlice(appConfig.Targets, value)
+		color.Green("Removed '%s' from targets.", value)
+	case "exclude":
+		appConfig.Exclude = removeStringFromSlice(appConfig.Exclude, value)
+		color.Green("Removed '%s' from exclusions.", value)
+	default:
+		return fmt.Errorf("unknown type '%s'. Can only remove 'target' or 'exclude'", removeType)
+	}
+	return nil
 }
 
 func completer(d prompt.Document) []prompt.Suggest {
@@ -259,11 +596,23 @@ func completer(d prompt.Document) []prompt.Suggest {
 	if len(parts) >= 1 {
 		cmd := parts[0]
 		if cmd == "run" && len(parts) <= 2 {
-			return prompt.FilterHasPrefix(runOptions, d.GetWordAfterCursor(), true)
+			return prompt.FilterHasPrefix(runOptions(), d.GetWordAfterCursor(), true)
 		}
 		if (cmd == "add" || cmd == "remove") && len(parts) <= 2 {
 			return prompt.FilterHasPrefix(addRemoveOptions, d.GetWordAfterCursor(), true)
 		}
+		if cmd == "show" && len(parts) <= 2 {
+			return prompt.FilterHasPrefix(showOptionSuggestions(), d.GetWordAfterCursor(), true)
+		}
+		if cmd == "triage" && len(parts) <= 2 {
+			return prompt.FilterHasPrefix(triageOptions, d.GetWordAfterCursor(), true)
+		}
+		if cmd == "triage" && len(parts) >= 3 {
+			return prompt.FilterHasPrefix(triageStatusSuggestions(), d.GetWordBeforeCursor(), true)
+		}
+		if cmd == "workspace" && len(parts) <= 2 {
+			return prompt.FilterHasPrefix(workspaceOptions, d.GetWordAfterCursor(), true)
+		}
 	}
 	return []prompt.Suggest{}
 }
@@ -289,13 +638,27 @@ func showHelp() {
 	fmt.Printf("  %-20s %s (e.g., %s)\n", green("add target"), white("Add a target to the scope"), yellow("add target example.com"))
 	fmt.Printf("  %-20s %s (e.g., %s)\n", green("remove target"), white("Remove a target from the scope"), yellow("remove target example.com"))
 	fmt.Printf("  %-20s %s (e.g., %s)\n", green("run"), white("Run a module"), yellow("run recon"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("run --resume"), white("Resume the last interrupted run"), yellow("run all --resume"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("run --format"), white("Override the report format for one run"), yellow("run report --format html"))
 	fmt.Printf("  %-20s %s\n", green("show"), white("Display the current configuration"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("show <table>"), white("Browse results with filters, sorting and paging"), yellow("show urls status=200 tech~nginx --page 2"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("show secret"), white("List stored secrets, or decrypt one with --reveal"), yellow("show secret 3 --reveal"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("triage"), white("Set the status, assignee or notes of findings"), yellow("triage vuln 12 false-positive --note \"WAF page\""))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("monitor"), white("Run the configured schedules until Ctrl+C"), yellow("monitor --once"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("notify test"), white("Send a test notification to the configured sinks"), yellow("notify test slack"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("diff"), white("Show assets added or removed between runs or dates"), yellow("diff 12 15, diff 7d"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("export sarif"), white("Export vulnerabilities and secrets as SARIF"), yellow("export sarif --output findings.sarif"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("export <table>"), white("Export assets as csv, jsonl or txt"), yellow("export urls --format jsonl --filter tech~nginx"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("import"), white("Import nmap, Burp, HAR, list or nuclei results"), yellow("import nmap scan.xml"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("db migrate"), white("Apply or list schema migrations"), yellow("db migrate --status"))
+	fmt.Printf("  %-20s %s (e.g., %s)\n", green("workspace"), white("List, create, switch, rename, delete or archive workspaces"), yellow("workspace switch acme"))
+	fmt.Printf("  %-20s %s\n", green("doctor"), white("Check tools, configuration and which tools can use the proxy"))
 	fmt.Printf("  %-20s %s\n", green("banner"), white("Display the application banner"))
 	fmt.Printf("  %-20s %s\n", green("clear"), white("Clear the terminal screen"))
 	fmt.Printf("  %-20s %s\n", green("exit"), white("Exit the framework"))
 
 	fmt.Println("\n" + cyan("Available Modules for 'run':"))
-	for _, opt := range runOptions {
+	for _, opt := range runOptions() {
 		fmt.Printf("  %-20s %s\n", green(opt.Text), white(opt.Description))
 	}
 	fmt.Println()
@@ -323,9 +686,35 @@ func showOptions() {
 	fmt.Printf("    %-18s : %s\n", yellow("Scanning Intensity"), white(appConfig.Scanning.Intensity))
 	fmt.Printf("    %-18s : %s\n", yellow("Crawling Max Depth"), white(strconv.Itoa(appConfig.Crawling.MaxDepth)))
 	fmt.Printf("    %-18s : %s\n", yellow("Reporting Format"), white(appConfig.Reporting.Format))
+	fmt.Printf("    %-18s : %s\n", yellow("Pipeline Workers"), white(strconv.Itoa(appConfig.Pipeline.Concurrency)))
+	fmt.Printf("    %-18s : %s\n", yellow("Rate Limit"), white(rateLimitSummary(appConfig.RateLimit)))
+	fmt.Printf("    %-18s : %s\n", yellow("Proxy"), white(appConfig.Proxy))
 	fmt.Println(cyan("-------------------------------------------\n"))
 }
 
+// rateLimitSummary describes the configured rate limits for 'show options'.
+func rateLimitSummary(rl config.RateLimit) string {
+	var parts []string
+	if rl.RequestsPerSecond > 0 {
+		parts = append(parts, fmt.Sprintf("%d req/s", rl.RequestsPerSecond))
+	}
+	if rl.PerHost > 0 {
+		parts = append(parts, fmt.Sprintf("%d req/s per host", rl.PerHost))
+	}
+	tools := make([]string, 0, len(rl.Tools))
+	for tool, rate := range rl.Tools {
+		if rate > 0 {
+			tools = append(tools, fmt.Sprintf("%s %d req/s", tool, rate))
+		}
+	}
+	sort.Strings(tools)
+	parts = append(parts, tools...)
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
 func checkGoPath() {
 	goPath := os.Getenv("GOPATH")
 	if goPath == "" {
@@ -360,55 +749,89 @@ func changeLivePrefix() (string, bool) {
 	return prompt, true
 }
 
+// checkDependencies checks the tools required by every registered module.
+// Modules whose tools are missing are listed but the shell still starts; they
+// refuse to run until the tools are installed. Missing optional tools only
+// make their modules fall back to built-in code.
 func checkDependencies() {
 	color.New(color.FgYellow).Println("[*] Checking for required tools...")
-	requiredTools := []string{
-		"subfinder", "dnsx", "naabu", "httpx", "katana", "gau",
-		"ffuf", "gowitness", "trufflehog", "arjun",
-	}
-	missingTools := false
-	for _, tool := range requiredTools {
-		if !utils.CommandExists(tool) {
-			color.Red("  [!] %s is not installed or not in your PATH.", tool)
-			missingTools = true
-		} else {
-			color.Green("  [✔] %s is installed.", tool)
+	checked := make(map[string]bool)
+	var disabled []string
+	for _, m := range registry.All() {
+		for _, tool := range m.RequiredTools() {
+			if _, seen := checked[tool]; seen {
+				continue
+			}
+			checked[tool] = utils.CommandExists(tool)
+			if checked[tool] {
+				color.Green("  [✔] %s is installed.", tool)
+			} else {
+				color.Red("  [!] %s is not installed or not in your PATH.", tool)
+			}
+		}
+		for _, tool := range registry.OptionalTools(m) {
+			if _, seen := checked[tool]; seen {
+				continue
+			}
+			checked[tool] = utils.CommandExists(tool)
+			if checked[tool] {
+				color.Green("  [✔] %s is installed.", tool)
+			} else {
+				color.Yellow("  [~] %s is not installed; %s falls back to built-in code.", tool, m.Name())
+			}
+		}
+		if len(registry.MissingTools(m)) > 0 {
+			disabled = append(disabled, m.Name())
 		}
 	}
 
-	if missingTools {
+	if len(disabled) > 0 {
 		color.New(color.FgRed, color.Bold).Println("\n[!] Some tools are missing.")
+		color.Yellow("These modules are unavailable until their tools are installed: %s", strings.Join(disabled, ", "))
 		color.Yellow("Please run the './install_tools.sh' script to install all dependencies.")
 		color.Yellow("Then, ensure your GOPATH/bin is in your system's PATH environment variable.")
 		color.Yellow("Ex: export PATH=$PATH:$(go env GOPATH)/bin")
-		os.Exit(1)
-	} else {
-		color.New(color.FgGreen).Println("\n[✔] All required tools are installed.")
+		fmt.Println()
+		return
 	}
+	color.New(color.FgGreen).Println("\n[✔] All required tools are installed.")
 	fmt.Println()
 }
 
+// loadConfig loads config.yaml into appConfig, creating a default one if it is missing.
+func loadConfig() error {
+	var err error
+	appConfig, err = config.LoadConfig()
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+	color.Yellow("Configuration file not found. Creating a default 'config.yaml'...")
+	appConfig, err = config.CreateDefaultConfig()
+	if err != nil {
+		return fmt.Errorf("could not create default config: %w", err)
+	}
+	color.Green("Default 'config.yaml' created. Please edit it to define your targets.")
+	return nil
+}
+
 func main() {
+	// Any arguments switch Sentinel into non-interactive mode for scripts, cron and CI.
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	checkDependencies()
 	checkGoPath()
 
-	var err error
-	appConfig, err = config.LoadConfig()
-	if err != nil {
-		if os.IsNotExist(err) {
-			color.Yellow("Configuration file not found. Creating a default 'config.yaml'...")
-			appConfig, err = config.CreateDefaultConfig()
-			if err != nil {
-				color.Red("Fatal: Could not create default config: %v", err)
-				os.Exit(1)
-			}
-			color.Green("Default 'config.yaml' created. Please edit it to define your targets.")
-		} else {
-			color.Red("Fatal: Could not load config: %v", err)
-			os.Exit(1)
-		}
+	if err := loadConfig(); err != nil {
+		color.Red("Fatal: %v", err)
+		os.Exit(1)
 	}
 
+	var err error
 	db, err = database.InitDB(appConfig)
 	if err != nil {
 		color.Red("Fatal: Could not initialize database: %v", err)
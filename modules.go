@@ -0,0 +1,17 @@
+package main
+
+// Built-in modules register themselves with the module registry when their
+// package is imported. Additional (e.g. internal) modules only need a blank
+// import in a file like this one; main.go does not have to change.
+import (
+	_ "sentinel/modules/crawling"
+	_ "sentinel/modules/exploit"
+	_ "sentinel/modules/fuzzing"
+	_ "sentinel/modules/jsextract"
+	_ "sentinel/modules/params"
+	_ "sentinel/modules/reconnaissance"
+	_ "sentinel/modules/reporting"
+	_ "sentinel/modules/scanning"
+	_ "sentinel/modules/secrets"
+	_ "sentinel/modules/visual"
+)
@@ -0,0 +1,123 @@
+package checkpoint
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"sentinel/modules/database"
+	"sentinel/modules/utils"
+)
+
+// Run ties a context to a row in the runs table so modules can record and
+// resume their progress. Every method is safe to call on a nil *Run, which is
+// what modules get when they are not running inside a recorded run.
+type Run struct {
+	db *sql.DB
+	ID int64
+}
+
+type ctxKey struct{}
+
+// WithRun returns a context carrying the given run.
+func WithRun(ctx context.Context, db *sql.DB, runID int64) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &Run{db: db, ID: runID})
+}
+
+// FromContext returns the run carried by ctx, or nil.
+func FromContext(ctx context.Context) *Run {
+	run, _ := ctx.Value(ctxKey{}).(*Run)
+	return run
+}
+
+// ModuleDone reports whether module already completed in this run.
+func (r *Run) ModuleDone(module string) bool {
+	if r == nil {
+		return false
+	}
+	status, err := database.GetModuleRunStatus(r.db, r.ID, module, "")
+	return err == nil && status == database.RunCompleted
+}
+
+// SetModuleStatus records the status of module in this run.
+func (r *Run) SetModuleStatus(module, status string, err error) {
+	if r == nil {
+		return
+	}
+	if dbErr := database.SetModuleRunStatus(r.db, r.ID, module, "", status, err); dbErr != nil {
+		utils.Warn(fmt.Sprintf("Could not record status of '%s' for run %d: %v", module, r.ID, dbErr))
+	}
+}
+
+// Tracker records per-target and per-item progress of one module within a run.
+type Tracker struct {
+	run    *Run
+	module string
+	done   map[string]bool
+}
+
+// For returns the progress tracker of module for the run in ctx. The tracker is
+// nil (and a no-op) when ctx carries no run.
+func For(ctx context.Context, module string) *Tracker {
+	run := FromContext(ctx)
+	if run == nil {
+		return nil
+	}
+	done, err := database.GetRunItems(run.db, run.ID, module)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not load checkpoints for '%s': %v", module, err))
+		done = make(map[string]bool)
+	}
+	if len(done) > 0 {
+		utils.Log(fmt.Sprintf("Resuming '%s': %d item(s) already processed in run %d.", module, len(done), run.ID))
+	}
+	return &Tracker{run: run, module: module, done: done}
+}
+
+// Done reports whether item was already processed in this run.
+func (t *Tracker) Done(item string) bool {
+	return t != nil && t.done[item]
+}
+
+// Mark records that item has been fully processed.
+func (t *Tracker) Mark(item string) {
+	if t == nil {
+		return
+	}
+	t.done[item] = true
+	if err := database.MarkRunItem(t.run.db, t.run.ID, t.module, item); err != nil {
+		utils.Warn(fmt.Sprintf("Could not save checkpoint for %s: %v", item, err))
+	}
+}
+
+// TargetDone reports whether target already completed in this run.
+func (t *Tracker) TargetDone(target string) bool {
+	if t == nil {
+		return false
+	}
+	status, err := database.GetModuleRunStatus(t.run.db, t.run.ID, t.module, target)
+	return err == nil && status == database.RunCompleted
+}
+
+// StartTarget records that work on target has begun.
+func (t *Tracker) StartTarget(target string) {
+	t.setTarget(target, database.RunRunning, nil)
+}
+
+// FinishTarget records the outcome of target.
+func (t *Tracker) FinishTarget(target string, err error) {
+	status := database.RunCompleted
+	if err != nil {
+		status = database.RunFailed
+	}
+	t.setTarget(target, status, err)
+}
+
+func (t *Tracker) setTarget(target, status string, err error) {
+	if t == nil {
+		return
+	}
+	if dbErr := database.SetModuleRunStatus(t.run.db, t.run.ID, t.module, target, status, err); dbErr != nil {
+		utils.Warn(fmt.Sprintf("Could not record status of %s for '%s': %v", target, t.module, dbErr))
+	}
+}
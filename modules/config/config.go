@@ -14,9 +14,11 @@ type Config struct {
 	Workspace string `yaml:"workspace"`
 
 	// Targets is a list of root domains or IPs to include in the scope.
+	// Entries use the scope rule syntax described in the scope package.
 	Targets []string `yaml:"targets"`
 
 	// Exclude is a list of domains or IPs to explicitly exclude from scans.
+	// Supports exact hosts, *.wildcards, CIDR ranges, re:regexes and port/path rules.
 	Exclude []string `yaml:"exclude,omitempty"`
 
 	// APIKeys for various services.
@@ -25,9 +27,17 @@ type Config struct {
 		// Future keys: Shodan, Virustotal, etc.
 	} `yaml:"api_keys,omitempty"`
 
+	// Request rate limits for every tool and for Sentinel's own HTTP requests
+	RateLimit RateLimit `yaml:"rate_limit,omitempty"`
+
+	// Upstream proxy for all traffic, e.g. http://127.0.0.1:8080 (Burp) or socks5://127.0.0.1:9050
+	Proxy string `yaml:"proxy,omitempty"`
+
 	// Reconnaissance module settings
 	Recon struct {
-		Threads int `yaml:"threads"`
+		Threads    int        `yaml:"threads"`
+		DNS        DNS        `yaml:"dns,omitempty"`
+		Bruteforce Bruteforce `yaml:"bruteforce,omitempty"`
 	} `yaml:"recon"`
 
 	// Fuzzing module settings
@@ -47,13 +57,94 @@ type Config struct {
 
 	// Secrets module settings
 	Secrets struct {
+		// Engine selects the scanner: "native" (built-in rules), "trufflehog" or "both".
+		// Empty means both when trufflehog is installed, native otherwise.
+		Engine string   `yaml:"engine,omitempty"`
+		Rules  []string `yaml:"rules,omitempty"` // extra rule files for the native scanner
+		// SourceMaps selects which source maps are unpacked and analysed: "probe" (the
+		// default) follows the references of scripts and tries <script>.map otherwise,
+		// "referenced" only follows references and "off" skips source maps.
+		SourceMaps       string `yaml:"source_maps,omitempty"`
 		TrufflehogConfig string `yaml:"trufflehog_config,omitempty"`
 	} `yaml:"secrets,omitempty"`
 
 	// Reporting module settings
 	Reporting struct {
-		Format string `yaml:"format,omitempty"` // "md", "json", "html"
+		Format string `yaml:"format,omitempty"` // "md", "json", "html", "sarif"
 	} `yaml:"reporting,omitempty"`
+
+	// Pipeline settings for 'run all'
+	Pipeline struct {
+		Concurrency int `yaml:"concurrency,omitempty"` // Max modules running at once
+	} `yaml:"pipeline,omitempty"`
+
+	// Monitor settings for 'sentinel monitor'
+	Monitor struct {
+		Schedules []Schedule `yaml:"schedules,omitempty"`
+	} `yaml:"monitor,omitempty"`
+
+	// Notification settings for new findings and assets
+	Notify struct {
+		Sinks []NotifySink `yaml:"sinks,omitempty"`
+	} `yaml:"notify,omitempty"`
+}
+
+// Schedule runs a set of modules as one pipeline whenever its cron expression matches.
+type Schedule struct {
+	Name      string   `yaml:"name"`
+	Cron      string   `yaml:"cron"`                // "minute hour day month weekday", @daily, @every 6h, ...
+	Modules   []string `yaml:"modules"`             // Module names, or "all"
+	Workspace string   `yaml:"workspace,omitempty"` // Existing workspace to run in; the current one when empty
+}
+
+// DNS configures how recon resolves subdomains.
+type DNS struct {
+	Engine    string   `yaml:"engine,omitempty"`    // "auto" (dnsx if installed, else native), "dnsx", "native"
+	Resolvers []string `yaml:"resolvers,omitempty"` // ip or ip:port for the native resolver; system resolvers when empty
+	Retries   int      `yaml:"retries,omitempty"`   // Native resolver retries per host, 2 by default
+	Timeout   int      `yaml:"timeout,omitempty"`   // Native resolver timeout per query in seconds, 3 by default
+}
+
+// Bruteforce configures active subdomain discovery in recon.
+type Bruteforce struct {
+	Enabled       bool   `yaml:"enabled,omitempty"`        // Resolve <word>.<target> for every word of the wordlist
+	Wordlist      string `yaml:"wordlist,omitempty"`       // Subdomain wordlist; the one shipped with Sentinel when empty
+	Permutations  bool   `yaml:"permutations,omitempty"`   // Resolve altdns-style permutations of known subdomains
+	Words         string `yaml:"words,omitempty"`          // Word list for permutations; a built-in list when empty
+	MaxCandidates int    `yaml:"max_candidates,omitempty"` // Names resolved per target, 50000 by default
+}
+
+// RateLimit caps how fast tools and Sentinel itself send requests. Zero means no limit.
+type RateLimit struct {
+	RequestsPerSecond int            `yaml:"requests_per_second,omitempty"` // Across all hosts
+	PerHost           int            `yaml:"per_host,omitempty"`            // To any single host
+	Tools             map[string]int `yaml:"tools,omitempty"`               // Per-tool overrides, e.g. nuclei: 50
+}
+
+// NotifySink is a destination for notifications about new findings and assets.
+type NotifySink struct {
+	Name        string   `yaml:"name"`
+	Type        string   `yaml:"type"`                   // "webhook", "smtp", "command"
+	MinSeverity string   `yaml:"min_severity,omitempty"` // Only events at or above this severity
+	Events      []string `yaml:"events,omitempty"`       // vulnerability, secret, new_subdomain, new_port; empty means all
+	Template    string   `yaml:"template,omitempty"`     // Go text/template for the message body
+
+	// Webhook settings
+	URL    string `yaml:"url,omitempty"`
+	Format string `yaml:"format,omitempty"` // "slack", "discord", "teams", "json"
+
+	// SMTP settings
+	SMTP struct {
+		Host     string   `yaml:"host,omitempty"`
+		Port     int      `yaml:"port,omitempty"` // 587 (STARTTLS) by default, 465 for implicit TLS
+		Username string   `yaml:"username,omitempty"`
+		Password string   `yaml:"password,omitempty"`
+		From     string   `yaml:"from,omitempty"`
+		To       []string `yaml:"to,omitempty"`
+	} `yaml:"smtp,omitempty"`
+
+	// Command settings: the event is passed as JSON on stdin
+	Command []string `yaml:"command,omitempty"`
 }
 
 // CreateDefaultConfig generates a default config.yaml file.
@@ -63,7 +154,9 @@ func CreateDefaultConfig() (*Config, error) {
 		Targets:   []string{"example.com"},
 		Exclude:   []string{},
 		Recon: struct {
-			Threads int `yaml:"threads"`
+			Threads    int        `yaml:"threads"`
+			DNS        DNS        `yaml:"dns,omitempty"`
+			Bruteforce Bruteforce `yaml:"bruteforce,omitempty"`
 		}{
 			Threads: 50,
 		},
@@ -83,15 +176,28 @@ func CreateDefaultConfig() (*Config, error) {
 			MaxDepth: 2,
 		},
 		Secrets: struct {
+			// Engine selects the scanner: "native" (built-in rules), "trufflehog" or "both".
+			// Empty means both when trufflehog is installed, native otherwise.
+			Engine string   `yaml:"engine,omitempty"`
+			Rules  []string `yaml:"rules,omitempty"` // extra rule files for the native scanner
+			// SourceMaps selects which source maps are unpacked and analysed: "probe" (the
+			// default) follows the references of scripts and tries <script>.map otherwise,
+			// "referenced" only follows references and "off" skips source maps.
+			SourceMaps       string `yaml:"source_maps,omitempty"`
 			TrufflehogConfig string `yaml:"trufflehog_config,omitempty"`
 		}{
 			TrufflehogConfig: "",
 		},
 		Reporting: struct {
-			Format string `yaml:"format,omitempty"` // "md", "json", "html"
+			Format string `yaml:"format,omitempty"` // "md", "json", "html", "sarif"
 		}{
 			Format: "md",
 		},
+		Pipeline: struct {
+			Concurrency int `yaml:"concurrency,omitempty"` // Max modules running at once
+		}{
+			Concurrency: 3,
+		},
 	}
 
 	data, err := yaml.Marshal(cfg)
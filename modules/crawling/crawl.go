@@ -10,10 +10,10 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 
 	"sentinel/modules/config"
 	"sentinel/modules/database"
+	"sentinel/modules/scope"
 	"sentinel/modules/utils"
 
 	"github.com/fatih/color"
@@ -26,17 +26,26 @@ type KatanaOutput struct {
 	} `json:"request"`
 }
 
-func RunCrawl(ctx context.Context, config *config.Config, db *sql.DB) {
+// RunCrawl crawls every live URL with katana and stores newly found endpoints.
+func RunCrawl(ctx context.Context, config *config.Config, db *sql.DB) error {
 	options := utils.Options{
-		Output:  config.Workspace,
-		Threads: config.Recon.Threads, // Not used by crawl, but good for consistency
+		Output:    config.Workspace,
+		Threads:   config.Recon.Threads, // Not used by crawl, but good for consistency
+		RateLimit: config.RateLimit,
+		Proxy:     config.Proxy,
 	}
 	color.Cyan("[*] Starting Crawling phase")
 
+	sc, err := scope.New(config)
+	if err != nil {
+		color.Red("Invalid scope configuration: %v", err)
+		return err
+	}
+
 	if !utils.CommandExists("katana") {
 		color.Red("katana not found. Please install it first.")
 		color.Yellow("Hint: go install github.com/projectdiscovery/katana/cmd/katana@latest")
-		return
+		return fmt.Errorf("katana not found")
 	}
 
 	tempDir := filepath.Join(options.Output, "temp")
@@ -51,19 +60,20 @@ func RunCrawl(ctx context.Context, config *config.Config, db *sql.DB) {
 	urls, err := database.GetLiveURLs(db)
 	if err != nil {
 		color.Red("Error getting URLs from database: %v", err)
-		return
+		return err
 	}
 
+	urls = sc.Filter("katana input", urls)
 	if len(urls) == 0 {
 		color.Yellow("No live URLs found in the database to crawl.")
-		return
+		return nil
 	}
 	color.Green("Found %d live URLs to crawl.", len(urls))
 
 	file, err := os.Create(katanaInputFile)
 	if err != nil {
 		color.Red("Error creating input file for katana: %v", err)
-		return
+		return err
 	}
 	for _, u := range urls {
 		fmt.Fprintln(file, u)
@@ -73,12 +83,12 @@ func RunCrawl(ctx context.Context, config *config.Config, db *sql.DB) {
 	absInputFile, err := filepath.Abs(katanaInputFile)
 	if err != nil {
 		color.Red("Error getting absolute path for katana input: %v", err)
-		return
+		return err
 	}
 	absOutputFile, err := filepath.Abs(katanaOutputFile)
 	if err != nil {
 		color.Red("Error getting absolute path for katana output: %v", err)
-		return
+		return err
 	}
 
 	utils.Banner("Running katana against live URLs")
@@ -87,20 +97,26 @@ func RunCrawl(ctx context.Context, config *config.Config, db *sql.DB) {
 	if crawlDepth == "0" {
 		crawlDepth = "2" // Default if not set
 	}
-	utils.RunCommand(ctx, options, "katana", "-list", absInputFile, "-json", "-depth", crawlDepth, "-o", absOutputFile)
+	if err := utils.RunCommand(ctx, options, "katana", "-list", absInputFile, "-json", "-depth", crawlDepth, "-o", absOutputFile); err != nil {
+		// katana may still have written partial results, so we keep going and parse what we have.
+		utils.Warn(fmt.Sprintf("katana exited with an error: %v", err))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
 
 	utils.Banner("Parsing katana output and adding new URLs to database")
 	targets, err := database.GetTargets(db)
 	if err != nil {
 		color.Red("Error getting targets from database: %v", err)
-		return
+		return err
 	}
 
 	outputFile, err := os.Open(absOutputFile)
 	if err != nil {
 		// It's possible katana found nothing, so the file might not exist.
 		color.Yellow("No katana output file found. Skipping parsing.")
-		return
+		return nil
 	}
 	defer outputFile.Close()
 
@@ -111,20 +127,16 @@ func RunCrawl(ctx context.Context, config *config.Config, db *sql.DB) {
 		line := scanner.Text()
 		if err := json.Unmarshal([]byte(line), &katanaOut); err == nil {
 			newURL := katanaOut.Request.Endpoint
-			var associatedTargetID int = -1
 
 			parsedNewUrl, err := url.Parse(newURL)
 			if err != nil {
 				continue
 			}
-
-			for id, targetDomain := range targets {
-				if strings.HasSuffix(parsedNewUrl.Hostname(), targetDomain) {
-					associatedTargetID = id
-					break
-				}
+			if !sc.Allow("katana", newURL) {
+				continue
 			}
 
+			associatedTargetID := scope.TargetFor(parsedNewUrl.Hostname(), targets)
 			if associatedTargetID != -1 {
 				if _, err := database.AddURL(db, associatedTargetID, newURL, "katana"); err == nil {
 					newURLsFound++
@@ -135,7 +147,9 @@ func RunCrawl(ctx context.Context, config *config.Config, db *sql.DB) {
 
 	if err := scanner.Err(); err != nil {
 		color.Red("Error reading katana output: %v", err)
+		return err
 	}
 
 	color.Green("Crawling phase completed. Found %d new URLs.", newURLsFound)
+	return nil
 }
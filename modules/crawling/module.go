@@ -0,0 +1,32 @@
+package crawling
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'crawl'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "crawl" }
+
+func (Module) Description() string {
+	return "Crawl discovered web services to find more endpoints"
+}
+
+func (Module) RequiredTools() []string { return []string{"katana"} }
+
+func (Module) Inputs() []string { return []string{registry.LiveURLs} }
+
+func (Module) Outputs() []string { return []string{registry.URLs} }
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunCrawl(ctx, cfg, db)
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Classification identifies the weakness behind a vulnerability: its CVE and
+// CWE IDs, its CVSS score and vector, and reference URLs. Nuclei templates
+// carry it; an offline NVD feed can fill in what they leave out.
+type Classification struct {
+	CVEIDs      []string `json:"cve_ids,omitempty"`
+	CWEIDs      []string `json:"cwe_ids,omitempty"`
+	CVSSScore   float64  `json:"cvss_score,omitempty"`
+	CVSSMetrics string   `json:"cvss_metrics,omitempty"`
+	References  []string `json:"references,omitempty"`
+}
+
+// cveID matches a CVE ID, which is also how nuclei names the templates of CVEs.
+var cveID = regexp.MustCompile(`(?i)^CVE-\d{4}-\d{4,}$`)
+
+// IsZero reports whether nothing is known of the classification.
+func (c Classification) IsZero() bool {
+	return len(c.CVEIDs) == 0 && len(c.CWEIDs) == 0 && c.CVSSScore == 0 && c.CVSSMetrics == "" && len(c.References) == 0
+}
+
+// Normalize upper-cases the CVE and CWE IDs and drops empty and duplicate entries.
+func (c Classification) Normalize() Classification {
+	c.CVEIDs = uniqueList(c.CVEIDs, true)
+	c.CWEIDs = uniqueList(c.CWEIDs, true)
+	c.References = uniqueList(c.References, false)
+	c.CVSSMetrics = strings.TrimSpace(c.CVSSMetrics)
+	return c
+}
+
+// Merge adds what o knows to c: IDs and references are combined, and o's CVSS
+// score and vector are only used when c has none.
+func (c Classification) Merge(o Classification) Classification {
+	merged := Classification{
+		CVEIDs:      append(append([]string{}, c.CVEIDs...), o.CVEIDs...),
+		CWEIDs:      append(append([]string{}, c.CWEIDs...), o.CWEIDs...),
+		CVSSScore:   c.CVSSScore,
+		CVSSMetrics: c.CVSSMetrics,
+		References:  append(append([]string{}, c.References...), o.References...),
+	}
+	if merged.CVSSScore == 0 && merged.CVSSMetrics == "" {
+		merged.CVSSScore, merged.CVSSMetrics = o.CVSSScore, o.CVSSMetrics
+	}
+	return merged.Normalize()
+}
+
+func uniqueList(items []string, upper bool) []string {
+	var list []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if upper {
+			item = strings.ToUpper(item)
+		}
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		list = append(list, item)
+	}
+	return list
+}
+
+// classificationColumns are the columns a Classification is stored in, in the
+// order of classificationValues and classificationScan.
+const classificationColumns = "cve_id, cwe_id, cvss_score, cvss_metrics, reference_urls"
+
+// classificationValues returns the column values of c. IDs are stored comma
+// separated and references one per line; unknown values are NULL.
+func classificationValues(c Classification) []any {
+	return []any{
+		nullString(strings.Join(c.CVEIDs, ",")),
+		nullString(strings.Join(c.CWEIDs, ",")),
+		sql.NullFloat64{Float64: c.CVSSScore, Valid: c.CVSSScore > 0},
+		nullString(c.CVSSMetrics),
+		nullString(strings.Join(c.References, "\n")),
+	}
+}
+
+// classificationScan holds the columns of a classification while a row is scanned.
+type classificationScan struct {
+	cve, cwe, metrics, refs sql.NullString
+	score                   sql.NullFloat64
+}
+
+func (s *classificationScan) dest() []any {
+	return []any{&s.cve, &s.cwe, &s.score, &s.metrics, &s.refs}
+}
+
+func (s *classificationScan) classification() Classification {
+	split := func(v sql.NullString, sep string) []string {
+		if v.String == "" {
+			return nil
+		}
+		return strings.Split(v.String, sep)
+	}
+	return Classification{
+		CVEIDs:      split(s.cve, ","),
+		CWEIDs:      split(s.cwe, ","),
+		CVSSScore:   s.score.Float64,
+		CVSSMetrics: s.metrics.String,
+		References:  split(s.refs, "\n"),
+	}
+}
+
+// setClassification stores what c knows of a vulnerability, leaving the
+// columns it knows nothing of alone.
+func setClassification(db *sql.DB, urlID int, templateID string, c Classification) error {
+	if c.IsZero() {
+		return nil
+	}
+	args := append(classificationValues(c.Normalize()), urlID, templateID)
+	_, err := db.Exec(`UPDATE vulnerabilities SET cve_id = COALESCE(?, cve_id), cwe_id = COALESCE(?, cwe_id),
+			cvss_score = COALESCE(?, cvss_score), cvss_metrics = COALESCE(?, cvss_metrics), reference_urls = COALESCE(?, reference_urls)
+		WHERE url_id = ? AND template_id = ?`, args...)
+	return err
+}
+
+// GetClassification returns the classification of a vulnerability.
+func GetClassification(db *sql.DB, id int64) (Classification, error) {
+	var s classificationScan
+	if err := db.QueryRow("SELECT "+classificationColumns+" FROM vulnerabilities WHERE id = ?", id).Scan(s.dest()...); err != nil {
+		return Classification{}, err
+	}
+	return s.classification(), nil
+}
+
+// CVEVulnerability is a vulnerability known to be about one or more CVEs.
+type CVEVulnerability struct {
+	ID         int64
+	TemplateID string
+	Classification
+}
+
+// VulnerabilityCVEs returns the vulnerabilities with CVE IDs, including those
+// of CVE templates that came without a classification, whose template ID is
+// taken as their CVE ID.
+func VulnerabilityCVEs(db *sql.DB) ([]CVEVulnerability, error) {
+	rows, err := db.Query(`SELECT id, template_id, ` + classificationColumns + ` FROM vulnerabilities
+		WHERE cve_id IS NOT NULL OR template_id LIKE 'CVE-%' ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("could not query vulnerabilities: %w", err)
+	}
+	defer rows.Close()
+
+	var vulns []CVEVulnerability
+	for rows.Next() {
+		var v CVEVulnerability
+		var s classificationScan
+		if err := rows.Scan(append([]any{&v.ID, &v.TemplateID}, s.dest()...)...); err != nil {
+			return nil, err
+		}
+		v.Classification = s.classification()
+		if len(v.CVEIDs) == 0 && cveID.MatchString(v.TemplateID) {
+			v.CVEIDs = []string{strings.ToUpper(v.TemplateID)}
+		}
+		if len(v.CVEIDs) > 0 {
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns, rows.Err()
+}
+
+// EnrichVulnerability merges c into the classification of a vulnerability and
+// reports whether that added anything.
+func EnrichVulnerability(db *sql.DB, id int64, c Classification) (bool, error) {
+	current, err := GetClassification(db, id)
+	if err != nil {
+		return false, err
+	}
+	current = current.Normalize()
+	merged := current.Merge(c)
+	if reflect.DeepEqual(merged, current) {
+		return false, nil
+	}
+	args := append(classificationValues(merged), id)
+	_, err = db.Exec(`UPDATE vulnerabilities SET cve_id = ?, cwe_id = ?, cvss_score = ?, cvss_metrics = ?, reference_urls = ?
+		WHERE id = ?`, args...)
+	return err == nil, err
+}
@@ -2,18 +2,32 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"sentinel/modules/config"
+	"sentinel/modules/utils"
+	"sentinel/modules/vault"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// FileName is the name of the database file in a workspace directory.
+const FileName = "sentinel.db"
+
 // InitDB initializes a new SQLite database for the given workspace.
 func InitDB(config *config.Config) (*sql.DB, error) {
+	return Open(config.Workspace)
+}
+
+// Open opens the database of the workspace directory, creating and migrating
+// it as needed.
+func Open(workspace string) (*sql.DB, error) {
 	// The database will be stored in the workspace directory. We resolve it to an absolute path.
-	workspacePath, err := filepath.Abs(config.Workspace)
+	workspacePath, err := filepath.Abs(workspace)
 	if err != nil {
 		return nil, fmt.Errorf("could not resolve absolute path for workspace: %w", err)
 	}
@@ -23,99 +37,28 @@ func InitDB(config *config.Config) (*sql.DB, error) {
 		return nil, fmt.Errorf("could not create workspace directory '%s': %w", workspacePath, err)
 	}
 
-	dbPath := filepath.Join(workspacePath, "sentinel.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	dbPath := filepath.Join(workspacePath, FileName)
+	// Pipeline stages write concurrently, so wait on locks instead of failing and use WAL
+	// so readers don't block writers.
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=10000&_journal_mode=WAL")
 	if err != nil {
 		return nil, fmt.Errorf("could not open database: %w", err)
 	}
 
-	if err = CreateTables(db); err != nil {
-		return nil, fmt.Errorf("could not create tables: %w", err)
+	if err = Migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate database schema: %w", err)
 	}
 
-	return db, nil
-}
-
-// CreateTables sets up the initial schema for the database.
-func CreateTables(db *sql.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS targets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			target TEXT NOT NULL UNIQUE
-		);`,
-		`CREATE TABLE IF NOT EXISTS subdomains (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			target_id INTEGER,
-			subdomain TEXT NOT NULL UNIQUE,
-			FOREIGN KEY(target_id) REFERENCES targets(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS ips (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			subdomain_id INTEGER,
-			ip_address TEXT NOT NULL,
-			FOREIGN KEY(subdomain_id) REFERENCES subdomains(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS ports (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			ip_id INTEGER,
-			port INTEGER NOT NULL,
-			service TEXT,
-			UNIQUE(ip_id, port),
-			FOREIGN KEY(ip_id) REFERENCES ips(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS urls (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			target_id INTEGER,
-			url TEXT NOT NULL UNIQUE,
-			source TEXT,
-			status_code INTEGER,
-			title TEXT,
-			tech TEXT,
-			screenshot_path TEXT,
-			FOREIGN KEY(target_id) REFERENCES targets(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS vulnerabilities (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			url_id INTEGER,
-			template_id TEXT NOT NULL,
-			name TEXT NOT NULL,
-			severity TEXT,
-			description TEXT,
-			UNIQUE(url_id, template_id),
-			FOREIGN KEY (url_id) REFERENCES urls(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS exploits (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			vulnerability_id INTEGER,
-			title TEXT NOT NULL,
-			edb_id TEXT,
-			path TEXT,
-			FOREIGN KEY (vulnerability_id) REFERENCES vulnerabilities(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS secrets (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			url_id INTEGER,
-			type TEXT NOT NULL,
-			value TEXT NOT NULL,
-			source TEXT NOT NULL,
-			FOREIGN KEY (url_id) REFERENCES urls(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS parameters (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			url_id INTEGER,
-			name TEXT NOT NULL,
-			source TEXT NOT NULL,
-			UNIQUE(url_id, name),
-			FOREIGN KEY (url_id) REFERENCES urls(id)
-		);`,
-	}
-
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return err
+	// Secrets stored in clear text by older versions are encrypted right away.
+	if n, err := PlaintextSecrets(db); err == nil && n > 0 {
+		if _, err := SecretKey(db, workspacePath); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("could not encrypt stored secrets: %w", err)
 		}
 	}
-	return nil
+
+	return db, nil
 }
 
 // AddTarget adds a new target to the database.
@@ -124,70 +67,104 @@ func AddTarget(db *sql.DB, target string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	id, _ := result.LastInsertId()
-	if id == 0 {
-		// If LastInsertId is 0, it means the target already existed. Get its ID.
-		db.QueryRow("SELECT id FROM targets WHERE target = ?", target).Scan(&id)
+	if n, _ := result.RowsAffected(); n > 0 {
+		return result.LastInsertId()
 	}
-	return id, nil
+	// The target already existed. LastInsertId is not reset by an ignored insert, so look it up.
+	var id int64
+	err = db.QueryRow("SELECT id FROM targets WHERE target = ?", target).Scan(&id)
+	return id, err
 }
 
-// AddSubdomain adds a new subdomain to the database.
-func AddSubdomain(db *sql.DB, targetID int64, subdomain string) (int64, error) {
-	result, err := db.Exec("INSERT OR IGNORE INTO subdomains (target_id, subdomain) VALUES (?, ?)", targetID, subdomain)
-	if err != nil {
-		return 0, err
-	}
-	id, _ := result.LastInsertId()
-	if id == 0 {
-		db.QueryRow("SELECT id FROM subdomains WHERE subdomain = ?", subdomain).Scan(&id)
-	}
-	return id, nil
+// AddSubdomain adds a new subdomain to the database, or marks an existing one as seen again.
+// The source is the tool (or import) that found it first.
+func AddSubdomain(db *sql.DB, targetID int64, subdomain, source string) (int64, error) {
+	id, _, err := addSeen(db, AssetSubdomain,
+		"INSERT OR IGNORE INTO subdomains (target_id, subdomain, source, first_seen, last_seen) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+		[]any{targetID, subdomain, source},
+		"SELECT id, removed_at FROM subdomains WHERE subdomain = ?", subdomain)
+	return id, err
 }
 
-// AddIP adds a new IP address for a subdomain.
-func AddIP(db *sql.DB, subdomainID int64, ip string) (int64, error) {
-	result, err := db.Exec("INSERT OR IGNORE INTO ips (subdomain_id, ip_address) VALUES (?, ?)", subdomainID, ip)
-	if err != nil {
-		return 0, err
-	}
-	id, _ := result.LastInsertId()
-	if id == 0 {
-		db.QueryRow("SELECT id FROM ips WHERE ip_address = ? AND subdomain_id = ?", ip, subdomainID).Scan(&id)
-	}
-	return id, nil
+// AddIP adds a new IP address for a subdomain, or marks an existing one as seen again.
+func AddIP(db *sql.DB, subdomainID int64, ip, source string) (int64, error) {
+	id, _, err := addSeen(db, AssetIP,
+		"INSERT OR IGNORE INTO ips (subdomain_id, ip_address, source, first_seen, last_seen) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+		[]any{subdomainID, ip, source},
+		"SELECT id, removed_at FROM ips WHERE ip_address = ? AND subdomain_id = ?", ip, subdomainID)
+	return id, err
 }
 
-// AddPort adds a new open port for an IP address.
-func AddPort(db *sql.DB, ipID int64, port int, service string) (int64, error) {
-	result, err := db.Exec("INSERT OR IGNORE INTO ports (ip_id, port, service) VALUES (?, ?, ?)", ipID, port, service)
-	if err != nil {
-		return 0, err
-	}
-	id, _ := result.LastInsertId()
-	if id == 0 {
-		db.QueryRow("SELECT id FROM ports WHERE ip_id = ? AND port = ?", ipID, port).Scan(&id)
+// AddPort adds a new open port for an IP address, or marks an existing one as seen again.
+func AddPort(db *sql.DB, ipID int64, port int, service, source string) (int64, error) {
+	var svc sql.NullString
+	if service != "" {
+		svc = sql.NullString{String: service, Valid: true}
 	}
-	return id, nil
+	id, _, err := addSeen(db, AssetPort,
+		"INSERT OR IGNORE INTO ports (ip_id, port, service, source, first_seen, last_seen) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+		[]any{ipID, port, svc, source},
+		"SELECT id, removed_at FROM ports WHERE ip_id = ? AND port = ?", ipID, port)
+	return id, err
 }
 
 // AddURL adds a new URL to the database if it doesn't already exist.
+// Liveness, and therefore URL history, is tracked by UpdateURLDetails.
 func AddURL(db *sql.DB, targetID int, url string, source string) (int64, error) {
-	result, err := db.Exec("INSERT OR IGNORE INTO urls (target_id, url, source) VALUES (?, ?, ?)", targetID, url, source)
+	result, err := db.Exec("INSERT OR IGNORE INTO urls (target_id, url, source, first_seen, last_seen) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+		targetID, url, source)
 	if err != nil {
 		return 0, err
 	}
-	id, _ := result.LastInsertId()
-	if id == 0 {
-		db.QueryRow("SELECT id FROM urls WHERE url = ?", url).Scan(&id)
+	if n, _ := result.RowsAffected(); n > 0 {
+		return result.LastInsertId()
 	}
-	return id, nil
+	var id int64
+	if err := db.QueryRow("SELECT id FROM urls WHERE url = ?", url).Scan(&id); err != nil {
+		return 0, err
+	}
+	_, err = db.Exec("UPDATE urls SET last_seen = CURRENT_TIMESTAMP WHERE id = ?", id)
+	return id, err
 }
 
 // UpdateURLDetails updates the status code, title, and tech for a given URL.
+// A positive status code marks the URL as live; if it was not live before, an
+// "added" event is recorded for it.
 func UpdateURLDetails(db *sql.DB, url, title, tech string, statusCode int) error {
-	_, err := db.Exec("UPDATE urls SET status_code = ?, title = ?, tech = ? WHERE url = ?", statusCode, title, tech, url)
-	return err
+	var id int64
+	var oldStatus sql.NullInt64
+	var removedAt sql.NullString
+	err := db.QueryRow("SELECT id, status_code, removed_at FROM urls WHERE url = ?", url).Scan(&id, &oldStatus, &removedAt)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE urls SET status_code = ?, title = ?, tech = ? WHERE id = ?", statusCode, title, tech, id); err != nil {
+		return err
+	}
+	if statusCode <= 0 {
+		return nil
+	}
+	if _, err := db.Exec("UPDATE urls SET last_seen = CURRENT_TIMESTAMP, removed_at = NULL WHERE id = ?", id); err != nil {
+		return err
+	}
+	if oldStatus.Int64 <= 0 || removedAt.Valid {
+		return recordEvent(db, AssetURL, id, EventAdded)
+	}
+	return nil
+}
+
+// SetURLStatusIfUnprobed records a status code seen outside of Sentinel (e.g.
+// in an imported proxy history) for a URL that has not been probed yet. The
+// results of a probe are never overwritten.
+func SetURLStatusIfUnprobed(db *sql.DB, url string, statusCode int) error {
+	var status sql.NullInt64
+	if err := db.QueryRow("SELECT status_code FROM urls WHERE url = ?", url).Scan(&status); err != nil {
+		return err
+	}
+	if status.Int64 > 0 || statusCode <= 0 {
+		return nil
+	}
+	return UpdateURLDetails(db, url, "", "", statusCode)
 }
 
 // UpdateURLScreenshotPath updates the screenshot path for a given URL.
@@ -196,11 +173,37 @@ func UpdateURLScreenshotPath(db *sql.DB, url, path string) error {
 	return err
 }
 
-// AddVulnerability adds a new vulnerability to the database.
-func AddVulnerability(db *sql.DB, urlID int, templateID, name, severity, description string) error {
-	_, err := db.Exec("INSERT OR IGNORE INTO vulnerabilities (url_id, template_id, name, severity, description) VALUES (?, ?, ?, ?, ?)",
-		urlID, templateID, name, severity, description)
-	return err
+// AddVulnerability adds a new vulnerability to the database, or marks an existing one as seen again.
+// It reports whether the vulnerability is new (or had been marked removed or fixed).
+// Findings triaged as false positives or accepted risks only have their last_seen
+// refreshed: they stay suppressed and are never reported as new again.
+// The classification is refreshed with what class knows on every sighting.
+func AddVulnerability(db *sql.DB, urlID int, templateID, name, severity, description, source string, class Classification) (bool, error) {
+	var status string
+	err := db.QueryRow("SELECT status FROM vulnerabilities WHERE url_id = ? AND template_id = ?", urlID, templateID).Scan(&status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if Suppressed(status) {
+		if _, err := db.Exec("UPDATE vulnerabilities SET last_seen = CURRENT_TIMESTAMP WHERE url_id = ? AND template_id = ?", urlID, templateID); err != nil {
+			return false, err
+		}
+		return false, setClassification(db, urlID, templateID, class)
+	}
+	id, added, err := addSeen(db, AssetVulnerability,
+		`INSERT OR IGNORE INTO vulnerabilities (url_id, template_id, name, severity, description, source, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		[]any{urlID, templateID, name, severity, description, source},
+		"SELECT id, removed_at FROM vulnerabilities WHERE url_id = ? AND template_id = ?", urlID, templateID)
+	if err == nil && status == StatusFixed {
+		// A fixed finding that is reported again has regressed, so it is reopened.
+		_, err = db.Exec("UPDATE vulnerabilities SET status = ? WHERE id = ?", StatusNew, id)
+		added = true
+	}
+	if err == nil {
+		err = setClassification(db, urlID, templateID, class)
+	}
+	return added, err
 }
 
 // AddExploit adds a new exploit to the database.
@@ -209,15 +212,83 @@ func AddExploit(db *sql.DB, vulnID int, title, edbID, path string) error {
 	return err
 }
 
-// AddSecret adds a new discovered secret to the database.
-func AddSecret(db *sql.DB, urlID int, secretType, value, source string) error {
-	_, err := db.Exec("INSERT INTO secrets (url_id, type, value, source) VALUES (?, ?, ?, ?)", urlID, secretType, value, source)
+// AddSecret adds a new discovered secret to the database, or marks a known one as seen again.
+// The value is stored encrypted with key, along with its fingerprint and redacted form.
+// A value triaged as a false positive or accepted risk stays suppressed wherever it
+// turns up again, and a fixed secret that is found again is reopened.
+// ruleID, line and context locate the secret when the scanner reports them; they
+// are empty or 0 otherwise, and refreshed on every sighting. context must not
+// contain the secret in clear text.
+func AddSecret(db *sql.DB, key *vault.Key, urlID int, secretType, value, source, ruleID string, line int, context string) (bool, error) {
+	sealed, err := key.Encrypt(value)
+	if err != nil {
+		return false, fmt.Errorf("could not encrypt secret: %w", err)
+	}
+	fingerprint := key.Fingerprint(value)
+
+	// The triage of the same value elsewhere, if it was suppressed.
+	status, notes, triagedAt := StatusNew, sql.NullString{}, sql.NullString{}
+	var suppressedID int64
+	err = db.QueryRow(`SELECT id, status, notes FROM secrets WHERE type = ? AND fingerprint = ? AND status IN (?, ?)
+		ORDER BY triaged_at DESC LIMIT 1`, secretType, fingerprint, StatusFalsePositive, StatusAcceptedRisk).Scan(&suppressedID, &status, &notes)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if suppressedID != 0 {
+		notes = sql.NullString{String: strings.TrimSpace(fmt.Sprintf("Same value as secret %d. %s", suppressedID, notes.String)), Valid: true}
+		triagedAt = sql.NullString{String: sqlTime(time.Now()), Valid: true}
+	}
+
+	rule, ln, ctx := nullString(ruleID), sql.NullInt64{Int64: int64(line), Valid: line > 0}, nullString(context)
+	result, err := db.Exec(`INSERT OR IGNORE INTO secrets (url_id, type, value, fingerprint, redacted, source, rule_id, line, context, status, notes, triaged_at, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		urlID, secretType, sealed, fingerprint, utils.Redact(value), source, rule, ln, ctx, status, notes, triagedAt)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return !Suppressed(status), nil
+	}
+	var current string
+	if err := db.QueryRow("SELECT status FROM secrets WHERE url_id = ? AND type = ? AND fingerprint = ?", urlID, secretType, fingerprint).Scan(&current); err != nil {
+		return false, err
+	}
+	// A fixed secret that is found again has not been rotated after all.
+	reopened := current == StatusFixed
+	if reopened {
+		current = StatusNew
+	}
+	_, err = db.Exec(`UPDATE secrets SET last_seen = CURRENT_TIMESTAMP, status = ?,
+			rule_id = COALESCE(?, rule_id), line = COALESCE(?, line), context = COALESCE(?, context)
+		WHERE url_id = ? AND type = ? AND fingerprint = ?`, current, rule, ln, ctx, urlID, secretType, fingerprint)
+	return reopened, err
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// AddSourceMap records the source map of the JavaScript file urlID, how many
+// sources it holds and where they were unpacked.
+func AddSourceMap(db *sql.DB, urlID int, mapURL string, sources int, path string) error {
+	_, err := db.Exec(`INSERT INTO source_maps (url_id, map_url, sources, path, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT(url_id, map_url) DO UPDATE SET sources = excluded.sources, path = excluded.path, last_seen = CURRENT_TIMESTAMP`,
+		urlID, mapURL, sources, path)
 	return err
 }
 
 // AddParameter adds a new discovered parameter for a URL.
 func AddParameter(db *sql.DB, urlID int, name, source string) error {
-	_, err := db.Exec("INSERT OR IGNORE INTO parameters (url_id, name, source) VALUES (?, ?, ?)", urlID, name, source)
+	result, err := db.Exec("INSERT OR IGNORE INTO parameters (url_id, name, source, first_seen, last_seen) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)",
+		urlID, name, source)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		_, err = db.Exec("UPDATE parameters SET last_seen = CURRENT_TIMESTAMP WHERE url_id = ? AND name = ?", urlID, name)
+	}
 	return err
 }
 
@@ -279,9 +350,9 @@ func GetTargets(db *sql.DB) (map[int]string, error) {
 	return targets, nil
 }
 
-// GetLiveURLs retrieves all URLs with a positive status code from the database.
+// GetLiveURLs retrieves all URLs with a positive status code that have not since gone away.
 func GetLiveURLs(db *sql.DB) ([]string, error) {
-	rows, err := db.Query("SELECT url FROM urls WHERE status_code > 0")
+	rows, err := db.Query("SELECT url FROM urls WHERE status_code > 0 AND removed_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -300,7 +371,7 @@ func GetLiveURLs(db *sql.DB) ([]string, error) {
 
 // GetLiveURLsAsMap retrieves all URLs with a positive status code from the database as a map[url]id.
 func GetLiveURLsAsMap(db *sql.DB) (map[string]int, error) {
-	rows, err := db.Query("SELECT id, url FROM urls WHERE status_code > 0")
+	rows, err := db.Query("SELECT id, url FROM urls WHERE status_code > 0 AND removed_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -320,7 +391,7 @@ func GetLiveURLsAsMap(db *sql.DB) (map[string]int, error) {
 
 // GetJavaScriptURLs retrieves all JS file URLs from the database.
 func GetJavaScriptURLs(db *sql.DB) (map[int]string, error) {
-	rows, err := db.Query("SELECT id, url FROM urls WHERE url LIKE '%.js' AND status_code > 0")
+	rows, err := db.Query("SELECT id, url FROM urls WHERE url LIKE '%.js' AND status_code > 0 AND removed_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -355,4 +426,63 @@ func GetTargetStrings(db *sql.DB) ([]string, error) {
 		targets = append(targets, target)
 	}
 	return targets, nil
-}
\ No newline at end of file
+}
+
+// CountAssets returns the number of present subdomains, IPs, ports, URLs and
+// vulnerabilities and of secrets, keyed by the name of their view.
+func CountAssets(db *sql.DB) (map[string]int, error) {
+	queries := map[string]string{
+		"subdomains": "SELECT COUNT(*) FROM subdomains WHERE removed_at IS NULL",
+		"ips":        "SELECT COUNT(*) FROM ips WHERE removed_at IS NULL",
+		"ports":      "SELECT COUNT(*) FROM ports WHERE removed_at IS NULL",
+		"urls":       "SELECT COUNT(*) FROM urls WHERE removed_at IS NULL",
+		"vulns":      "SELECT COUNT(*) FROM vulnerabilities WHERE removed_at IS NULL",
+		"secrets":    "SELECT COUNT(*) FROM secrets",
+	}
+	counts := make(map[string]int, len(queries))
+	for name, query := range queries {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			return nil, fmt.Errorf("could not count %s: %w", name, err)
+		}
+		counts[name] = n
+	}
+	return counts, nil
+}
+
+// RelocateWorkspace rewrites the stored paths of files in the workspace, such
+// as screenshots and unpacked source maps, after its directory moved from
+// "from" to "to".
+func RelocateWorkspace(db *sql.DB, from, to string) error {
+	from, to = filepath.Clean(from)+string(filepath.Separator), filepath.Clean(to)+string(filepath.Separator)
+	for _, c := range []struct{ table, column string }{{"urls", "screenshot_path"}, {"source_maps", "path"}} {
+		query := fmt.Sprintf("UPDATE %[1]s SET %[2]s = ? || substr(%[2]s, ?) WHERE substr(%[2]s, 1, ?) = ?", c.table, c.column)
+		if _, err := db.Exec(query, to, len(from)+1, len(from), from); err != nil {
+			return fmt.Errorf("could not relocate %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// GetVulnerabilityCounts returns the number of open vulnerabilities per severity,
+// leaving out false positives, accepted risks, fixed findings and those that
+// are no longer seen, as the reports do.
+func GetVulnerabilityCounts(db *sql.DB) (map[string]int, error) {
+	rows, err := db.Query("SELECT LOWER(COALESCE(severity, '')), COUNT(*) FROM vulnerabilities WHERE status NOT IN (?, ?, ?) AND removed_at IS NULL GROUP BY 1",
+		StatusFalsePositive, StatusAcceptedRisk, StatusFixed)
+	if err != nil {
+		return nil, fmt.Errorf("could not count vulnerabilities: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var severity string
+		var count int
+		if err := rows.Scan(&severity, &count); err != nil {
+			return nil, fmt.Errorf("could not scan vulnerability count: %w", err)
+		}
+		counts[severity] = count
+	}
+	return counts, nil
+}
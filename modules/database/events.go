@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Kinds of change events raised after monitored runs.
+const (
+	ChangeNewSubdomain     = "new_subdomain"
+	ChangeNewPort          = "new_port"
+	ChangeNewVulnerability = "new_vulnerability"
+)
+
+// changeKinds maps the asset additions that are worth raising to their event kind.
+var changeKinds = map[string]string{
+	AssetSubdomain:     ChangeNewSubdomain,
+	AssetPort:          ChangeNewPort,
+	AssetVulnerability: ChangeNewVulnerability,
+}
+
+// ChangeEvent is a notable change detected by a run, such as a new subdomain.
+type ChangeEvent struct {
+	ID        int64     `json:"id"`
+	RunID     int64     `json:"run_id"`
+	Kind      string    `json:"kind"`
+	Asset     string    `json:"asset"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordChangeEvents derives change events from the assets added after the
+// asset event sinceEventID (see LastAssetEventID) and stores them against a
+// run. It returns the recorded events.
+func RecordChangeEvents(db *sql.DB, runID, sinceEventID int64) ([]ChangeEvent, error) {
+	changes, err := DiffAssetsSince(db, sinceEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ChangeEvent
+	for _, c := range changes {
+		kind, ok := changeKinds[c.Type]
+		if !ok || c.Change != EventAdded {
+			continue
+		}
+		result, err := db.Exec("INSERT INTO change_events (run_id, kind, asset, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)", runID, kind, c.Asset)
+		if err != nil {
+			return events, fmt.Errorf("could not record change event: %w", err)
+		}
+		id, _ := result.LastInsertId()
+		events = append(events, ChangeEvent{ID: id, RunID: runID, Kind: kind, Asset: c.Asset, CreatedAt: time.Now().UTC()})
+	}
+	return events, nil
+}
@@ -0,0 +1,350 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Asset types tracked in asset_events. A "url" asset is a live URL: it is added
+// when a probe first gets a response from it and removed when it stops answering.
+const (
+	AssetSubdomain     = "subdomain"
+	AssetIP            = "ip"
+	AssetPort          = "port"
+	AssetURL           = "url"
+	AssetVulnerability = "vulnerability"
+)
+
+// ImportSourcePrefix starts the source of every imported asset. Imported
+// assets are never marked removed because a scan did not find them again.
+const ImportSourcePrefix = "import:"
+
+// notImported returns the condition excluding imported assets from stale
+// checks, for the source column of the given table alias (or "").
+func notImported(alias string) string {
+	if alias != "" {
+		alias += "."
+	}
+	return "COALESCE(" + alias + "source, '') NOT LIKE '" + ImportSourcePrefix + "%'"
+}
+
+// Asset events stored in asset_events.event.
+const (
+	EventAdded   = "added"
+	EventRemoved = "removed"
+)
+
+// assetTypes lists the tracked asset types in the order they are reported.
+var assetTypes = []string{AssetSubdomain, AssetIP, AssetPort, AssetURL, AssetVulnerability}
+
+var assetTables = map[string]string{
+	AssetSubdomain:     "subdomains",
+	AssetIP:            "ips",
+	AssetPort:          "ports",
+	AssetURL:           "urls",
+	AssetVulnerability: "vulnerabilities",
+}
+
+// assetLabels render the human readable name of an asset from its ID.
+var assetLabels = map[string]string{
+	AssetSubdomain: "SELECT subdomain FROM subdomains WHERE id = ?",
+	AssetIP:        "SELECT i.ip_address || ' (' || COALESCE(s.subdomain, '') || ')' FROM ips i LEFT JOIN subdomains s ON i.subdomain_id = s.id WHERE i.id = ?",
+	AssetPort:      "SELECT i.ip_address || ':' || p.port FROM ports p JOIN ips i ON p.ip_id = i.id WHERE p.id = ?",
+	AssetURL:       "SELECT url FROM urls WHERE id = ?",
+	AssetVulnerability: `SELECT '[' || COALESCE(v.severity, 'unknown') || '] ' || v.template_id || ' @ ' || COALESCE(u.url, '')
+		FROM vulnerabilities v LEFT JOIN urls u ON v.url_id = u.id WHERE v.id = ?`,
+}
+
+// AssetChange is a single difference between two points in the history of a workspace.
+type AssetChange struct {
+	Type   string    `json:"type"`
+	Asset  string    `json:"asset"`
+	Change string    `json:"change"`
+	At     time.Time `json:"at"`
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// sqlTime formats t the way SQLite's CURRENT_TIMESTAMP does, so the two compare correctly.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func recordEvent(db execer, assetType string, id int64, event string) error {
+	_, err := db.Exec(fmt.Sprintf(`INSERT INTO asset_events (asset_type, asset_id, asset, event, created_at)
+		SELECT ?, ?, COALESCE((%s), ''), ?, CURRENT_TIMESTAMP`, assetLabels[assetType]), assetType, id, id, event)
+	return err
+}
+
+// addSeen inserts an asset (the insert is expected to be an INSERT OR IGNORE
+// that sets first_seen and last_seen) or, if it already exists, refreshes its
+// last_seen. It reports whether the asset is new or had been marked removed,
+// in which case an "added" event is recorded.
+func addSeen(db *sql.DB, assetType, insert string, insertArgs []any, lookup string, lookupArgs ...any) (int64, bool, error) {
+	result, err := db.Exec(insert, insertArgs...)
+	if err != nil {
+		return 0, false, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, false, err
+		}
+		return id, true, recordEvent(db, assetType, id, EventAdded)
+	}
+
+	var id int64
+	var removedAt sql.NullString
+	if err := db.QueryRow(lookup, lookupArgs...).Scan(&id, &removedAt); err != nil {
+		return 0, false, err
+	}
+	table := assetTables[assetType]
+	if _, err := db.Exec("UPDATE "+table+" SET last_seen = CURRENT_TIMESTAMP, removed_at = NULL WHERE id = ?", id); err != nil {
+		return id, false, err
+	}
+	if removedAt.Valid {
+		return id, true, recordEvent(db, assetType, id, EventAdded)
+	}
+	return id, false, nil
+}
+
+// markRemoved flags assets as no longer present and records a "removed" event for each.
+func markRemoved(db *sql.DB, assetType string, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	table := assetTables[assetType]
+	removed := 0
+	for _, id := range ids {
+		result, err := tx.Exec("UPDATE "+table+" SET removed_at = CURRENT_TIMESTAMP WHERE id = ? AND removed_at IS NULL", id)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		if err := recordEvent(tx, assetType, id, EventRemoved); err != nil {
+			return 0, err
+		}
+		removed++
+	}
+	return removed, tx.Commit()
+}
+
+func queryIDs(db *sql.DB, query string, args ...any) ([]int64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkStaleSubdomains marks the subdomains of a target that were not seen since
+// the given time as removed. Only subdomains first found by one of sources are
+// considered, so that those of a source that did not run are left alone. It
+// returns how many were marked.
+func MarkStaleSubdomains(db *sql.DB, targetID int64, since time.Time, sources []string) (int, error) {
+	if len(sources) == 0 {
+		return 0, nil
+	}
+	args := []any{targetID, sqlTime(since)}
+	for _, source := range sources {
+		args = append(args, source)
+	}
+	ids, err := queryIDs(db, "SELECT id FROM subdomains WHERE target_id = ? AND removed_at IS NULL AND COALESCE(last_seen, '') < ? AND source IN (?"+
+		strings.Repeat(", ?", len(sources)-1)+") AND "+notImported(""), args...)
+	if err != nil {
+		return 0, err
+	}
+	return markRemoved(db, AssetSubdomain, ids)
+}
+
+// MarkStaleIPs marks the IPs of a target's subdomains that were not seen since the given time as removed.
+func MarkStaleIPs(db *sql.DB, targetID int64, since time.Time) (int, error) {
+	ids, err := queryIDs(db, `SELECT i.id FROM ips i JOIN subdomains s ON i.subdomain_id = s.id
+		WHERE s.target_id = ? AND i.removed_at IS NULL AND COALESCE(i.last_seen, '') < ? AND `+notImported("i"), targetID, sqlTime(since))
+	if err != nil {
+		return 0, err
+	}
+	return markRemoved(db, AssetIP, ids)
+}
+
+// MarkStalePorts marks the ports of a target's IPs that were not seen since the given time as removed.
+func MarkStalePorts(db *sql.DB, targetID int64, since time.Time) (int, error) {
+	ids, err := queryIDs(db, `SELECT p.id FROM ports p JOIN ips i ON p.ip_id = i.id JOIN subdomains s ON i.subdomain_id = s.id
+		WHERE s.target_id = ? AND p.removed_at IS NULL AND COALESCE(p.last_seen, '') < ? AND `+notImported("p"), targetID, sqlTime(since))
+	if err != nil {
+		return 0, err
+	}
+	return markRemoved(db, AssetPort, ids)
+}
+
+// MarkStaleLiveURLs marks the live URLs of a target that are not in live as no longer live.
+func MarkStaleLiveURLs(db *sql.DB, targetID int64, live map[string]bool) (int, error) {
+	rows, err := db.Query("SELECT id, url FROM urls WHERE target_id = ? AND status_code > 0 AND removed_at IS NULL AND "+notImported(""), targetID)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var url string
+		if err := rows.Scan(&id, &url); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !live[url] {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	return markRemoved(db, AssetURL, ids)
+}
+
+// MarkStaleVulnerabilities marks findings on the scanned URLs that were not
+// reported since the given time as removed. Only findings with one of the
+// scanned severities are considered; a nil severities slice means all of them.
+func MarkStaleVulnerabilities(db *sql.DB, scanned []string, severities []string, since time.Time) (int, error) {
+	urlSet := make(map[string]bool, len(scanned))
+	for _, u := range scanned {
+		urlSet[u] = true
+	}
+	sevSet := make(map[string]bool, len(severities))
+	for _, s := range severities {
+		sevSet[strings.ToLower(s)] = true
+	}
+
+	rows, err := db.Query(`SELECT v.id, u.url, LOWER(COALESCE(v.severity, '')) FROM vulnerabilities v JOIN urls u ON v.url_id = u.id
+		WHERE v.removed_at IS NULL AND COALESCE(v.last_seen, '') < ? AND `+notImported("v"), sqlTime(since))
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var url, severity string
+		if err := rows.Scan(&id, &url, &severity); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if urlSet[url] && (severities == nil || sevSet[severity]) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	return markRemoved(db, AssetVulnerability, ids)
+}
+
+// DiffAssets compares the state of the workspace at two points in time and
+// returns the assets that appeared or disappeared in between. An asset that
+// disappeared and came back within the window is not reported.
+func DiffAssets(db *sql.DB, from, to time.Time) ([]AssetChange, error) {
+	rows, err := db.Query(`SELECT id, asset_type, asset_id, asset, event, created_at FROM asset_events
+		WHERE created_at <= ? ORDER BY id`, sqlTime(to))
+	if err != nil {
+		return nil, fmt.Errorf("could not read asset history: %w", err)
+	}
+	defer rows.Close()
+
+	fromStr := sqlTime(from)
+	return diffEvents(rows, func(_ int64, at time.Time) bool { return sqlTime(at) <= fromStr })
+}
+
+// DiffAssetsSince returns the assets that appeared or disappeared after the
+// asset event with the given ID, as returned by LastAssetEventID. Unlike
+// DiffAssets it is not limited by the one second resolution of timestamps.
+func DiffAssetsSince(db *sql.DB, eventID int64) ([]AssetChange, error) {
+	rows, err := db.Query(`SELECT id, asset_type, asset_id, asset, event, created_at FROM asset_events ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("could not read asset history: %w", err)
+	}
+	defer rows.Close()
+
+	return diffEvents(rows, func(id int64, _ time.Time) bool { return id <= eventID })
+}
+
+// LastAssetEventID returns the ID of the most recent asset event, or 0.
+func LastAssetEventID(db *sql.DB) (int64, error) {
+	var id int64
+	err := db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM asset_events").Scan(&id)
+	return id, err
+}
+
+// diffEvents replays asset events in order. Events for which before returns
+// true make up the earlier state, all of them the later one.
+func diffEvents(rows *sql.Rows, before func(eventID int64, at time.Time) bool) ([]AssetChange, error) {
+	type state struct {
+		before, after string
+		change        AssetChange
+	}
+	states := make(map[string]*state)
+	var keys []string
+	for rows.Next() {
+		var assetType, asset, event string
+		var eventID, id int64
+		var at time.Time
+		if err := rows.Scan(&eventID, &assetType, &id, &asset, &event, &at); err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%s/%d", assetType, id)
+		st, ok := states[key]
+		if !ok {
+			st = &state{}
+			states[key] = st
+			keys = append(keys, key)
+		}
+		if before(eventID, at) {
+			st.before = event
+		}
+		st.after = event
+		st.change = AssetChange{Type: assetType, Asset: asset, Change: event, At: at}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var changes []AssetChange
+	for _, key := range keys {
+		st := states[key]
+		wasPresent, isPresent := st.before == EventAdded, st.after == EventAdded
+		if wasPresent != isPresent {
+			changes = append(changes, st.change)
+		}
+	}
+
+	order := make(map[string]int)
+	for i, t := range assetTypes {
+		order[t] = i
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Type != changes[j].Type {
+			return order[changes[i].Type] < order[changes[j].Type]
+		}
+		if changes[i].Change != changes[j].Change {
+			return changes[i].Change == EventAdded
+		}
+		return changes[i].Asset < changes[j].Asset
+	})
+	return changes, nil
+}
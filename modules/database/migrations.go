@@ -0,0 +1,421 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is one ordered, forward-only change to the workspace schema.
+// Statements run first, then up (if set), inside a single transaction.
+type migration struct {
+	version     int
+	description string
+	statements  []string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations must only ever be appended to. Existing entries are applied to
+// databases in the wild and must not change.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "initial schema",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS targets (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				target TEXT NOT NULL UNIQUE
+			);`,
+			`CREATE TABLE IF NOT EXISTS subdomains (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				target_id INTEGER,
+				subdomain TEXT NOT NULL UNIQUE,
+				FOREIGN KEY(target_id) REFERENCES targets(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS ips (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				subdomain_id INTEGER,
+				ip_address TEXT NOT NULL,
+				FOREIGN KEY(subdomain_id) REFERENCES subdomains(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS ports (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				ip_id INTEGER,
+				port INTEGER NOT NULL,
+				service TEXT,
+				UNIQUE(ip_id, port),
+				FOREIGN KEY(ip_id) REFERENCES ips(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS urls (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				target_id INTEGER,
+				url TEXT NOT NULL UNIQUE,
+				source TEXT,
+				status_code INTEGER,
+				title TEXT,
+				tech TEXT,
+				screenshot_path TEXT,
+				FOREIGN KEY(target_id) REFERENCES targets(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS vulnerabilities (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url_id INTEGER,
+				template_id TEXT NOT NULL,
+				name TEXT NOT NULL,
+				severity TEXT,
+				description TEXT,
+				UNIQUE(url_id, template_id),
+				FOREIGN KEY (url_id) REFERENCES urls(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS exploits (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				vulnerability_id INTEGER,
+				title TEXT NOT NULL,
+				edb_id TEXT,
+				path TEXT,
+				FOREIGN KEY (vulnerability_id) REFERENCES vulnerabilities(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS secrets (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url_id INTEGER,
+				type TEXT NOT NULL,
+				value TEXT NOT NULL,
+				source TEXT NOT NULL,
+				FOREIGN KEY (url_id) REFERENCES urls(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS parameters (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url_id INTEGER,
+				name TEXT NOT NULL,
+				source TEXT NOT NULL,
+				UNIQUE(url_id, name),
+				FOREIGN KEY (url_id) REFERENCES urls(id)
+			);`,
+		},
+	},
+	{
+		version:     2,
+		description: "run and module checkpoints",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				pipeline TEXT NOT NULL,
+				status TEXT NOT NULL,
+				started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				finished_at TIMESTAMP
+			);`,
+			`CREATE TABLE IF NOT EXISTS module_runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				run_id INTEGER NOT NULL,
+				module TEXT NOT NULL,
+				target TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL,
+				error TEXT,
+				started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				finished_at TIMESTAMP,
+				UNIQUE(run_id, module, target),
+				FOREIGN KEY (run_id) REFERENCES runs(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS run_items (
+				run_id INTEGER NOT NULL,
+				module TEXT NOT NULL,
+				item TEXT NOT NULL,
+				PRIMARY KEY (run_id, module, item),
+				FOREIGN KEY (run_id) REFERENCES runs(id)
+			);`,
+		},
+	},
+	{
+		version:     3,
+		description: "asset history",
+		statements: []string{
+			// ips had no unique constraint, so every recon run duplicated its rows.
+			// Point ports at the surviving row, drop the rest and add the constraint.
+			`UPDATE OR IGNORE ports SET ip_id = (
+				SELECT MIN(d.id) FROM ips d, ips o
+				WHERE o.id = ports.ip_id AND d.ip_address = o.ip_address AND d.subdomain_id IS o.subdomain_id
+			) WHERE ip_id IN (SELECT id FROM ips);`,
+			`DELETE FROM ports WHERE ip_id IN (SELECT id FROM ips) AND ip_id NOT IN (SELECT MIN(id) FROM ips GROUP BY subdomain_id, ip_address);`,
+			`DELETE FROM ips WHERE id NOT IN (SELECT MIN(id) FROM ips GROUP BY subdomain_id, ip_address);`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS ips_subdomain_ip ON ips(subdomain_id, ip_address);`,
+
+			`ALTER TABLE subdomains ADD COLUMN first_seen TIMESTAMP;`,
+			`ALTER TABLE subdomains ADD COLUMN last_seen TIMESTAMP;`,
+			`ALTER TABLE subdomains ADD COLUMN removed_at TIMESTAMP;`,
+			`ALTER TABLE ips ADD COLUMN first_seen TIMESTAMP;`,
+			`ALTER TABLE ips ADD COLUMN last_seen TIMESTAMP;`,
+			`ALTER TABLE ips ADD COLUMN removed_at TIMESTAMP;`,
+			`ALTER TABLE ports ADD COLUMN first_seen TIMESTAMP;`,
+			`ALTER TABLE ports ADD COLUMN last_seen TIMESTAMP;`,
+			`ALTER TABLE ports ADD COLUMN removed_at TIMESTAMP;`,
+			`ALTER TABLE urls ADD COLUMN first_seen TIMESTAMP;`,
+			`ALTER TABLE urls ADD COLUMN last_seen TIMESTAMP;`,
+			`ALTER TABLE urls ADD COLUMN removed_at TIMESTAMP;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN first_seen TIMESTAMP;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN last_seen TIMESTAMP;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN removed_at TIMESTAMP;`,
+			`ALTER TABLE secrets ADD COLUMN first_seen TIMESTAMP;`,
+			`ALTER TABLE secrets ADD COLUMN last_seen TIMESTAMP;`,
+			`ALTER TABLE parameters ADD COLUMN first_seen TIMESTAMP;`,
+			`ALTER TABLE parameters ADD COLUMN last_seen TIMESTAMP;`,
+
+			// Existing rows have no history; treat them as first seen now.
+			`UPDATE subdomains SET first_seen = CURRENT_TIMESTAMP, last_seen = CURRENT_TIMESTAMP;`,
+			`UPDATE ips SET first_seen = CURRENT_TIMESTAMP, last_seen = CURRENT_TIMESTAMP;`,
+			`UPDATE ports SET first_seen = CURRENT_TIMESTAMP, last_seen = CURRENT_TIMESTAMP;`,
+			`UPDATE urls SET first_seen = CURRENT_TIMESTAMP, last_seen = CURRENT_TIMESTAMP;`,
+			`UPDATE vulnerabilities SET first_seen = CURRENT_TIMESTAMP, last_seen = CURRENT_TIMESTAMP;`,
+			`UPDATE secrets SET first_seen = CURRENT_TIMESTAMP, last_seen = CURRENT_TIMESTAMP;`,
+			`UPDATE parameters SET first_seen = CURRENT_TIMESTAMP, last_seen = CURRENT_TIMESTAMP;`,
+
+			`CREATE TABLE IF NOT EXISTS asset_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				asset_type TEXT NOT NULL,
+				asset_id INTEGER NOT NULL,
+				asset TEXT NOT NULL,
+				event TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);`,
+			`CREATE INDEX IF NOT EXISTS asset_events_created_at ON asset_events(created_at);`,
+			`INSERT INTO asset_events (asset_type, asset_id, asset, event, created_at)
+				SELECT 'subdomain', id, subdomain, 'added', CURRENT_TIMESTAMP FROM subdomains;`,
+			`INSERT INTO asset_events (asset_type, asset_id, asset, event, created_at)
+				SELECT 'ip', i.id, i.ip_address || ' (' || COALESCE(s.subdomain, '') || ')', 'added', CURRENT_TIMESTAMP
+				FROM ips i LEFT JOIN subdomains s ON i.subdomain_id = s.id;`,
+			`INSERT INTO asset_events (asset_type, asset_id, asset, event, created_at)
+				SELECT 'port', p.id, i.ip_address || ':' || p.port, 'added', CURRENT_TIMESTAMP
+				FROM ports p JOIN ips i ON p.ip_id = i.id;`,
+			`INSERT INTO asset_events (asset_type, asset_id, asset, event, created_at)
+				SELECT 'url', id, url, 'added', CURRENT_TIMESTAMP FROM urls WHERE status_code > 0;`,
+			`INSERT INTO asset_events (asset_type, asset_id, asset, event, created_at)
+				SELECT 'vulnerability', v.id, '[' || COALESCE(v.severity, 'unknown') || '] ' || v.template_id || ' @ ' || COALESCE(u.url, ''),
+				'added', CURRENT_TIMESTAMP
+				FROM vulnerabilities v LEFT JOIN urls u ON v.url_id = u.id;`,
+		},
+	},
+	{
+		version:     4,
+		description: "change events",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS change_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				run_id INTEGER,
+				kind TEXT NOT NULL,
+				asset TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (run_id) REFERENCES runs(id)
+			);`,
+			`CREATE INDEX IF NOT EXISTS change_events_run_id ON change_events(run_id);`,
+		},
+	},
+	{
+		version:     5,
+		description: "unique secrets",
+		statements: []string{
+			// Secrets were inserted again on every scan; keep the first sighting of each.
+			`UPDATE secrets SET last_seen = (
+				SELECT MAX(d.last_seen) FROM secrets d
+				WHERE d.url_id IS secrets.url_id AND d.type = secrets.type AND d.value = secrets.value
+			);`,
+			`DELETE FROM secrets WHERE id NOT IN (SELECT MIN(id) FROM secrets GROUP BY url_id, type, value);`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS secrets_url_type_value ON secrets(url_id, type, value);`,
+		},
+	},
+	{
+		version:     6,
+		description: "asset sources",
+		statements: []string{
+			// urls already record the tool that found them; the other assets could only come from one.
+			`ALTER TABLE subdomains ADD COLUMN source TEXT;`,
+			`ALTER TABLE ips ADD COLUMN source TEXT;`,
+			`ALTER TABLE ports ADD COLUMN source TEXT;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN source TEXT;`,
+			`UPDATE subdomains SET source = 'subfinder';`,
+			`UPDATE ips SET source = 'dnsx';`,
+			`UPDATE ports SET source = 'naabu';`,
+			`UPDATE vulnerabilities SET source = 'nuclei';`,
+		},
+	},
+	{
+		version:     7,
+		description: "secret locations",
+		statements: []string{
+			`ALTER TABLE secrets ADD COLUMN rule_id TEXT;`,
+			`ALTER TABLE secrets ADD COLUMN line INTEGER;`,
+			`ALTER TABLE secrets ADD COLUMN context TEXT;`,
+		},
+	},
+	{
+		version:     8,
+		description: "source maps",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS source_maps (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url_id INTEGER NOT NULL,
+				map_url TEXT NOT NULL,
+				sources INTEGER,
+				path TEXT,
+				first_seen TIMESTAMP,
+				last_seen TIMESTAMP,
+				FOREIGN KEY(url_id) REFERENCES urls(id),
+				UNIQUE(url_id, map_url)
+			);`,
+		},
+	},
+	{
+		version:     9,
+		description: "encrypted secrets",
+		statements: []string{
+			// Values are encrypted once a key is available (see SecretKey); until then
+			// only the redacted form may be read. Sealed values differ on every write,
+			// so known secrets are matched by fingerprint instead.
+			`ALTER TABLE secrets ADD COLUMN fingerprint TEXT;`,
+			`ALTER TABLE secrets ADD COLUMN redacted TEXT;`,
+			`UPDATE secrets SET redacted = CASE WHEN length(value) <= 8 THEN substr('********', 1, length(value))
+				ELSE substr(value, 1, 4) || '********' END;`,
+			`DROP INDEX IF EXISTS secrets_url_type_value;`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS secrets_url_type_fingerprint ON secrets(url_id, type, fingerprint);`,
+			`CREATE TABLE IF NOT EXISTS secret_key (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				salt BLOB NOT NULL,
+				check_value TEXT NOT NULL,
+				created_at TIMESTAMP
+			);`,
+		},
+	},
+	{
+		version:     10,
+		description: "finding triage",
+		statements: []string{
+			`ALTER TABLE vulnerabilities ADD COLUMN status TEXT NOT NULL DEFAULT 'new';`,
+			`ALTER TABLE vulnerabilities ADD COLUMN assignee TEXT;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN notes TEXT;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN triaged_at TIMESTAMP;`,
+			`ALTER TABLE secrets ADD COLUMN status TEXT NOT NULL DEFAULT 'new';`,
+			`ALTER TABLE secrets ADD COLUMN assignee TEXT;`,
+			`ALTER TABLE secrets ADD COLUMN notes TEXT;`,
+			`ALTER TABLE secrets ADD COLUMN triaged_at TIMESTAMP;`,
+		},
+	},
+	{
+		version:     11,
+		description: "vulnerability classification",
+		statements: []string{
+			`ALTER TABLE vulnerabilities ADD COLUMN cve_id TEXT;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN cwe_id TEXT;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN cvss_score REAL;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN cvss_metrics TEXT;`,
+			`ALTER TABLE vulnerabilities ADD COLUMN reference_urls TEXT;`,
+		},
+	},
+}
+
+// MigrationStatus describes whether a migration has been applied to a database.
+type MigrationStatus struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+}
+
+// LatestSchemaVersion returns the schema version this build of Sentinel expects.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func ensureVersionTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	return err
+}
+
+// SchemaVersion returns the highest migration applied to db, or 0 for a new database.
+func SchemaVersion(db *sql.DB) (int, error) {
+	if err := ensureVersionTable(db); err != nil {
+		return 0, err
+	}
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("could not read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrate applies every pending migration in order, each in its own transaction.
+// It refuses to touch a database created by a newer version of Sentinel.
+func Migrate(db *sql.DB) error {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); current > latest {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d); please upgrade Sentinel", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if m.up != nil {
+		if err := m.up(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, CURRENT_TIMESTAMP)", m.version, m.description); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetMigrationStatus lists every known migration along with when it was applied.
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	if err := ensureVersionTable(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, fmt.Errorf("could not read schema versions: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	var status []MigrationStatus
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Description: m.description}
+		if t, ok := applied[m.version]; ok {
+			s.AppliedAt = &t
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
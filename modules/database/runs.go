@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Run statuses stored in runs.status and module_runs.status.
+const (
+	RunRunning     = "running"
+	RunCompleted   = "completed"
+	RunFailed      = "failed"
+	RunInterrupted = "interrupted"
+	RunSkipped     = "skipped"
+)
+
+// Run is a single invocation of a module or pipeline.
+type Run struct {
+	ID         int64
+	Pipeline   string
+	Status     string
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+}
+
+// StartRun records the start of a new run and returns its ID.
+func StartRun(db *sql.DB, pipeline string) (int64, error) {
+	result, err := db.Exec("INSERT INTO runs (pipeline, status, started_at) VALUES (?, ?, CURRENT_TIMESTAMP)", pipeline, RunRunning)
+	if err != nil {
+		return 0, fmt.Errorf("could not start run: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// ResumeRun marks an unfinished run as running again.
+func ResumeRun(db *sql.DB, runID int64) error {
+	_, err := db.Exec("UPDATE runs SET status = ?, finished_at = NULL WHERE id = ?", RunRunning, runID)
+	return err
+}
+
+// FinishRun records the final status of a run.
+func FinishRun(db *sql.DB, runID int64, status string) error {
+	_, err := db.Exec("UPDATE runs SET status = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?", status, runID)
+	return err
+}
+
+// GetResumableRun returns the ID of the latest run of pipeline if it did not complete, or 0.
+func GetResumableRun(db *sql.DB, pipeline string) (int64, error) {
+	var id int64
+	var status string
+	err := db.QueryRow("SELECT id, status FROM runs WHERE pipeline = ? ORDER BY id DESC LIMIT 1", pipeline).Scan(&id, &status)
+	if err == sql.ErrNoRows || status == RunCompleted {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not look up previous run: %w", err)
+	}
+	return id, nil
+}
+
+// GetRun retrieves a run by ID.
+func GetRun(db *sql.DB, runID int64) (*Run, error) {
+	r := &Run{}
+	err := db.QueryRow("SELECT id, pipeline, status, started_at, finished_at FROM runs WHERE id = ?", runID).
+		Scan(&r.ID, &r.Pipeline, &r.Status, &r.StartedAt, &r.FinishedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not get run %d: %w", runID, err)
+	}
+	return r, nil
+}
+
+// SetModuleRunStatus records the status of a module, or of one target within a
+// module when target is not empty.
+func SetModuleRunStatus(db *sql.DB, runID int64, module, target, status string, runErr error) error {
+	var errText sql.NullString
+	if runErr != nil {
+		errText = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	if status == RunRunning {
+		_, err := db.Exec(`INSERT INTO module_runs (run_id, module, target, status, started_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(run_id, module, target) DO UPDATE SET status = excluded.status, error = NULL,
+			started_at = CURRENT_TIMESTAMP, finished_at = NULL`, runID, module, target, status)
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO module_runs (run_id, module, target, status, error, finished_at) VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(run_id, module, target) DO UPDATE SET status = excluded.status, error = excluded.error,
+		finished_at = CURRENT_TIMESTAMP`, runID, module, target, status, errText)
+	return err
+}
+
+// GetModuleRunStatus returns the recorded status of a module (or module target) in a run, or "".
+func GetModuleRunStatus(db *sql.DB, runID int64, module, target string) (string, error) {
+	var status string
+	err := db.QueryRow("SELECT status FROM module_runs WHERE run_id = ? AND module = ? AND target = ?", runID, module, target).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return status, err
+}
+
+// MarkRunItem records that module finished processing item (e.g. a URL) during a run.
+func MarkRunItem(db *sql.DB, runID int64, module, item string) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO run_items (run_id, module, item) VALUES (?, ?, ?)", runID, module, item)
+	return err
+}
+
+// GetRunItems returns the items module already finished during a run.
+func GetRunItems(db *sql.DB, runID int64, module string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT item FROM run_items WHERE run_id = ? AND module = ?", runID, module)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[string]bool)
+	for rows.Next() {
+		var item string
+		if err := rows.Scan(&item); err != nil {
+			return nil, err
+		}
+		items[item] = true
+	}
+	return items, nil
+}
+
+// GetRecentRuns returns up to limit runs with the given status (any status if
+// empty), most recent first.
+func GetRecentRuns(db *sql.DB, status string, limit int) ([]Run, error) {
+	rows, err := db.Query(`SELECT id, pipeline, status, started_at, finished_at FROM runs
+		WHERE ? = '' OR status = ? ORDER BY id DESC LIMIT ?`, status, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not list runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var r Run
+		if err := rows.Scan(&r.ID, &r.Pipeline, &r.Status, &r.StartedAt, &r.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"sentinel/modules/utils"
+	"sentinel/modules/vault"
+)
+
+// StoredSecret is a secret as stored: Value is sealed and only readable with
+// the workspace key.
+type StoredSecret struct {
+	ID        int64      `json:"id"`
+	URL       string     `json:"url"`
+	Target    string     `json:"target,omitempty"`
+	Type      string     `json:"type"`
+	Value     string     `json:"-"`
+	Redacted  string     `json:"redacted"`
+	Source    string     `json:"source"`
+	RuleID    string     `json:"rule_id,omitempty"`
+	Line      int        `json:"line,omitempty"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+}
+
+// SecretKey returns the key of the workspace, taken from the environment or,
+// when neither variable is set, from the key file generated for the workspace
+// (see the vault package). The first call creates the workspace's salt and
+// records a check value, which later calls use to reject a different key.
+// Values stored in clear text by older versions are encrypted on the way.
+func SecretKey(db *sql.DB, workspace string) (*vault.Key, error) {
+	var salt []byte
+	var check string
+	err := db.QueryRow("SELECT salt, check_value FROM secret_key WHERE id = 1").Scan(&salt, &check)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if salt, err = vault.NewSalt(); err != nil {
+			return nil, err
+		}
+		key, err := workspaceKey(workspace, salt, true)
+		if err != nil {
+			return nil, err
+		}
+		// Another process may have created the salt meanwhile; only one insert wins.
+		if _, err := db.Exec("INSERT OR IGNORE INTO secret_key (id, salt, check_value, created_at) VALUES (1, ?, ?, CURRENT_TIMESTAMP)", salt, key.Check()); err != nil {
+			return nil, fmt.Errorf("could not store the secrets key salt: %w", err)
+		}
+		return SecretKey(db, workspace)
+	case err != nil:
+		return nil, fmt.Errorf("could not read the secrets key salt: %w", err)
+	}
+
+	// A workspace whose key came from the environment gets no key file later on.
+	key, err := workspaceKey(workspace, salt, false)
+	if errors.Is(err, vault.ErrNoKeyFile) {
+		return nil, fmt.Errorf("the key this workspace's secrets are encrypted with is not available: set %s or %s, or restore %s", vault.PassphraseEnv, vault.KeyEnv, filepath.Join(workspace, vault.KeyFile))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.Check() != check {
+		return nil, fmt.Errorf("the secrets key does not match the one this workspace's secrets are encrypted with (check %s, %s and %s)", vault.PassphraseEnv, vault.KeyEnv, filepath.Join(workspace, vault.KeyFile))
+	}
+	if err := sealSecrets(db, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// workspaceKey takes the key from the environment when it is set there, and
+// from the workspace's key file otherwise.
+func workspaceKey(workspace string, salt []byte, create bool) (*vault.Key, error) {
+	if vault.Configured() {
+		return vault.FromEnv(salt)
+	}
+	return vault.FromFile(workspace, salt, create)
+}
+
+// PlaintextSecrets counts the secrets stored in clear text by older versions,
+// which SecretKey encrypts.
+func PlaintextSecrets(db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM secrets WHERE fingerprint IS NULL").Scan(&n)
+	return n, err
+}
+
+// sealSecrets encrypts the values stored in clear text.
+func sealSecrets(db *sql.DB, key *vault.Key) error {
+	rows, err := db.Query("SELECT id, value FROM secrets WHERE fingerprint IS NULL")
+	if err != nil {
+		return fmt.Errorf("could not read plaintext secrets: %w", err)
+	}
+	plain := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		plain[id] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(plain) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, value := range plain {
+		sealed, err := key.Encrypt(value)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE secrets SET value = ?, fingerprint = ?, redacted = ? WHERE id = ?",
+			sealed, key.Fingerprint(value), utils.Redact(value), id); err != nil {
+			return fmt.Errorf("could not encrypt secret %d: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSecrets returns the stored secrets, or only the one with the given ID
+// when id is not 0.
+func GetSecrets(db *sql.DB, id int64) ([]StoredSecret, error) {
+	query := `SELECT s.id, COALESCE(u.url, ''), COALESCE(t.target, ''), s.type, s.value, COALESCE(s.redacted, ''), COALESCE(s.source, ''),
+			COALESCE(s.rule_id, ''), COALESCE(s.line, 0), s.first_seen, s.last_seen
+		FROM secrets s LEFT JOIN urls u ON s.url_id = u.id LEFT JOIN targets t ON u.target_id = t.id`
+	var args []any
+	if id != 0 {
+		query += " WHERE s.id = ?"
+		args = append(args, id)
+	}
+	rows, err := db.Query(query+" ORDER BY s.id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []StoredSecret
+	for rows.Next() {
+		var s StoredSecret
+		var firstSeen, lastSeen sql.NullTime
+		if err := rows.Scan(&s.ID, &s.URL, &s.Target, &s.Type, &s.Value, &s.Redacted, &s.Source, &s.RuleID, &s.Line, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		if firstSeen.Valid {
+			s.FirstSeen = &firstSeen.Time
+		}
+		if lastSeen.Valid {
+			s.LastSeen = &lastSeen.Time
+		}
+		secrets = append(secrets, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if id != 0 && len(secrets) == 0 {
+		return nil, fmt.Errorf("no secret with ID %d", id)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Triage statuses of vulnerabilities and secrets.
+const (
+	StatusNew           = "new"
+	StatusConfirmed     = "confirmed"
+	StatusFalsePositive = "false_positive"
+	StatusAcceptedRisk  = "accepted_risk"
+	StatusFixed         = "fixed"
+)
+
+// Statuses lists the triage statuses in workflow order.
+var Statuses = []string{StatusNew, StatusConfirmed, StatusFalsePositive, StatusAcceptedRisk, StatusFixed}
+
+// Kinds of findings that can be triaged.
+const (
+	FindingVuln   = "vuln"
+	FindingSecret = "secret"
+)
+
+var findingTables = map[string]string{
+	FindingVuln:   "vulnerabilities",
+	FindingSecret: "secrets",
+}
+
+// findingLabels describe a finding by ID in triage output, like assetLabels.
+var findingLabels = map[string]string{
+	FindingVuln: `SELECT '[' || COALESCE(v.severity, 'unknown') || '] ' || v.template_id || ' @ ' || COALESCE(u.url, '')
+		FROM vulnerabilities v LEFT JOIN urls u ON v.url_id = u.id WHERE v.id = ?`,
+	FindingSecret: `SELECT s.type || ' ' || COALESCE(s.redacted, '') || ' @ ' || COALESCE(u.url, '')
+		FROM secrets s LEFT JOIN urls u ON s.url_id = u.id WHERE s.id = ?`,
+}
+
+// ParseStatus checks a triage status, accepting dashes for underscores
+// (false-positive).
+func ParseStatus(s string) (string, error) {
+	status := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", "_")
+	for _, known := range Statuses {
+		if status == known {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("unknown status '%s' (expected one of: %s)", s, strings.Join(Statuses, ", "))
+}
+
+// Suppressed reports whether findings with a status are kept out of
+// notifications and are not reopened when scans report them again.
+func Suppressed(status string) bool {
+	return status == StatusFalsePositive || status == StatusAcceptedRisk
+}
+
+// Triage is the triage state of one finding.
+type Triage struct {
+	Kind      string     `json:"kind"`
+	ID        int64      `json:"id"`
+	Finding   string     `json:"finding"`
+	Status    string     `json:"status"`
+	Assignee  string     `json:"assignee,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+	TriagedAt *time.Time `json:"triaged_at,omitempty"`
+	// Classification is only set for vulnerabilities.
+	Classification *Classification `json:"classification,omitempty"`
+}
+
+// TriageUpdate changes the triage state of a finding. Empty fields are left
+// alone; Note is appended to the notes, and an Assignee of "-" clears it.
+type TriageUpdate struct {
+	Status   string
+	Assignee string
+	Note     string
+}
+
+// GetTriage returns the triage state of a finding.
+func GetTriage(db *sql.DB, kind string, id int64) (*Triage, error) {
+	table, ok := findingTables[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown finding kind '%s' (expected %s or %s)", kind, FindingVuln, FindingSecret)
+	}
+	t := &Triage{Kind: kind, ID: id}
+	var assignee, notes sql.NullString
+	var triagedAt sql.NullTime
+	err := db.QueryRow("SELECT status, assignee, notes, triaged_at FROM "+table+" WHERE id = ?", id).Scan(&t.Status, &assignee, &notes, &triagedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no %s with ID %d", kind, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Assignee, t.Notes = assignee.String, notes.String
+	if triagedAt.Valid {
+		t.TriagedAt = &triagedAt.Time
+	}
+	if err := db.QueryRow(findingLabels[kind], id).Scan(&t.Finding); err != nil {
+		return nil, err
+	}
+	if kind == FindingVuln {
+		c, err := GetClassification(db, id)
+		if err != nil {
+			return nil, err
+		}
+		if !c.IsZero() {
+			t.Classification = &c
+		}
+	}
+	return t, nil
+}
+
+// TriageFinding applies an update to a finding and returns its new state.
+func TriageFinding(db *sql.DB, kind string, id int64, u TriageUpdate) (*Triage, error) {
+	if _, err := GetTriage(db, kind, id); err != nil {
+		return nil, err
+	}
+	var sets []string
+	var args []any
+	if u.Status != "" {
+		status, err := ParseStatus(u.Status)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, "status = ?")
+		args = append(args, status)
+	}
+	switch u.Assignee {
+	case "":
+	case "-":
+		sets = append(sets, "assignee = NULL")
+	default:
+		sets = append(sets, "assignee = ?")
+		args = append(args, u.Assignee)
+	}
+	if note := strings.TrimSpace(u.Note); note != "" {
+		line := time.Now().UTC().Format("2006-01-02") + ": " + note
+		sets = append(sets, "notes = CASE WHEN COALESCE(notes, '') = '' THEN ? ELSE notes || char(10) || ? END")
+		args = append(args, line, line)
+	}
+	if len(sets) > 0 {
+		sets = append(sets, "triaged_at = CURRENT_TIMESTAMP")
+		args = append(args, id)
+		if _, err := db.Exec("UPDATE "+findingTables[kind]+" SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...); err != nil {
+			return nil, fmt.Errorf("could not triage %s %d: %w", kind, id, err)
+		}
+	}
+	return GetTriage(db, kind, id)
+}
+
+// TriageCounts returns the number of vulnerabilities and secrets per status,
+// keyed by finding kind. Vulnerabilities that are no longer present are not counted.
+func TriageCounts(db *sql.DB) (map[string]map[string]int, error) {
+	counts := make(map[string]map[string]int)
+	queries := map[string]string{
+		FindingVuln:   "SELECT status, COUNT(*) FROM vulnerabilities WHERE removed_at IS NULL GROUP BY status",
+		FindingSecret: "SELECT status, COUNT(*) FROM secrets GROUP BY status",
+	}
+	for kind, query := range queries {
+		counts[kind] = make(map[string]int)
+		for _, status := range Statuses {
+			counts[kind][status] = 0
+		}
+		rows, err := db.Query(query)
+		if err != nil {
+			return nil, fmt.Errorf("could not count %s statuses: %w", kind, err)
+		}
+		for rows.Next() {
+			var status string
+			var n int
+			if err := rows.Scan(&status, &n); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			counts[kind][status] = n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,359 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sentinel/modules/utils"
+)
+
+// A view is a flattened, joined listing of one kind of asset, used to export
+// the workspace to other tools. Its query names every column with an alias.
+// The first column identifies the asset and is what plain lists contain.
+type view struct {
+	columns []string
+	query   string
+	// redact lists columns whose values must never leave the database in clear text.
+	// Secret values are read from their redacted form; this is a second line of defence.
+	redact map[string]bool
+	// triage is set for findings, whose status column holds their triage status.
+	triage bool
+}
+
+var views = map[string]view{
+	"subdomains": {
+		columns: []string{"subdomain", "target", "ips", "source", "first_seen", "last_seen", "removed_at"},
+		query: `SELECT s.subdomain AS subdomain, t.target AS target,
+				(SELECT GROUP_CONCAT(i.ip_address, ' ') FROM ips i WHERE i.subdomain_id = s.id AND i.removed_at IS NULL) AS ips, s.source AS source,
+				s.first_seen AS first_seen, s.last_seen AS last_seen, s.removed_at AS removed_at
+			FROM subdomains s LEFT JOIN targets t ON s.target_id = t.id`,
+	},
+	"ips": {
+		columns: []string{"ip", "subdomain", "target", "ports", "source", "first_seen", "last_seen", "removed_at"},
+		query: `SELECT i.ip_address AS ip, s.subdomain AS subdomain, t.target AS target,
+				(SELECT GROUP_CONCAT(p.port, ' ') FROM ports p WHERE p.ip_id = i.id AND p.removed_at IS NULL) AS ports, i.source AS source,
+				i.first_seen AS first_seen, i.last_seen AS last_seen, i.removed_at AS removed_at
+			FROM ips i LEFT JOIN subdomains s ON i.subdomain_id = s.id LEFT JOIN targets t ON s.target_id = t.id`,
+	},
+	"ports": {
+		columns: []string{"address", "ip", "port", "service", "subdomain", "target", "source", "first_seen", "last_seen", "removed_at"},
+		query: `SELECT i.ip_address || ':' || p.port AS address, i.ip_address AS ip, p.port AS port, p.service AS service,
+				s.subdomain AS subdomain, t.target AS target, p.source AS source,
+				p.first_seen AS first_seen, p.last_seen AS last_seen, p.removed_at AS removed_at
+			FROM ports p JOIN ips i ON p.ip_id = i.id
+			LEFT JOIN subdomains s ON i.subdomain_id = s.id LEFT JOIN targets t ON s.target_id = t.id`,
+	},
+	"urls": {
+		columns: []string{"url", "target", "status", "title", "tech", "source", "parameters", "screenshot", "first_seen", "last_seen", "removed_at"},
+		query: `SELECT u.url AS url, t.target AS target, u.status_code AS status, u.title AS title, u.tech AS tech, u.source AS source,
+				(SELECT GROUP_CONCAT(pa.name, ' ') FROM parameters pa WHERE pa.url_id = u.id) AS parameters,
+				u.screenshot_path AS screenshot, u.first_seen AS first_seen, u.last_seen AS last_seen, u.removed_at AS removed_at
+			FROM urls u LEFT JOIN targets t ON u.target_id = t.id`,
+	},
+	"vulns": {
+		columns: []string{"url", "target", "template_id", "name", "severity", "cvss_score", "cve_id", "cwe_id", "description", "source",
+			"status", "assignee", "notes", "cvss_metrics", "references", "id", "first_seen", "last_seen", "removed_at"},
+		query: `SELECT u.url AS url, t.target AS target, v.template_id AS template_id, v.name AS name, v.severity AS severity,
+				v.cvss_score AS cvss_score, v.cve_id AS cve_id, v.cwe_id AS cwe_id,
+				v.description AS description, v.source AS source, v.status AS status, v.assignee AS assignee, v.notes AS notes,
+				v.cvss_metrics AS cvss_metrics, v.reference_urls AS "references", v.id AS id,
+				v.first_seen AS first_seen, v.last_seen AS last_seen, v.removed_at AS removed_at
+			FROM vulnerabilities v LEFT JOIN urls u ON v.url_id = u.id LEFT JOIN targets t ON u.target_id = t.id`,
+		triage: true,
+	},
+	"secrets": {
+		columns: []string{"url", "target", "type", "value", "source", "rule_id", "line", "context", "status", "assignee", "notes", "id", "first_seen", "last_seen"},
+		query: `SELECT u.url AS url, t.target AS target, s.type AS type, s.redacted AS value, s.source AS source,
+				s.rule_id AS rule_id, s.line AS line, s.context AS context, s.status AS status, s.assignee AS assignee, s.notes AS notes, s.id AS id,
+				s.first_seen AS first_seen, s.last_seen AS last_seen
+			FROM secrets s LEFT JOIN urls u ON s.url_id = u.id LEFT JOIN targets t ON u.target_id = t.id`,
+		redact: map[string]bool{"value": true},
+		triage: true,
+	},
+	"sourcemaps": {
+		columns: []string{"url", "map_url", "target", "sources", "path", "first_seen", "last_seen"},
+		query: `SELECT u.url AS url, m.map_url AS map_url, t.target AS target, m.sources AS sources, m.path AS path,
+				m.first_seen AS first_seen, m.last_seen AS last_seen
+			FROM source_maps m LEFT JOIN urls u ON m.url_id = u.id LEFT JOIN targets t ON u.target_id = t.id`,
+	},
+	"params": {
+		columns: []string{"url", "name", "source", "target", "first_seen", "last_seen"},
+		query: `SELECT u.url AS url, pa.name AS name, pa.source AS source, t.target AS target,
+				pa.first_seen AS first_seen, pa.last_seen AS last_seen
+			FROM parameters pa LEFT JOIN urls u ON pa.url_id = u.id LEFT JOIN targets t ON u.target_id = t.id`,
+	},
+}
+
+// ViewNames returns the names of the views that can be queried, sorted.
+func ViewNames() []string {
+	names := make([]string, 0, len(views))
+	for name := range views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ViewColumns returns the columns of a view, or nil if there is no such view.
+func ViewColumns(name string) []string {
+	return views[name].columns
+}
+
+// Filter restricts a view to rows whose column compares to a value. Op is one
+// of = != ~ (contains) !~ > < >= <=; comparisons of ~ and !~ ignore case.
+type Filter struct {
+	Column string
+	Op     string
+	Value  string
+}
+
+// filterOps are the filter operators. The first operator in an expression
+// splits it; of two starting at the same place (">" and ">="), the longer wins.
+var filterOps = []string{"!=", ">=", "<=", "!~", "=", "~", ">", "<"}
+
+// ParseFilter parses an expression such as "status=200" or "tech~nginx".
+func ParseFilter(expr string) (Filter, error) {
+	best := -1
+	var op string
+	for _, o := range filterOps {
+		if i := strings.Index(expr, o); i > 0 && (best == -1 || i < best || (i == best && len(o) > len(op))) {
+			best, op = i, o
+		}
+	}
+	if best == -1 {
+		return Filter{}, fmt.Errorf("invalid filter '%s' (expected <column><op><value>, e.g. status=200 or tech~nginx)", expr)
+	}
+	return Filter{Column: strings.TrimSpace(expr[:best]), Op: op, Value: strings.TrimSpace(expr[best+len(op):])}, nil
+}
+
+// ViewQuery selects the rows of a view.
+type ViewQuery struct {
+	Filters []Filter
+	// IncludeRemoved also returns assets that are no longer present.
+	IncludeRemoved bool
+	// IncludeFalsePositives also returns findings triaged as false positives.
+	IncludeFalsePositives bool
+	// Columns selects the columns returned, in that order; all when empty.
+	Columns []string
+	// Sort orders the rows by a column, in descending order when it starts
+	// with "-". Without it, rows are ordered by target and the first column.
+	Sort string
+	// Limit and Offset page through the rows. A Limit of 0 returns them all.
+	Limit  int
+	Offset int
+}
+
+// Table is the result of a view query. Values are strings, int64s, float64s,
+// time.Times or nil.
+type Table struct {
+	Columns []string
+	Rows    [][]any
+	// Total is the number of matching rows, of which Rows is one page.
+	Total int
+}
+
+// TargetCount is the number of rows of a view that belong to one target.
+type TargetCount struct {
+	Target string `json:"target"`
+	Count  int    `json:"count"`
+}
+
+// QueryView returns the rows of the named view that match q, in a stable order.
+func QueryView(db *sql.DB, name string, q ViewQuery) (*Table, error) {
+	v, ok := views[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown view '%s' (expected one of: %s)", name, strings.Join(ViewNames(), ", "))
+	}
+	has := v.has()
+	where, args, err := v.where(name, q)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := v.columns
+	if len(q.Columns) > 0 {
+		columns = q.Columns
+		for _, c := range columns {
+			if !has[c] {
+				return nil, fmt.Errorf("unknown column '%s' for %s (expected one of: %s)", c, name, strings.Join(v.columns, ", "))
+			}
+		}
+	}
+
+	order := quoteIdent(v.columns[0])
+	if has["target"] {
+		order = "target, " + order
+	}
+	if q.Sort != "" {
+		col, desc := strings.CutPrefix(q.Sort, "-")
+		if !has[col] {
+			return nil, fmt.Errorf("unknown column '%s' for %s (expected one of: %s)", col, name, strings.Join(v.columns, ", "))
+		}
+		if v.redact[col] {
+			return nil, fmt.Errorf("cannot sort on '%s'", col)
+		}
+		dir := "ASC"
+		if desc {
+			dir = "DESC"
+		}
+		order = fmt.Sprintf("%s %s, %s", quoteIdent(col), dir, order)
+	}
+
+	table := &Table{Columns: columns, Rows: [][]any{}}
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM (%s)%s", v.query, where), args...).Scan(&table.Total); err != nil {
+		return nil, fmt.Errorf("could not count %s: %w", name, err)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM (%s)%s ORDER BY %s", columnList(columns), v.query, where, order)
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", q.Limit, max(q.Offset, 0))
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, c := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			if s, ok := values[i].(string); ok && v.redact[c] {
+				values[i] = utils.Redact(s)
+			}
+		}
+		table.Rows = append(table.Rows, values)
+	}
+	return table, rows.Err()
+}
+
+// CountViewByTarget returns how many rows of the named view that match the
+// filters of q belong to each target, largest first.
+func CountViewByTarget(db *sql.DB, name string, q ViewQuery) ([]TargetCount, error) {
+	v, ok := views[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown view '%s' (expected one of: %s)", name, strings.Join(ViewNames(), ", "))
+	}
+	if !v.has()["target"] {
+		return nil, nil
+	}
+	where, args, err := v.where(name, q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(fmt.Sprintf("SELECT COALESCE(target, ''), COUNT(*) FROM (%s)%s GROUP BY 1 ORDER BY 2 DESC, 1", v.query, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not count %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	counts := []TargetCount{}
+	for rows.Next() {
+		var c TargetCount
+		if err := rows.Scan(&c.Target, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func (v view) has() map[string]bool {
+	has := make(map[string]bool, len(v.columns))
+	for _, c := range v.columns {
+		has[c] = true
+	}
+	return has
+}
+
+// where builds the WHERE clause, if any, of the filters of q.
+func (v view) where(name string, q ViewQuery) (string, []any, error) {
+	has := v.has()
+	var where []string
+	var args []any
+	if has["removed_at"] && !q.IncludeRemoved {
+		where = append(where, "removed_at IS NULL")
+	}
+	if v.triage && !q.IncludeFalsePositives {
+		where = append(where, "status IS NOT ?")
+		args = append(args, StatusFalsePositive)
+	}
+	for _, f := range q.Filters {
+		if !has[f.Column] {
+			return "", nil, fmt.Errorf("unknown column '%s' for %s (expected one of: %s)", f.Column, name, strings.Join(v.columns, ", "))
+		}
+		if v.redact[f.Column] {
+			return "", nil, fmt.Errorf("cannot filter on '%s'", f.Column)
+		}
+		col := quoteIdent(f.Column)
+		switch f.Op {
+		case "~":
+			where = append(where, fmt.Sprintf("COALESCE(%s, '') LIKE '%%' || ? || '%%'", col))
+		case "!~":
+			where = append(where, fmt.Sprintf("COALESCE(%s, '') NOT LIKE '%%' || ? || '%%'", col))
+		case "!=":
+			where = append(where, fmt.Sprintf("%s IS NOT ?", col))
+		case "=", ">", "<", ">=", "<=":
+			where = append(where, fmt.Sprintf("%s %s ?", col, f.Op))
+		default:
+			return "", nil, fmt.Errorf("unknown filter operator '%s'", f.Op)
+		}
+		args = append(args, filterValue(f))
+	}
+
+	if len(where) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(where, " AND "), args, nil
+}
+
+// filterValue passes numbers as numbers so that "status>=400" compares numerically.
+func filterValue(f Filter) any {
+	if f.Op == "~" || f.Op == "!~" {
+		return f.Value
+	}
+	if n, err := strconv.ParseInt(f.Value, 10, 64); err == nil {
+		return n
+	}
+	if x, err := strconv.ParseFloat(f.Value, 64); err == nil {
+		return x
+	}
+	return f.Value
+}
+
+// FormatValue renders a table value as text, the way exports and listings show it.
+func FormatValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return x.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func columnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
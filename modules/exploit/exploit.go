@@ -27,28 +27,33 @@ type SearchsploitResults struct {
 }
 
 // RunExploitResearch orchestrates the exploit research workflow.
-func RunExploitResearch(ctx context.Context, cfg *config.Config, db *sql.DB) {
+func RunExploitResearch(ctx context.Context, cfg *config.Config, db *sql.DB) error {
 	options := utils.Options{
-		Output:  cfg.Workspace,
-		Threads: cfg.Recon.Threads,
+		Output:    cfg.Workspace,
+		Threads:   cfg.Recon.Threads,
+		RateLimit: cfg.RateLimit,
+		Proxy:     cfg.Proxy,
 	}
 	utils.Banner("Starting Exploit Research phase")
 
 	vulns, err := getVulnerabilities(db)
 	if err != nil {
 		utils.Error("Could not retrieve vulnerabilities from database", err)
-		return
+		return err
 	}
 
 	if len(vulns) == 0 {
 		utils.Warn("No vulnerabilities found in database to research. Run the 'scan' module first.")
-		return
+		return nil
 	}
 
 	var totalExploitsFound int
 	for _, vuln := range vulns {
 		utils.Log(fmt.Sprintf("Researching exploits for: %s", vuln.Name))
 		exploits, err := runSearchsploit(ctx, vuln.Name, options)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			utils.Warn(fmt.Sprintf("Exploit search failed for '%s': %v", vuln.Name, err))
 			continue
@@ -68,6 +73,7 @@ func RunExploitResearch(ctx context.Context, cfg *config.Config, db *sql.DB) {
 		}
 	}
 	utils.Success(fmt.Sprintf("Exploit research complete. Found %d total potential exploits.", totalExploitsFound))
+	return nil
 }
 
 func getVulnerabilities(db *sql.DB) ([]Vulnerability, error) {
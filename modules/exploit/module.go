@@ -0,0 +1,32 @@
+package exploit
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'exploit'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "exploit" }
+
+func (Module) Description() string {
+	return "Research public exploits for vulnerabilities found"
+}
+
+func (Module) RequiredTools() []string { return []string{"searchsploit"} }
+
+func (Module) Inputs() []string { return []string{registry.Vulnerabilities} }
+
+func (Module) Outputs() []string { return []string{registry.Exploits} }
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunExploitResearch(ctx, cfg, db)
+}
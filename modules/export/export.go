@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"sentinel/modules/database"
+)
+
+// Formats lists the supported table export formats.
+var Formats = []string{"csv", "jsonl", "txt"}
+
+// IsFormat reports whether format is a supported table export format.
+func IsFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes a view table to w. csv has a header row, jsonl writes one
+// object per row with the columns in view order, and txt is a plain list of
+// the first column (e.g. one URL per line), without duplicates, for piping
+// into other tools.
+func Write(w io.Writer, t *database.Table, format string) error {
+	switch format {
+	case "csv":
+		return writeCSV(w, t)
+	case "jsonl":
+		return writeJSONL(w, t)
+	case "txt":
+		return writeList(w, t)
+	default:
+		return fmt.Errorf("unknown export format '%s' (expected %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func writeCSV(w io.Writer, t *database.Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			record[i] = database.FormatValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSONL(w io.Writer, t *database.Table) error {
+	var buf bytes.Buffer
+	for _, row := range t.Rows {
+		buf.Reset()
+		buf.WriteByte('{')
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(t.Columns[i])
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteString("}\n")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeList(w io.Writer, t *database.Table) error {
+	seen := make(map[string]bool)
+	for _, row := range t.Rows {
+		v := database.FormatValue(row[0])
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		if _, err := fmt.Fprintln(w, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
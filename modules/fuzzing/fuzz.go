@@ -7,10 +7,11 @@ import (
 	"fmt"
 	"net/url"
 	"os"
-	"strings"
 
+	"sentinel/modules/checkpoint"
 	"sentinel/modules/config"
 	"sentinel/modules/database"
+	"sentinel/modules/scope"
 	"sentinel/modules/utils"
 	"github.com/fatih/color"
 )
@@ -40,17 +41,26 @@ func getBaseURLs(urls map[string]int) []string {
 	return keys
 }
 
-func RunFuzzing(ctx context.Context, config *config.Config, db *sql.DB) {
+// RunFuzzing runs ffuf against every live base URL and stores discovered content.
+func RunFuzzing(ctx context.Context, config *config.Config, db *sql.DB) error {
 	options := utils.Options{
-		Output:  config.Workspace,
-		Threads: config.Recon.Threads, // ffuf uses its own thread control
+		Output:    config.Workspace,
+		Threads:   config.Recon.Threads, // ffuf uses its own thread control
+		RateLimit: config.RateLimit,
+		Proxy:     config.Proxy,
 	}
 	color.Cyan("[*] Starting Content Discovery (Fuzzing) phase")
 
+	sc, err := scope.New(config)
+	if err != nil {
+		color.Red("Invalid scope configuration: %v", err)
+		return err
+	}
+
 	if !utils.CommandExists("ffuf") {
 		color.Red("ffuf not found. Please install it first.")
 		color.Yellow("Hint: go install github.com/ffuf/ffuf@latest")
-		return
+		return fmt.Errorf("ffuf not found")
 	}
 
 	wordlist := config.Fuzzing.Wordlist
@@ -63,56 +73,61 @@ func RunFuzzing(ctx context.Context, config *config.Config, db *sql.DB) {
 	if _, err := os.Stat(wordlist); os.IsNotExist(err) {
 		utils.Error("Default wordlist not found. This should be installed automatically with the 'seclists' package.", err)
 		utils.Warn("Please ensure Sentinel and its dependencies are installed correctly.")
-		return
+		return fmt.Errorf("wordlist %s not found", wordlist)
 	}
 
 	utils.Banner("Fetching live URLs to determine base targets for fuzzing")
 	urls, err := database.GetLiveURLsAsMap(db)
 	if err != nil {
 		color.Red("Error getting URLs from database: %v", err)
-		return
+		return err
 	}
 
-	baseURLs := getBaseURLs(urls)
+	baseURLs := sc.Filter("ffuf input", getBaseURLs(urls))
 	if len(baseURLs) == 0 {
 		color.Yellow("No base URLs found to fuzz.")
-		return
+		return nil
 	}
 	color.Green("Found %d unique base URLs to fuzz.", len(baseURLs))
 
 	targets, err := database.GetTargets(db)
 	if err != nil {
 		color.Red("Error getting targets for URL association: %v", err)
-		return
+		return err
 	}
 
+	// When resuming an interrupted run, base URLs that were already fuzzed are skipped.
+	tracker := checkpoint.For(ctx, "fuzz")
 	newURLsFound := 0
 	for _, baseURL := range baseURLs {
+		if tracker.Done(baseURL) {
+			continue
+		}
 		utils.Log(fmt.Sprintf("Fuzzing: %s", baseURL))
 		output, err := utils.RunCommandAndCapture(ctx, options, "ffuf", "-w", wordlist, "-u", baseURL+"/FUZZ", "-ac", "-o", "/dev/stdout", "-of", "json")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil && len(output) == 0 {
 			utils.Warn(fmt.Sprintf("Error running ffuf on %s: %v", baseURL, err))
 			continue
 		}
+		tracker.Mark(baseURL)
 
 		var ffufResult FFUFOutput
 		if err := json.Unmarshal([]byte(output), &ffufResult); err == nil {
 			for _, result := range ffufResult.Results {
 				newURL := result.URL
 
-				var associatedTargetID int = -1
 				parsedNewUrl, err := url.Parse(newURL)
 				if err != nil {
 					continue
 				}
-
-				for id, targetDomain := range targets {
-					if strings.HasSuffix(parsedNewUrl.Hostname(), targetDomain) {
-						associatedTargetID = id
-						break
-					}
+				if !sc.Allow("ffuf", newURL) {
+					continue
 				}
 
+				associatedTargetID := scope.TargetFor(parsedNewUrl.Hostname(), targets)
 				if associatedTargetID != -1 {
 					if _, err := database.AddURL(db, associatedTargetID, newURL, "ffuf"); err == nil {
 						newURLsFound++
@@ -126,4 +141,5 @@ func RunFuzzing(ctx context.Context, config *config.Config, db *sql.DB) {
 	}
 
 	utils.Success(fmt.Sprintf("Fuzzing phase completed. Found %d new URLs.", newURLsFound))
+	return nil
 }
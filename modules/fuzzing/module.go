@@ -0,0 +1,32 @@
+package fuzzing
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'fuzz'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "fuzz" }
+
+func (Module) Description() string {
+	return "Discover hidden content and directories with ffuf"
+}
+
+func (Module) RequiredTools() []string { return []string{"ffuf"} }
+
+func (Module) Inputs() []string { return []string{registry.LiveURLs} }
+
+func (Module) Outputs() []string { return []string{registry.URLs} }
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunFuzzing(ctx, cfg, db)
+}
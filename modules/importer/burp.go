@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"encoding/xml"
+	"os"
+	"strconv"
+)
+
+// burpItems is a Burp Suite XML export ("Save items" from the site map or proxy history).
+type burpItems struct {
+	Items []struct {
+		URL  string `xml:"url"`
+		Host struct {
+			IP string `xml:"ip,attr"`
+		} `xml:"host"`
+		Status string `xml:"status"`
+	} `xml:"item"`
+}
+
+// parseBurp reads the requested URLs, with the status they answered with and
+// the address of their host. Request and response bodies are not imported.
+func parseBurp(f *os.File) (*Batch, error) {
+	var items burpItems
+	if err := xml.NewDecoder(f).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{}
+	for _, it := range items.Items {
+		if it.URL == "" {
+			batch.Skipped++
+			continue
+		}
+		status, _ := strconv.Atoi(it.Status)
+		batch.URLs = append(batch.URLs, URL{URL: it.URL, Status: status, IP: it.Host.IP})
+	}
+	return batch, nil
+}
@@ -0,0 +1,42 @@
+package importer
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// harLog is the part of an HTTP Archive (browser devtools, Burp, ZAP) that is imported.
+type harLog struct {
+	Log struct {
+		Entries []struct {
+			ServerIPAddress string `json:"serverIPAddress"`
+			Request         struct {
+				URL string `json:"url"`
+			} `json:"request"`
+			Response struct {
+				Status int `json:"status"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// parseHAR reads the requested URLs, with their status and server address.
+func parseHAR(f *os.File) (*Batch, error) {
+	var har harLog
+	if err := json.NewDecoder(f).Decode(&har); err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{}
+	for _, e := range har.Log.Entries {
+		if !strings.HasPrefix(e.Request.URL, "http://") && !strings.HasPrefix(e.Request.URL, "https://") {
+			batch.Skipped++
+			continue
+		}
+		// Browsers wrap IPv6 addresses in brackets.
+		ip := strings.Trim(e.ServerIPAddress, "[]")
+		batch.URLs = append(batch.URLs, URL{URL: e.Request.URL, Status: e.Response.Status, IP: ip})
+	}
+	return batch, nil
+}
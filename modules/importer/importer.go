@@ -0,0 +1,307 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"sentinel/modules/config"
+	"sentinel/modules/database"
+	"sentinel/modules/nvd"
+	"sentinel/modules/scope"
+	"sentinel/modules/utils"
+)
+
+// Formats lists the supported import formats.
+var Formats = []string{"nmap", "burp", "har", "list", "nuclei"}
+
+// Host is a host name (or a bare IP address) with the addresses it resolved
+// to and the ports found open on them.
+type Host struct {
+	Name  string
+	IPs   []string
+	Ports []Port
+}
+
+// Port is an open port on one of a host's addresses.
+type Port struct {
+	IP      string
+	Number  int
+	Service string
+}
+
+// URL is a URL with the status code it answered with, if known.
+type URL struct {
+	URL    string
+	Status int
+	IP     string
+}
+
+// Finding is a vulnerability reported at a URL.
+type Finding struct {
+	URL         string
+	TemplateID  string
+	Name        string
+	Severity    string
+	Description string
+	Class       database.Classification
+}
+
+// Batch is the normalized content of an imported file. Skipped counts the
+// entries of the file that could not be used, such as findings without a URL.
+type Batch struct {
+	Hosts    []Host
+	URLs     []URL
+	Findings []Finding
+	Skipped  int
+}
+
+// Result counts what an import stored. Assets that were already known are
+// counted too, as they are marked as seen again.
+type Result struct {
+	Format          string `json:"format"`
+	File            string `json:"file"`
+	Source          string `json:"source"`
+	Subdomains      int    `json:"subdomains"`
+	IPs             int    `json:"ips"`
+	Ports           int    `json:"ports"`
+	URLs            int    `json:"urls"`
+	Vulnerabilities int    `json:"vulnerabilities"`
+	OutOfScope      int    `json:"out_of_scope"`
+	NoTarget        int    `json:"no_target"`
+	Unusable        int    `json:"unusable"`
+}
+
+var parsers = map[string]func(*os.File) (*Batch, error){
+	"nmap":   parseNmap,
+	"burp":   parseBurp,
+	"har":    parseHAR,
+	"list":   parseList,
+	"nuclei": parseNuclei,
+}
+
+// Import parses a file in the given format and stores its content in the
+// workspace database. Everything is recorded with the source
+// "import:<label>", and label defaults to the format. Items are checked
+// against the scope and assigned to the target they belong to; items outside
+// the scope or not belonging to any target are skipped.
+func Import(cfg *config.Config, db *sql.DB, format, path, label string) (*Result, error) {
+	parse, ok := parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import format '%s' (expected %s)", format, strings.Join(Formats, ", "))
+	}
+	if label == "" {
+		label = format
+	}
+	source := database.ImportSourcePrefix + label
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	batch, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s as %s: %w", path, format, err)
+	}
+
+	sc, err := scope.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scope configuration: %w", err)
+	}
+	targets, err := database.GetTargets(db)
+	if err != nil {
+		return nil, fmt.Errorf("could not read targets: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("the workspace has no targets; add one with 'add target' first")
+	}
+
+	s := &store{
+		db: db, sc: sc, targets: targets, source: source,
+		res:    &Result{Format: format, File: path, Source: source},
+		subIDs: make(map[string]int64),
+		ipIDs:  make(map[string]int64),
+		urlIDs: make(map[string]int64),
+	}
+	for _, h := range batch.Hosts {
+		s.host(h)
+	}
+	for _, u := range batch.URLs {
+		s.url(u)
+	}
+	for _, v := range batch.Findings {
+		s.finding(v)
+	}
+	if s.res.Vulnerabilities > 0 {
+		if _, err := nvd.Enrich(db, cfg.Workspace); err != nil {
+			utils.Warn(fmt.Sprintf("Could not enrich vulnerabilities: %v", err))
+		}
+	}
+
+	res := s.res
+	res.Unusable = batch.Skipped
+	if res.Unusable > 0 {
+		utils.Warn(fmt.Sprintf("Skipped %d entries of %s that could not be imported.", res.Unusable, path))
+	}
+	if res.OutOfScope > 0 {
+		utils.Warn(fmt.Sprintf("Skipped %d out-of-scope item(s).", res.OutOfScope))
+	}
+	if res.NoTarget > 0 {
+		utils.Warn(fmt.Sprintf("Skipped %d item(s) that belong to no target.", res.NoTarget))
+	}
+	utils.Success(fmt.Sprintf("Imported %d subdomains, %d IPs, %d ports, %d URLs and %d vulnerabilities from %s as '%s'.",
+		res.Subdomains, res.IPs, res.Ports, res.URLs, res.Vulnerabilities, path, source))
+	return res, nil
+}
+
+// store writes a batch to the database.
+type store struct {
+	db      *sql.DB
+	sc      *scope.Scope
+	targets map[int]string
+	source  string
+	res     *Result
+	subIDs  map[string]int64
+	ipIDs   map[string]int64
+	urlIDs  map[string]int64
+}
+
+// subdomain stores a host name and returns its ID, or 0 if it was skipped.
+// Hosts known only by their address are stored under it, so that their IPs
+// and ports have a host like every other.
+func (s *store) subdomain(name string) int64 {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if id, ok := s.subIDs[name]; ok {
+		return id
+	}
+	s.subIDs[name] = 0
+	if name == "" {
+		return 0
+	}
+	if !s.sc.Allow(s.source, name) {
+		s.res.OutOfScope++
+		return 0
+	}
+	targetID := scope.TargetFor(name, s.targets)
+	if targetID == -1 {
+		s.res.NoTarget++
+		return 0
+	}
+	id, err := database.AddSubdomain(s.db, int64(targetID), name, s.source)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Failed to import subdomain %s: %v", name, err))
+		return 0
+	}
+	s.subIDs[name] = id
+	s.res.Subdomains++
+	return id
+}
+
+// ip stores an address of a host and returns its ID, or 0 if it was skipped.
+func (s *store) ip(subID int64, host, ip string) int64 {
+	key := host + "|" + ip
+	if id, ok := s.ipIDs[key]; ok {
+		return id
+	}
+	s.ipIDs[key] = 0
+	if !s.sc.Allow(s.source, ip) {
+		s.res.OutOfScope++
+		return 0
+	}
+	id, err := database.AddIP(s.db, subID, ip, s.source)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Failed to import IP %s for %s: %v", ip, host, err))
+		return 0
+	}
+	s.ipIDs[key] = id
+	s.res.IPs++
+	return id
+}
+
+func (s *store) host(h Host) {
+	subID := s.subdomain(h.Name)
+	if subID == 0 {
+		return
+	}
+	ipIDs := make(map[string]int64)
+	for _, ip := range h.IPs {
+		if id := s.ip(subID, h.Name, ip); id != 0 {
+			ipIDs[ip] = id
+		}
+	}
+	for _, p := range h.Ports {
+		ipID, ok := ipIDs[p.IP]
+		if !ok {
+			continue
+		}
+		if !s.sc.Allow(s.source, fmt.Sprintf("%s:%d", p.IP, p.Number)) {
+			s.res.OutOfScope++
+			continue
+		}
+		if _, err := database.AddPort(s.db, ipID, p.Number, p.Service, s.source); err != nil {
+			utils.Warn(fmt.Sprintf("Failed to import port %s:%d: %v", p.IP, p.Number, err))
+			continue
+		}
+		s.res.Ports++
+	}
+}
+
+// addURL stores a URL and the host it is on, and returns its ID, or 0 if it was skipped.
+func (s *store) addURL(raw, ip string) int64 {
+	if id, ok := s.urlIDs[raw]; ok {
+		return id
+	}
+	s.urlIDs[raw] = 0
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return 0
+	}
+	if !s.sc.Allow(s.source, raw) {
+		s.res.OutOfScope++
+		return 0
+	}
+	targetID := scope.TargetFor(u.Hostname(), s.targets)
+	if targetID == -1 {
+		s.res.NoTarget++
+		return 0
+	}
+	if ip != "" && net.ParseIP(ip) != nil {
+		s.host(Host{Name: u.Hostname(), IPs: []string{ip}})
+	} else {
+		s.subdomain(u.Hostname())
+	}
+	id, err := database.AddURL(s.db, targetID, raw, s.source)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Failed to import URL %s: %v", raw, err))
+		return 0
+	}
+	s.urlIDs[raw] = id
+	s.res.URLs++
+	return id
+}
+
+func (s *store) url(u URL) {
+	if s.addURL(u.URL, u.IP) == 0 || u.Status <= 0 {
+		return
+	}
+	if err := database.SetURLStatusIfUnprobed(s.db, u.URL, u.Status); err != nil {
+		utils.Warn(fmt.Sprintf("Failed to record the status of %s: %v", u.URL, err))
+	}
+}
+
+func (s *store) finding(v Finding) {
+	urlID := s.addURL(v.URL, "")
+	if urlID == 0 {
+		return
+	}
+	severity := strings.ToLower(v.Severity)
+	if _, err := database.AddVulnerability(s.db, int(urlID), v.TemplateID, v.Name, severity, v.Description, s.source, v.Class); err != nil {
+		utils.Warn(fmt.Sprintf("Failed to import finding '%s' at %s: %v", v.Name, v.URL, err))
+		return
+	}
+	s.res.Vulnerabilities++
+}
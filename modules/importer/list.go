@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseList reads one host, IP address, host:port or URL per line, as written
+// by subfinder, amass, naabu, httpx and most other tools. Empty lines and
+// lines starting with # are ignored. Ports are only kept for IP addresses,
+// since the address a host name resolves to is not known.
+func parseList(f *os.File) (*Batch, error) {
+	batch := &Batch{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Some tools add columns (e.g. "host [ip]"); the first one is the asset.
+		line = strings.Fields(line)[0]
+
+		if strings.Contains(line, "://") {
+			batch.URLs = append(batch.URLs, URL{URL: line})
+			continue
+		}
+		host, port := line, 0
+		if h, p, err := net.SplitHostPort(line); err == nil {
+			n, err := strconv.Atoi(p)
+			if err != nil || n <= 0 || n > 65535 {
+				batch.Skipped++
+				continue
+			}
+			host, port = h, n
+		}
+		if strings.ContainsAny(host, "/@ ") {
+			batch.Skipped++
+			continue
+		}
+
+		h := Host{Name: host}
+		if net.ParseIP(host) != nil {
+			h.IPs = []string{host}
+			if port > 0 {
+				h.Ports = []Port{{IP: host, Number: port}}
+			}
+		}
+		batch.Hosts = append(batch.Hosts, h)
+	}
+	return batch, scanner.Err()
+}
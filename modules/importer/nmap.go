@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"encoding/xml"
+	"os"
+)
+
+// nmapRun is the part of nmap's XML output (-oX) that is imported.
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name    string `xml:"name,attr"`
+				Product string `xml:"product,attr"`
+				Tunnel  string `xml:"tunnel,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+// parseNmap reads the hosts that were up, with their names, addresses and
+// open ports. A host without a name is imported under its address.
+func parseNmap(f *os.File) (*Batch, error) {
+	var run nmapRun
+	if err := xml.NewDecoder(f).Decode(&run); err != nil {
+		return nil, err
+	}
+
+	batch := &Batch{}
+	for _, h := range run.Hosts {
+		if h.Status.State != "" && h.Status.State != "up" {
+			continue
+		}
+		var ips []string
+		for _, a := range h.Addresses {
+			if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+				ips = append(ips, a.Addr)
+			}
+		}
+		if len(ips) == 0 {
+			batch.Skipped++
+			continue
+		}
+
+		var ports []Port
+		for _, p := range h.Ports {
+			if p.State.State != "open" {
+				continue
+			}
+			service := p.Service.Name
+			if p.Service.Tunnel == "ssl" && service == "http" {
+				service = "https"
+			}
+			if p.Service.Product != "" {
+				service += " (" + p.Service.Product + ")"
+			}
+			for _, ip := range ips {
+				ports = append(ports, Port{IP: ip, Number: p.PortID, Service: service})
+			}
+		}
+
+		names := make([]string, 0, len(h.Hostnames))
+		for _, n := range h.Hostnames {
+			names = append(names, n.Name)
+		}
+		if len(names) == 0 {
+			names = ips[:1]
+		}
+		for _, name := range names {
+			batch.Hosts = append(batch.Hosts, Host{Name: name, IPs: ips, Ports: ports})
+		}
+	}
+	return batch, nil
+}
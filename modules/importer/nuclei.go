@@ -0,0 +1,47 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"sentinel/modules/scanning"
+)
+
+// parseNuclei reads nuclei's JSONL output (-jsonl), the same format the scan
+// module stores. Findings of network templates, which have no URL, are skipped.
+func parseNuclei(f *os.File) (*Batch, error) {
+	batch := &Batch{}
+	scanner := bufio.NewScanner(f)
+	// Lines carry full requests and responses and can be large.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var res scanning.NucleiResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil || res.TemplateID == "" {
+			batch.Skipped++
+			continue
+		}
+		url := res.MatchedAt
+		if !strings.Contains(url, "://") {
+			url = res.Host
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			batch.Skipped++
+			continue
+		}
+		batch.Findings = append(batch.Findings, Finding{
+			URL:         url,
+			TemplateID:  res.TemplateID,
+			Name:        res.Info.Name,
+			Severity:    res.Info.Severity,
+			Description: res.Info.Description,
+			Class:       res.Classification(),
+		})
+	}
+	return batch, scanner.Err()
+}
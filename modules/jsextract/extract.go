@@ -0,0 +1,192 @@
+package jsextract
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// endpointPattern finds quoted strings that look like URLs or paths, in the
+// manner of LinkFinder: absolute URLs, root-relative and dot-relative paths,
+// REST-style routes and file names with a known extension. \x60 is the
+// backtick of template literals.
+var endpointPattern = regexp.MustCompile(`(?:"|'|\x60)(` +
+	`(?:[a-zA-Z]{1,10}://|//)[^"'\x60/\s]+\.[a-zA-Z]{2,}[^"'\x60\s]*` +
+	`|(?:/|\.\./|\./)[^"'\x60><,;| *()%$^/\\\[\]\s][^"'\x60><,;|()\s]+` +
+	`|[a-zA-Z0-9_\-/]+/[a-zA-Z0-9_\-/.]+\.(?:[a-zA-Z]{1,4}|action)(?:[?#][^"'\x60\s]*)?` +
+	`|[a-zA-Z0-9_\-/]+/[a-zA-Z0-9_\-/]{3,}(?:[?#][^"'\x60\s]*)?` +
+	`|[a-zA-Z0-9_\-]+\.(?:php|asp|aspx|jsp|json|action|html|js|txt|xml)(?:[?#][^"'\x60\s]*)?` +
+	`)(?:"|'|\x60)`)
+
+// Parameter names passed to URLSearchParams, FormData and the like, and the
+// keys of params objects as used by axios and jQuery.
+var (
+	appendPattern  = regexp.MustCompile(`\.(?:append|set)\(\s*["'\x60]([A-Za-z_][A-Za-z0-9_.\-\[\]]{0,63})["'\x60]\s*,`)
+	paramsPattern  = regexp.MustCompile(`\b(?:params|searchParams|queryParams|query)\s*:\s*\{([^{}]{1,1000})\}`)
+	objectKey      = regexp.MustCompile(`(?:^|[,{\s])["']?([A-Za-z_$][A-Za-z0-9_$\-]{0,63})["']?\s*:`)
+	graphqlPattern = regexp.MustCompile(`\b(query|mutation|subscription)\s+([A-Za-z_][A-Za-z0-9_]*)\s*(\([^)]{0,1000}\))?\s*\{`)
+	variableName   = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)\s*:`)
+	parameterName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-\[\]]{0,63}$`)
+)
+
+// mimePrefixes mark strings such as "application/json" that look like
+// routes but are MIME types.
+var mimePrefixes = []string{"application/", "text/", "image/", "audio/", "video/", "font/", "multipart/", "model/"}
+
+// staticExtensions are files that never take parameters; they are not stored.
+var staticExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".ico": true, ".webp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".eot": true, ".otf": true, ".css": true, ".map": true,
+}
+
+// Result is what Extract finds in a JavaScript file.
+type Result struct {
+	// Endpoints are absolute http(s) URLs without query string or fragment.
+	Endpoints []Endpoint
+	// Params are parameter names that are not tied to a particular endpoint.
+	Params []string
+	// Operations are the GraphQL operations the file sends.
+	Operations []Operation
+}
+
+// Endpoint is a URL referenced by a JavaScript file and the names of the
+// query parameters it was seen with.
+type Endpoint struct {
+	URL    string
+	Params []string
+}
+
+// Operation is a GraphQL query, mutation or subscription.
+type Operation struct {
+	Type      string
+	Name      string
+	Variables []string
+}
+
+// Extract finds the endpoints, parameter names and GraphQL operations in the
+// content of the JavaScript file at jsURL. Relative paths are resolved against
+// the origin of the file, since scripts build them for the page they run on.
+func Extract(content []byte, jsURL *url.URL) Result {
+	// JSON embedded in scripts escapes slashes.
+	text := strings.ReplaceAll(string(content), `\/`, `/`)
+	origin := &url.URL{Scheme: jsURL.Scheme, Host: jsURL.Host, Path: "/"}
+
+	byURL := make(map[string]map[string]bool)
+	for _, m := range endpointPattern.FindAllStringSubmatch(text, -1) {
+		u := resolve(m[1], origin)
+		if u == nil {
+			continue
+		}
+		params := queryParams(u)
+		u.RawQuery, u.Fragment = "", ""
+		key := u.String()
+		if byURL[key] == nil {
+			byURL[key] = make(map[string]bool)
+		}
+		for _, p := range params {
+			byURL[key][p] = true
+		}
+	}
+
+	var res Result
+	for u, params := range byURL {
+		res.Endpoints = append(res.Endpoints, Endpoint{URL: u, Params: sortedKeys(params)})
+	}
+	sort.Slice(res.Endpoints, func(i, j int) bool { return res.Endpoints[i].URL < res.Endpoints[j].URL })
+
+	loose := make(map[string]bool)
+	for _, m := range appendPattern.FindAllStringSubmatch(text, -1) {
+		loose[m[1]] = true
+	}
+	for _, m := range paramsPattern.FindAllStringSubmatch(text, -1) {
+		for _, k := range objectKey.FindAllStringSubmatch(m[1], -1) {
+			if parameterName.MatchString(k[1]) {
+				loose[k[1]] = true
+			}
+		}
+	}
+	res.Params = sortedKeys(loose)
+
+	seen := make(map[string]bool)
+	for _, m := range graphqlPattern.FindAllStringSubmatch(text, -1) {
+		op := Operation{Type: m[1], Name: m[2]}
+		if seen[op.Type+" "+op.Name] {
+			continue
+		}
+		seen[op.Type+" "+op.Name] = true
+		vars := make(map[string]bool)
+		for _, v := range variableName.FindAllStringSubmatch(m[3], -1) {
+			vars[v[1]] = true
+		}
+		op.Variables = sortedKeys(vars)
+		res.Operations = append(res.Operations, op)
+	}
+	return res
+}
+
+// resolve turns a string found in a script into an absolute http(s) URL, or
+// returns nil if it is not one.
+func resolve(raw string, origin *url.URL) *url.URL {
+	// Template literals and concatenations only give the constant prefix.
+	if i := strings.Index(raw, "${"); i >= 0 {
+		raw = raw[:i]
+	}
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 2 || strings.ContainsAny(raw, " \t\r\n\\<>{}") {
+		return nil
+	}
+	// Dates such as 12/31/2024 match the route patterns too.
+	if !strings.ContainsAny(strings.ToLower(raw), "abcdefghijklmnopqrstuvwxyz") {
+		return nil
+	}
+	lower := strings.ToLower(raw)
+	for _, p := range mimePrefixes {
+		if strings.HasPrefix(lower, p) {
+			return nil
+		}
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	u := origin.ResolveReference(ref)
+	if u.Scheme != "http" && u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return nil
+	}
+	if staticExtensions[strings.ToLower(path.Ext(u.Path))] {
+		return nil
+	}
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u
+}
+
+// queryParams returns the names of the query parameters of u.
+func queryParams(u *url.URL) []string {
+	var names []string
+	for name := range u.Query() {
+		if parameterName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsGraphQL reports whether an endpoint looks like a GraphQL API.
+func IsGraphQL(endpoint string) bool {
+	return strings.Contains(strings.ToLower(endpoint), "graphql")
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
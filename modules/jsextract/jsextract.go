@@ -0,0 +1,200 @@
+package jsextract
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"sentinel/modules/checkpoint"
+	"sentinel/modules/config"
+	"sentinel/modules/database"
+	"sentinel/modules/scope"
+	"sentinel/modules/utils"
+
+	"github.com/fatih/color"
+)
+
+// Source is recorded for the URLs and parameters found in JavaScript files.
+const Source = "js-extract"
+
+// downloadTimeout bounds the download of a single JavaScript file.
+const downloadTimeout = 30 * time.Second
+
+// maxFileSize bounds the size of a JavaScript file that is analysed; larger
+// files are skipped rather than read into memory.
+const maxFileSize = 20 << 20
+
+// RunJSExtract downloads every live JavaScript file and stores the endpoints
+// and parameter names it references.
+func RunJSExtract(ctx context.Context, config *config.Config, db *sql.DB) error {
+	options := utils.Options{
+		Output:    config.Workspace,
+		Threads:   config.Recon.Threads,
+		RateLimit: config.RateLimit,
+		Proxy:     config.Proxy,
+	}
+	color.Cyan("[*] Starting JavaScript endpoint extraction phase")
+
+	sc, err := scope.New(config)
+	if err != nil {
+		color.Red("Invalid scope configuration: %v", err)
+		return err
+	}
+
+	utils.Banner("Fetching JavaScript URLs from database")
+	jsURLs, err := database.GetJavaScriptURLs(db)
+	if err != nil {
+		color.Red("Error getting JavaScript URLs from database: %v", err)
+		return err
+	}
+	for urlID, jsURL := range jsURLs {
+		if !sc.Allow("js-extract input", jsURL) {
+			delete(jsURLs, urlID)
+		}
+	}
+	if len(jsURLs) == 0 {
+		color.Yellow("No JavaScript files found in the database to analyse.")
+		return nil
+	}
+	color.Green("Found %d JavaScript files to analyse.", len(jsURLs))
+
+	targets, err := database.GetTargets(db)
+	if err != nil {
+		color.Red("Error getting targets from database: %v", err)
+		return err
+	}
+
+	// When resuming an interrupted run, files that were already analysed are skipped.
+	tracker := checkpoint.For(ctx, "jsextract")
+	// The client uses the configured proxy and spaces out downloads according to the rate limits.
+	client, err := utils.HTTPClient(options, downloadTimeout)
+	if err != nil {
+		color.Red("Invalid proxy configuration: %v", err)
+		return err
+	}
+	urlCount, paramCount := 0, 0
+	for _, jsURL := range jsURLs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if tracker.Done(jsURL) {
+			continue
+		}
+		base, err := url.Parse(jsURL)
+		if err != nil {
+			continue
+		}
+		utils.Log(fmt.Sprintf("Analysing: %s", jsURL))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, jsURL, nil)
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Failed to download %s: %v", jsURL, err))
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			utils.Warn(fmt.Sprintf("Failed to download %s: %v", jsURL, err))
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize+1))
+		resp.Body.Close()
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Failed to read content of %s: %v", jsURL, err))
+			continue
+		}
+		if len(body) > maxFileSize {
+			utils.Warn(fmt.Sprintf("Skipping %s: larger than %d MB", jsURL, maxFileSize>>20))
+			continue
+		}
+
+		urls, params, err := Store(db, sc, targets, base, Extract(body, base))
+		if err != nil {
+			color.Red("Failed to save endpoints from %s: %v", jsURL, err)
+			continue
+		}
+		if urls > 0 || params > 0 {
+			utils.Success(fmt.Sprintf("  [+] %d endpoints and %d parameters in %s", urls, params, jsURL))
+		}
+		urlCount += urls
+		paramCount += params
+		tracker.Mark(jsURL)
+	}
+
+	utils.Success(fmt.Sprintf("JavaScript endpoint extraction phase completed. Stored %d endpoints and %d parameters.", urlCount, paramCount))
+	return nil
+}
+
+// Store saves what Extract found in the file at jsURL: the in-scope endpoints
+// with their query parameters, and the remaining parameter names on the
+// file's origin. GraphQL operations are stored as parameters of the GraphQL
+// endpoints the file references (or of its origin), named <type>:<name> and
+// followed by their variables. It returns how many URLs and parameters it stored.
+func Store(db *sql.DB, sc *scope.Scope, targets map[int]string, jsURL *url.URL, res Result) (int, int, error) {
+	var candidates []string
+	params := make(map[string][]string)
+	var graphql []string
+	for _, ep := range res.Endpoints {
+		candidates = append(candidates, ep.URL)
+		params[ep.URL] = ep.Params
+		if IsGraphQL(ep.URL) {
+			graphql = append(graphql, ep.URL)
+		}
+	}
+
+	origin := (&url.URL{Scheme: jsURL.Scheme, Host: jsURL.Host}).String()
+	var loose []string
+	loose = append(loose, res.Params...)
+	var operations []string
+	for _, op := range res.Operations {
+		operations = append(operations, op.Type+":"+op.Name)
+		operations = append(operations, op.Variables...)
+	}
+	if len(graphql) == 0 {
+		loose = append(loose, operations...)
+	} else {
+		for _, g := range graphql {
+			params[g] = append(params[g], operations...)
+		}
+	}
+	if len(loose) > 0 {
+		if _, found := params[origin]; !found {
+			candidates = append(candidates, origin)
+		}
+		params[origin] = append(params[origin], loose...)
+	}
+
+	urlCount, paramCount := 0, 0
+	for _, raw := range sc.Filter(Source, candidates) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		targetID := scope.TargetFor(u.Hostname(), targets)
+		if targetID == -1 {
+			continue
+		}
+		urlID, err := database.AddURL(db, targetID, raw, Source)
+		if err != nil {
+			return urlCount, paramCount, err
+		}
+		urlCount++
+		seen := make(map[string]bool)
+		for _, name := range params[raw] {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if err := database.AddParameter(db, int(urlID), name, Source); err != nil {
+				return urlCount, paramCount, err
+			}
+			paramCount++
+		}
+	}
+	return urlCount, paramCount, nil
+}
@@ -0,0 +1,32 @@
+package jsextract
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'jsextract'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "jsextract" }
+
+func (Module) Description() string {
+	return "Extract endpoints and parameter names from JavaScript files"
+}
+
+func (Module) RequiredTools() []string { return nil }
+
+func (Module) Inputs() []string { return []string{registry.URLs} }
+
+func (Module) Outputs() []string { return []string{registry.Endpoints, registry.Parameters} }
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunJSExtract(ctx, cfg, db)
+}
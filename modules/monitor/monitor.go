@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sentinel/modules/config"
+	"sentinel/modules/database"
+	"sentinel/modules/utils"
+
+	"github.com/fatih/color"
+)
+
+// RunFunc runs modules as one pipeline recorded under name in the runs table
+// of db, the database of workspace, and returns the run ID. It is provided by
+// the caller so monitor mode shares the dispatch code of 'run'.
+type RunFunc func(ctx context.Context, db *sql.DB, workspace, name string, modules []string) (int64, error)
+
+// Result describes one scheduled run.
+type Result struct {
+	Schedule  string                 `json:"schedule"`
+	Workspace string                 `json:"workspace"`
+	RunID     int64                  `json:"run_id"`
+	Started   time.Time              `json:"started"`
+	Status    string                 `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	Events    []database.ChangeEvent `json:"events"`
+}
+
+type job struct {
+	schedule config.Schedule
+	spec     *Spec
+	next     time.Time
+}
+
+var eventLabels = map[string]string{
+	database.ChangeNewSubdomain:     "New subdomain",
+	database.ChangeNewPort:          "New open port",
+	database.ChangeNewVulnerability: "New vulnerability",
+}
+
+// RunName is the name scheduled runs are recorded under in the runs table.
+func RunName(schedule string) string {
+	return "monitor:" + schedule
+}
+
+// Run executes the schedules of cfg until ctx is cancelled. Runs never
+// overlap: a schedule that becomes due while another one is running starts as
+// soon as it finishes. With once set, every schedule runs a single time,
+// right away, and Run returns. db is the database of the current workspace;
+// schedules of other workspaces open theirs for the duration of their runs.
+func Run(ctx context.Context, cfg *config.Config, db *sql.DB, run RunFunc, once bool) ([]Result, error) {
+	jobs, err := buildJobs(cfg.Monitor.Schedules, cfg.Workspace, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	printSchedules(jobs, once)
+
+	var results []Result
+	if once {
+		for _, j := range jobs {
+			if ctx.Err() != nil {
+				break
+			}
+			results = append(results, runJob(ctx, cfg.Workspace, db, j, run))
+		}
+		return results, nil
+	}
+
+	for {
+		sort.SliceStable(jobs, func(a, b int) bool { return jobs[a].next.Before(jobs[b].next) })
+		j := jobs[0]
+		utils.Log(fmt.Sprintf("Next run: '%s' at %s.", j.schedule.Name, j.next.Format("2006-01-02 15:04")))
+
+		timer := time.NewTimer(time.Until(j.next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			utils.Log("Monitor stopped.")
+			return results, nil
+		case <-timer.C:
+		}
+
+		results = append(results, runJob(ctx, cfg.Workspace, db, j, run))
+		if ctx.Err() != nil {
+			utils.Log("Monitor stopped.")
+			return results, nil
+		}
+		// Runs that were missed while this one was busy are skipped, not queued.
+		j.next = j.spec.Next(time.Now())
+	}
+}
+
+func buildJobs(schedules []config.Schedule, current string, now time.Time) ([]*job, error) {
+	if len(schedules) == 0 {
+		return nil, fmt.Errorf("no schedules configured; add them under 'monitor.schedules' in %s", config.ConfigFileName)
+	}
+	seen := make(map[string]bool)
+	var jobs []*job
+	var problems []string
+	for i, s := range schedules {
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("schedule %s has no name", name))
+		} else if seen[name] {
+			problems = append(problems, fmt.Sprintf("schedule '%s' is defined twice", name))
+		}
+		seen[name] = true
+		if len(s.Modules) == 0 {
+			problems = append(problems, fmt.Sprintf("schedule '%s' has no modules", name))
+		}
+		spec, err := Parse(s.Cron)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("schedule '%s': %v", name, err))
+			continue
+		}
+		next := spec.Next(now)
+		if next.IsZero() {
+			problems = append(problems, fmt.Sprintf("schedule '%s': '%s' never matches", name, s.Cron))
+			continue
+		}
+		if s.Workspace == "" {
+			s.Workspace = current
+		}
+		jobs = append(jobs, &job{schedule: s, spec: spec, next: next})
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid monitor configuration: %s", strings.Join(problems, "; "))
+	}
+	return jobs, nil
+}
+
+func printSchedules(jobs []*job, once bool) {
+	utils.Banner("Monitor Schedules")
+	for _, j := range jobs {
+		next := j.next.Format("2006-01-02 15:04")
+		if once {
+			next = "now"
+		}
+		fmt.Printf("  %-16s %-16s %-24s %-16s next: %s\n", j.schedule.Name, j.schedule.Cron, strings.Join(j.schedule.Modules, ","), j.schedule.Workspace, next)
+	}
+	fmt.Println()
+}
+
+func runJob(ctx context.Context, current string, db *sql.DB, j *job, run RunFunc) Result {
+	workspace := j.schedule.Workspace
+	res := Result{Schedule: j.schedule.Name, Workspace: workspace, Started: time.Now(), Status: "ok"}
+	utils.Banner(fmt.Sprintf("Scheduled run '%s' in '%s': %s", j.schedule.Name, workspace, strings.Join(j.schedule.Modules, ", ")))
+
+	if filepath.Clean(workspace) != filepath.Clean(current) {
+		jobDB, err := database.Open(workspace)
+		if err != nil {
+			res.Status, res.Error = "failed", err.Error()
+			utils.Error(fmt.Sprintf("Scheduled run '%s' failed: could not open workspace '%s'", j.schedule.Name, workspace), err)
+			return res
+		}
+		defer jobDB.Close()
+		db = jobDB
+	}
+
+	lastEvent, err := database.LastAssetEventID(db)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not read asset history: %v", err))
+	}
+	runID, err := run(ctx, db, workspace, RunName(j.schedule.Name), j.schedule.Modules)
+	res.RunID = runID
+	if err != nil {
+		res.Status, res.Error = "failed", err.Error()
+		utils.Error(fmt.Sprintf("Scheduled run '%s' failed", j.schedule.Name), err)
+	}
+	if runID == 0 {
+		return res
+	}
+
+	// Change events are raised even for failed runs, for whatever was found before the failure.
+	events, err := database.RecordChangeEvents(db, runID, lastEvent)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not record change events for run %d: %v", runID, err))
+	}
+	res.Events = events
+	for _, e := range events {
+		fmt.Printf("  %s %s: %s\n", color.GreenString("[+]"), eventLabels[e.Kind], e.Asset)
+	}
+	utils.Success(fmt.Sprintf("Scheduled run '%s' finished with %d change event(s).", j.schedule.Name, len(events)))
+	return res
+}
@@ -0,0 +1,240 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"sentinel/modules/config"
+	"sentinel/modules/utils"
+)
+
+// Event kinds. The asset kinds match the change events recorded by monitor mode.
+const (
+	KindVulnerability = "vulnerability"
+	KindSecret        = "secret"
+	KindNewSubdomain  = "new_subdomain"
+	KindNewPort       = "new_port"
+	KindTest          = "test"
+)
+
+// Event is a single notification. Items holds the assets of a batched event,
+// such as every new subdomain found for a target in one recon pass.
+type Event struct {
+	Kind      string    `json:"kind"`
+	Severity  string    `json:"severity"`
+	Title     string    `json:"title"`
+	Target    string    `json:"target,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	Items     []string  `json:"items,omitempty"`
+	Workspace string    `json:"workspace"`
+	Time      time.Time `json:"time"`
+}
+
+// DefaultTemplate renders the message body when a sink does not define one.
+const DefaultTemplate = `[{{upper .Severity}}] {{.Title}}
+{{- if .URL}}
+URL: {{.URL}}{{end}}
+{{- if .Details}}
+{{.Details}}{{end}}
+{{- range .Items}}
+  - {{.}}{{end}}
+Workspace: {{.Workspace}}`
+
+// maxItems caps how many assets of a batched event are listed in a message.
+const maxItems = 50
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+}
+
+// sender delivers a rendered message for an event.
+type sender interface {
+	send(ctx context.Context, ev Event, subject, message string) error
+}
+
+type sink struct {
+	name        string
+	minSeverity int
+	events      map[string]bool
+	tmpl        *template.Template
+	sender      sender
+}
+
+// Notifier fans events out to the configured sinks. A nil Notifier, or one
+// without sinks, silently drops every event.
+type Notifier struct {
+	workspace string
+	sinks     []*sink
+}
+
+// New builds a Notifier from the notify section of the configuration.
+func New(cfg *config.Config) (*Notifier, error) {
+	n := &Notifier{workspace: cfg.Workspace}
+	for i, sc := range cfg.Notify.Sinks {
+		name := sc.Name
+		if name == "" {
+			name = fmt.Sprintf("%s #%d", sc.Type, i+1)
+		}
+		s, err := newSink(name, sc)
+		if err != nil {
+			return nil, fmt.Errorf("notification sink '%s': %w", name, err)
+		}
+		n.sinks = append(n.sinks, s)
+	}
+	return n, nil
+}
+
+// Load is New for modules: an invalid notify configuration is reported once
+// and notifications are disabled instead of failing the module.
+func Load(cfg *config.Config) *Notifier {
+	n, err := New(cfg)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Notifications disabled: %v", err))
+		return nil
+	}
+	return n
+}
+
+func newSink(name string, sc config.NotifySink) (*sink, error) {
+	s := &sink{name: name}
+	if sc.MinSeverity != "" {
+		if s.minSeverity = utils.SeverityRank(sc.MinSeverity); s.minSeverity < 0 {
+			return nil, fmt.Errorf("invalid min_severity '%s'", sc.MinSeverity)
+		}
+	}
+	if len(sc.Events) > 0 {
+		s.events = make(map[string]bool)
+		for _, e := range sc.Events {
+			s.events[e] = true
+		}
+	}
+
+	text := sc.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	s.tmpl = tmpl
+
+	switch sc.Type {
+	case "webhook":
+		s.sender, err = newWebhook(sc)
+	case "smtp":
+		s.sender, err = newSMTP(sc)
+	case "command":
+		s.sender, err = newCommand(sc)
+	default:
+		err = fmt.Errorf("unknown type '%s' (expected webhook, smtp or command)", sc.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sink) accepts(ev Event) bool {
+	if s.events != nil && !s.events[ev.Kind] {
+		return false
+	}
+	return utils.SeverityRank(ev.Severity) >= s.minSeverity
+}
+
+// Enabled reports whether any sink is configured.
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.sinks) > 0
+}
+
+// Send delivers ev to every sink whose filters accept it. Delivery failures
+// are logged and returned, but never stop the caller's work.
+func (n *Notifier) Send(ctx context.Context, ev Event) error {
+	if !n.Enabled() {
+		return nil
+	}
+	if ev.Workspace == "" {
+		ev.Workspace = n.workspace
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if len(ev.Items) > maxItems {
+		more := len(ev.Items) - maxItems
+		ev.Items = append(ev.Items[:maxItems:maxItems], fmt.Sprintf("... and %d more", more))
+	}
+
+	var failed []string
+	for _, s := range n.sinks {
+		if !s.accepts(ev) {
+			continue
+		}
+		if err := s.deliver(ctx, ev); err != nil {
+			utils.Warn(fmt.Sprintf("Notification to sink '%s' failed: %v", s.name, err))
+			failed = append(failed, s.name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("notification failed for sink(s): %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (s *sink) deliver(ctx context.Context, ev Event) error {
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, ev); err != nil {
+		return fmt.Errorf("could not render template: %w", err)
+	}
+	subject := fmt.Sprintf("[Sentinel] [%s] %s", strings.ToUpper(ev.Severity), ev.Title)
+	return s.sender.send(ctx, ev, subject, buf.String())
+}
+
+// Test sends a test event to every sink, or only to the one called name,
+// ignoring their filters. It returns the names of the sinks it reached.
+func (n *Notifier) Test(ctx context.Context, name string) ([]string, error) {
+	if !n.Enabled() {
+		return nil, fmt.Errorf("no notification sinks configured")
+	}
+	ev := Event{
+		Kind:      KindTest,
+		Severity:  "info",
+		Title:     "Test notification",
+		Details:   "Notifications from Sentinel are working.",
+		Workspace: n.workspace,
+		Time:      time.Now(),
+	}
+	var reached, failed []string
+	for _, s := range n.sinks {
+		if name != "" && s.name != name {
+			continue
+		}
+		if err := s.deliver(ctx, ev); err != nil {
+			utils.Warn(fmt.Sprintf("Notification to sink '%s' failed: %v", s.name, err))
+			failed = append(failed, s.name)
+			continue
+		}
+		reached = append(reached, s.name)
+	}
+	if name != "" && len(reached)+len(failed) == 0 {
+		return nil, fmt.Errorf("no notification sink named '%s'", name)
+	}
+	if len(failed) > 0 {
+		return reached, fmt.Errorf("notification failed for sink(s): %s", strings.Join(failed, ", "))
+	}
+	return reached, nil
+}
+
+// SecretSeverity is the severity used for secret events: verified secrets are critical.
+func SecretSeverity(verified bool) string {
+	if verified {
+		return "critical"
+	}
+	return "high"
+}
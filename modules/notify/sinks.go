@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sentinel/modules/config"
+)
+
+const sendTimeout = 30 * time.Second
+
+// webhook posts a JSON message to Slack, Discord, Teams or any HTTP endpoint.
+type webhook struct {
+	url    string
+	format string
+	client *http.Client
+}
+
+func newWebhook(sc config.NotifySink) (sender, error) {
+	if sc.URL == "" {
+		return nil, fmt.Errorf("webhook sinks need a url")
+	}
+	format := sc.Format
+	if format == "" {
+		format = "json"
+	}
+	switch format {
+	case "slack", "discord", "teams", "json":
+	default:
+		return nil, fmt.Errorf("unknown webhook format '%s' (expected slack, discord, teams or json)", format)
+	}
+	return &webhook{url: sc.URL, format: format, client: &http.Client{Timeout: sendTimeout}}, nil
+}
+
+func (w *webhook) send(ctx context.Context, ev Event, subject, message string) error {
+	var payload any
+	switch w.format {
+	case "slack":
+		payload = map[string]string{"text": message}
+	case "discord":
+		// Discord rejects messages longer than 2000 characters. Cutting runes
+		// rather than bytes keeps a multi-byte character from being split.
+		if utf8.RuneCountInString(message) > 2000 {
+			message = string([]rune(message)[:1996]) + "\n..."
+		}
+		payload = map[string]string{"content": message}
+	case "teams":
+		payload = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "http://schema.org/extensions",
+			"summary":  subject,
+			"title":    subject,
+			"text":     strings.ReplaceAll(message, "\n", "<br>"),
+		}
+	default:
+		payload = struct {
+			Event
+			Message string `json:"message"`
+		}{ev, message}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// mailer sends the message as a plain text email.
+type mailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func newSMTP(sc config.NotifySink) (sender, error) {
+	m := &mailer{
+		host:     sc.SMTP.Host,
+		port:     sc.SMTP.Port,
+		username: sc.SMTP.Username,
+		password: sc.SMTP.Password,
+		from:     sc.SMTP.From,
+		to:       sc.SMTP.To,
+	}
+	if m.host == "" || m.from == "" || len(m.to) == 0 {
+		return nil, fmt.Errorf("smtp sinks need smtp.host, smtp.from and smtp.to")
+	}
+	if m.port == 0 {
+		m.port = 587
+	}
+	return m, nil
+}
+
+// headerValue makes text safe for a mail header: line breaks, which would
+// start new headers, are folded into spaces and non-ASCII text is Q-encoded.
+func headerValue(text string) string {
+	return mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(text), " "))
+}
+
+func (m *mailer) send(ctx context.Context, _ Event, subject, message string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	var conn net.Conn
+	var err error
+	if m.port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: m.host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && m.port != 465 {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	for _, rcpt := range m.to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// command runs a local program with the event as JSON on stdin and the
+// rendered message in SENTINEL_MESSAGE.
+type command struct {
+	argv []string
+}
+
+func newCommand(sc config.NotifySink) (sender, error) {
+	if len(sc.Command) == 0 {
+		return nil, fmt.Errorf("command sinks need a command")
+	}
+	return &command{argv: sc.Command}, nil
+}
+
+func (c *command) send(ctx context.Context, ev Event, subject, message string) error {
+	payload, err := json.Marshal(struct {
+		Event
+		Message string `json:"message"`
+	}{ev, message})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.argv[0], c.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"SENTINEL_EVENT_KIND="+ev.Kind,
+		"SENTINEL_SEVERITY="+ev.Severity,
+		"SENTINEL_SUBJECT="+subject,
+		"SENTINEL_MESSAGE="+message,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+// Package nvd enriches vulnerabilities with the CVSS scores, CWE IDs and
+// references of their CVEs, read from NVD JSON feeds stored in the workspace.
+// No network access is involved: the feeds are downloaded separately.
+package nvd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sentinel/modules/database"
+	"sentinel/modules/utils"
+)
+
+// Dir is the directory of a workspace the feeds are read from. Both the 1.1
+// feeds (nvdcve-1.1-2021.json) and CVE API 2.0 responses are read, plain or
+// gzipped.
+const Dir = "nvd"
+
+// Feeds returns the feed files of a workspace, or nothing when it has none.
+func Feeds(workspace string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(workspace, Dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not list NVD feeds: %w", err)
+	}
+	var feeds []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && (strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz")) {
+			feeds = append(feeds, filepath.Join(workspace, Dir, name))
+		}
+	}
+	sort.Strings(feeds)
+	return feeds, nil
+}
+
+// Enrich merges what the feeds of a workspace know about the CVEs of its
+// vulnerabilities into their classification, and returns the number of
+// vulnerabilities that gained something. It does nothing without feeds.
+func Enrich(db *sql.DB, workspace string) (int, error) {
+	feeds, err := Feeds(workspace)
+	if err != nil || len(feeds) == 0 {
+		return 0, err
+	}
+	vulns, err := database.VulnerabilityCVEs(db)
+	if err != nil || len(vulns) == 0 {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, v := range vulns {
+		for _, id := range v.CVEIDs {
+			wanted[id] = true
+		}
+	}
+	found := make(map[string]database.Classification)
+	for _, feed := range feeds {
+		if err := readFeed(feed, wanted, found); err != nil {
+			return 0, fmt.Errorf("could not read NVD feed %s: %w", feed, err)
+		}
+	}
+
+	enriched := 0
+	for _, v := range vulns {
+		c := v.Classification
+		for _, id := range v.CVEIDs {
+			if entry, ok := found[id]; ok {
+				c = c.Merge(entry)
+			}
+		}
+		changed, err := database.EnrichVulnerability(db, v.ID, c)
+		if err != nil {
+			return enriched, fmt.Errorf("could not enrich vulnerability %d: %w", v.ID, err)
+		}
+		if changed {
+			enriched++
+		}
+	}
+	if enriched > 0 {
+		utils.Log(fmt.Sprintf("Enriched %d vulnerabilities from the NVD feeds in %s.", enriched, filepath.Join(workspace, Dir)))
+	}
+	return enriched, nil
+}
+
+// readFeed adds the wanted CVEs of a feed to found. Feeds hold a year of CVEs
+// or more, so the items are decoded one at a time rather than all at once.
+func readFeed(path string, wanted map[string]bool, found map[string]database.Classification) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = bufio.NewReader(f)
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var decode func(*json.Decoder) (string, database.Classification, error)
+		switch tok {
+		case "CVE_Items":
+			decode = decodeItem[legacyItem]
+		case "vulnerabilities":
+			decode = decodeItem[apiItem]
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			id, c, err := decode(dec)
+			if err != nil {
+				return err
+			}
+			if wanted[id] {
+				found[id] = c
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("unexpected %v where %v was expected", tok, delim)
+	}
+	return nil
+}
+
+// item is a CVE of one of the feed formats.
+type item interface {
+	classification() (string, database.Classification)
+}
+
+func decodeItem[T item](dec *json.Decoder) (string, database.Classification, error) {
+	var it T
+	if err := dec.Decode(&it); err != nil {
+		return "", database.Classification{}, err
+	}
+	id, c := it.classification()
+	return strings.ToUpper(id), c, nil
+}
+
+// cvss is a CVSS score with its vector, as both formats write it.
+type cvss struct {
+	BaseScore    float64 `json:"baseScore"`
+	VectorString string  `json:"vectorString"`
+}
+
+// weakness keeps the CWE IDs among the values of a problem type or weakness,
+// leaving out NVD-CWE-Other and NVD-CWE-noinfo.
+func weakness(values []string) []string {
+	var ids []string
+	for _, v := range values {
+		if strings.HasPrefix(strings.ToUpper(v), "CWE-") {
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
+
+// legacyItem is an entry of CVE_Items in the 1.1 feeds.
+type legacyItem struct {
+	CVE struct {
+		Meta struct {
+			ID string `json:"ID"`
+		} `json:"CVE_data_meta"`
+		ProblemType struct {
+			Data []struct {
+				Description []struct {
+					Value string `json:"value"`
+				} `json:"description"`
+			} `json:"problemtype_data"`
+		} `json:"problemtype"`
+		References struct {
+			Data []struct {
+				URL string `json:"url"`
+			} `json:"reference_data"`
+		} `json:"references"`
+	} `json:"cve"`
+	Impact struct {
+		V3 struct {
+			CVSS cvss `json:"cvssV3"`
+		} `json:"baseMetricV3"`
+		V2 struct {
+			CVSS cvss `json:"cvssV2"`
+		} `json:"baseMetricV2"`
+	} `json:"impact"`
+}
+
+func (it legacyItem) classification() (string, database.Classification) {
+	c := database.Classification{CVEIDs: []string{it.CVE.Meta.ID}}
+	var values []string
+	for _, d := range it.CVE.ProblemType.Data {
+		for _, v := range d.Description {
+			values = append(values, v.Value)
+		}
+	}
+	c.CWEIDs = weakness(values)
+	for _, r := range it.CVE.References.Data {
+		c.References = append(c.References, r.URL)
+	}
+	score := it.Impact.V3.CVSS
+	if score.VectorString == "" {
+		score = it.Impact.V2.CVSS
+	}
+	c.CVSSScore, c.CVSSMetrics = score.BaseScore, score.VectorString
+	return it.CVE.Meta.ID, c.Normalize()
+}
+
+// apiMetric is a CVSS metric of a CVE API 2.0 response.
+type apiMetric struct {
+	Type     string `json:"type"`
+	CVSSData cvss   `json:"cvssData"`
+}
+
+// apiItem is an entry of vulnerabilities in CVE API 2.0 responses.
+type apiItem struct {
+	CVE struct {
+		ID         string `json:"id"`
+		Weaknesses []struct {
+			Description []struct {
+				Value string `json:"value"`
+			} `json:"description"`
+		} `json:"weaknesses"`
+		References []struct {
+			URL string `json:"url"`
+		} `json:"references"`
+		Metrics map[string][]apiMetric `json:"metrics"`
+	} `json:"cve"`
+}
+
+// apiMetricVersions are the metrics of API 2.0 responses in order of
+// preference, the CVSS versions nuclei templates use first.
+var apiMetricVersions = []string{"cvssMetricV31", "cvssMetricV30", "cvssMetricV40", "cvssMetricV2"}
+
+func (it apiItem) classification() (string, database.Classification) {
+	c := database.Classification{CVEIDs: []string{it.CVE.ID}}
+	var values []string
+	for _, w := range it.CVE.Weaknesses {
+		for _, v := range w.Description {
+			values = append(values, v.Value)
+		}
+	}
+	c.CWEIDs = weakness(values)
+	for _, r := range it.CVE.References {
+		c.References = append(c.References, r.URL)
+	}
+	for _, version := range apiMetricVersions {
+		metrics := it.CVE.Metrics[version]
+		if len(metrics) == 0 {
+			continue
+		}
+		// The NVD's own ("Primary") score is preferred over those of other sources.
+		m := metrics[0]
+		for _, candidate := range metrics {
+			if candidate.Type == "Primary" {
+				m = candidate
+				break
+			}
+		}
+		c.CVSSScore, c.CVSSMetrics = m.CVSSData.BaseScore, m.CVSSData.VectorString
+		break
+	}
+	return it.CVE.ID, c.Normalize()
+}
@@ -0,0 +1,32 @@
+package params
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'params'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "params" }
+
+func (Module) Description() string {
+	return "Discover hidden parameters on known endpoints"
+}
+
+func (Module) RequiredTools() []string { return []string{"arjun"} }
+
+func (Module) Inputs() []string { return []string{registry.LiveURLs} }
+
+func (Module) Outputs() []string { return []string{registry.Parameters} }
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunParams(ctx, cfg, db)
+}
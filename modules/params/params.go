@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"strings"
 
+	"sentinel/modules/checkpoint"
 	"sentinel/modules/config"
 	"sentinel/modules/database"
+	"sentinel/modules/scope"
 	"sentinel/modules/utils"
 	"github.com/fatih/color"
 )
@@ -18,37 +20,60 @@ type ArjunOutput struct {
 	Parameters map[string][]string `json:"parameters"`
 }
 
-func RunParams(ctx context.Context, config *config.Config, db *sql.DB) {
+// RunParams runs arjun against every live URL and stores discovered parameters.
+func RunParams(ctx context.Context, config *config.Config, db *sql.DB) error {
 	options := utils.Options{
-		Output:  config.Workspace,
-		Threads: config.Recon.Threads,
+		Output:    config.Workspace,
+		Threads:   config.Recon.Threads,
+		RateLimit: config.RateLimit,
+		Proxy:     config.Proxy,
 	}
 	color.Cyan("[*] Starting Parameter discovery phase")
 
+	sc, err := scope.New(config)
+	if err != nil {
+		color.Red("Invalid scope configuration: %v", err)
+		return err
+	}
+
 	if !utils.CommandExists("arjun") {
 		color.Red("arjun not found. Please install it first.")
 		color.Yellow("Hint: pip3 install arjun")
-		return
+		return fmt.Errorf("arjun not found")
 	}
 
 	utils.Banner("Fetching live URLs from database for parameter discovery")
 	urls, err := database.GetLiveURLsAsMap(db)
 	if err != nil {
 		color.Red("Error getting URLs from database: %v", err)
-		return
+		return err
+	}
+
+	for urlStr := range urls {
+		if !sc.Allow("arjun input", urlStr) {
+			delete(urls, urlStr)
+		}
 	}
 
 	if len(urls) == 0 {
 		color.Yellow("No live URLs found in the database to scan for parameters.")
-		return
+		return nil
 	}
 	color.Green("Found %d live URLs to scan for parameters.", len(urls))
 
+	// When resuming an interrupted run, URLs that were already scanned are skipped.
+	tracker := checkpoint.For(ctx, "params")
 	paramsFoundCount := 0
 	for urlStr, urlID := range urls {
+		if tracker.Done(urlStr) {
+			continue
+		}
 		utils.Log(fmt.Sprintf("Scanning: %s", urlStr))
 
 		output, err := utils.RunCommandAndCapture(ctx, options, "arjun", "-u", urlStr, "-oJ", "/dev/stdout", "--stable")
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			if len(output) == 0 {
 				utils.Warn(fmt.Sprintf("Error running arjun on %s: %v", urlStr, err))
@@ -56,6 +81,8 @@ func RunParams(ctx context.Context, config *config.Config, db *sql.DB) {
 			}
 		}
 
+		tracker.Mark(urlStr)
+
 		jsonStartIndex := strings.Index(output, "{")
 		if jsonStartIndex == -1 {
 			continue
@@ -77,4 +104,5 @@ func RunParams(ctx context.Context, config *config.Config, db *sql.DB) {
 	}
 
 	utils.Success(fmt.Sprintf("Parameter discovery phase completed. Found %d new parameters.", paramsFoundCount))
+	return nil
 } 
\ No newline at end of file
@@ -0,0 +1,270 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"sentinel/modules/checkpoint"
+	"sentinel/modules/config"
+	"sentinel/modules/database"
+	"sentinel/modules/registry"
+	"sentinel/modules/utils"
+
+	"github.com/fatih/color"
+)
+
+// DefaultConcurrency is used when pipeline.concurrency is not set in config.yaml.
+const DefaultConcurrency = 3
+
+// Stage statuses reported in a Result.
+const (
+	StatusOK        = "ok"
+	StatusDone      = "done" // completed in an earlier attempt of a resumed run
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	StatusCancelled = "cancelled"
+)
+
+// Result describes how a single stage of a pipeline run ended.
+type Result struct {
+	Name     string
+	Status   string
+	Err      error
+	Started  time.Time
+	Duration time.Duration
+}
+
+type stage struct {
+	module     registry.Module
+	deps       []string
+	dependants []string
+}
+
+// Pipeline is a set of modules ordered as a DAG: a module depends on every
+// other module in the pipeline that produces one of its inputs.
+type Pipeline struct {
+	stages map[string]*stage
+	order  []string // topological order, used for starting and reporting
+}
+
+// Build resolves the named modules from the registry and computes their dependencies.
+func Build(names []string) (*Pipeline, error) {
+	p := &Pipeline{stages: make(map[string]*stage)}
+	var requested []string
+	for _, name := range names {
+		if _, dup := p.stages[name]; dup {
+			continue
+		}
+		m, ok := registry.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown module: %s", name)
+		}
+		p.stages[name] = &stage{module: m}
+		requested = append(requested, name)
+	}
+
+	producers := make(map[string][]string)
+	for _, name := range requested {
+		for _, out := range p.stages[name].module.Outputs() {
+			producers[out] = append(producers[out], name)
+		}
+	}
+	for _, name := range requested {
+		st := p.stages[name]
+		seen := make(map[string]bool)
+		for _, in := range st.module.Inputs() {
+			for _, producer := range producers[in] {
+				if producer == name || seen[producer] {
+					continue
+				}
+				seen[producer] = true
+				st.deps = append(st.deps, producer)
+				p.stages[producer].dependants = append(p.stages[producer].dependants, name)
+			}
+		}
+	}
+
+	// Kahn's algorithm; the requested order breaks ties so output stays predictable.
+	remaining := make(map[string]int)
+	for _, name := range requested {
+		remaining[name] = len(p.stages[name].deps)
+	}
+	for len(p.order) < len(requested) {
+		var ready []string
+		for _, name := range requested {
+			if remaining[name] == 0 {
+				ready = append(ready, name)
+			}
+		}
+		for _, name := range ready {
+			remaining[name] = -1
+			p.order = append(p.order, name)
+			for _, dep := range p.stages[name].dependants {
+				remaining[dep]--
+			}
+		}
+		if len(ready) == 0 {
+			var cyclic []string
+			for _, name := range requested {
+				if remaining[name] > 0 {
+					cyclic = append(cyclic, name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between modules: %s", strings.Join(cyclic, ", "))
+		}
+	}
+	return p, nil
+}
+
+// Order returns the stage names in dependency order.
+func (p *Pipeline) Order() []string {
+	return append([]string(nil), p.order...)
+}
+
+// Run executes the pipeline, starting each stage as soon as its dependencies
+// have succeeded and running at most concurrency stages at once. Dependants of
+// a failed stage are skipped. Results are returned in dependency order.
+func (p *Pipeline) Run(ctx context.Context, cfg *config.Config, db *sql.DB, concurrency int) []Result {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	results := make(map[string]Result)
+	pending := make(map[string]int)
+	for name, st := range p.stages {
+		pending[name] = len(st.deps)
+	}
+
+	done := make(chan Result)
+	sem := make(chan struct{}, concurrency)
+	running := 0
+	launch := func(name string) {
+		running++
+		go func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			done <- p.runStage(ctx, cfg, db, name)
+		}()
+	}
+
+	var skip func(name, reason string)
+	skip = func(name, reason string) {
+		if _, finished := results[name]; finished {
+			return
+		}
+		utils.Warn(fmt.Sprintf("Skipping '%s': %s", name, reason))
+		results[name] = Result{Name: name, Status: StatusSkipped, Err: fmt.Errorf("%s", reason)}
+		checkpoint.FromContext(ctx).SetModuleStatus(name, database.RunSkipped, results[name].Err)
+		for _, dep := range p.stages[name].dependants {
+			skip(dep, fmt.Sprintf("prerequisite '%s' was skipped", name))
+		}
+	}
+
+	for _, name := range p.order {
+		if pending[name] == 0 {
+			launch(name)
+		}
+	}
+	for running > 0 {
+		res := <-done
+		running--
+		results[res.Name] = res
+		for _, dep := range p.stages[res.Name].dependants {
+			if _, finished := results[dep]; finished {
+				continue
+			}
+			if !succeeded(res) {
+				skip(dep, fmt.Sprintf("prerequisite '%s' %s", res.Name, res.Status))
+				continue
+			}
+			pending[dep]--
+			if pending[dep] == 0 {
+				launch(dep)
+			}
+		}
+	}
+
+	ordered := make([]Result, 0, len(p.order))
+	for _, name := range p.order {
+		ordered = append(ordered, results[name])
+	}
+	return ordered
+}
+
+func (p *Pipeline) runStage(ctx context.Context, cfg *config.Config, db *sql.DB, name string) (res Result) {
+	res = Result{Name: name, Started: time.Now()}
+	defer func() { res.Duration = time.Since(res.Started) }()
+
+	if ctx.Err() != nil {
+		res.Status, res.Err = StatusCancelled, ctx.Err()
+		return res
+	}
+	run := checkpoint.FromContext(ctx)
+	if run.ModuleDone(name) {
+		utils.Log(fmt.Sprintf("Stage '%s' already completed in run %d, skipping.", name, run.ID))
+		res.Status = StatusDone
+		return res
+	}
+	m := p.stages[name].module
+	if missing := registry.MissingTools(m); len(missing) > 0 {
+		res.Status, res.Err = StatusFailed, fmt.Errorf("missing required tools: %s", strings.Join(missing, ", "))
+		utils.Error(fmt.Sprintf("Cannot run '%s'", name), res.Err)
+		run.SetModuleStatus(name, database.RunFailed, res.Err)
+		return res
+	}
+
+	utils.Log(fmt.Sprintf("Stage '%s' started.", name))
+	run.SetModuleStatus(name, database.RunRunning, nil)
+	err := m.Run(ctx, cfg, db)
+	switch {
+	case ctx.Err() != nil:
+		res.Status, res.Err = StatusCancelled, ctx.Err()
+		run.SetModuleStatus(name, database.RunInterrupted, res.Err)
+	case err != nil:
+		res.Status, res.Err = StatusFailed, err
+		run.SetModuleStatus(name, database.RunFailed, err)
+	default:
+		res.Status = StatusOK
+		run.SetModuleStatus(name, database.RunCompleted, nil)
+	}
+	utils.Log(fmt.Sprintf("Stage '%s' finished (%s).", name, res.Status))
+	return res
+}
+
+// Failed returns the names of stages that did not complete successfully.
+func Failed(results []Result) []string {
+	var failed []string
+	for _, r := range results {
+		if !succeeded(r) {
+			failed = append(failed, r.Name)
+		}
+	}
+	return failed
+}
+
+func succeeded(r Result) bool {
+	return r.Status == StatusOK || r.Status == StatusDone
+}
+
+// PrintSummary prints one line per stage with its status and duration.
+func PrintSummary(results []Result, elapsed time.Duration) {
+	utils.Banner("Pipeline Summary")
+	for _, r := range results {
+		status := color.GreenString("%-9s", r.Status)
+		switch r.Status {
+		case StatusFailed:
+			status = color.RedString("%-9s", r.Status)
+		case StatusSkipped, StatusCancelled:
+			status = color.YellowString("%-9s", r.Status)
+		}
+		line := fmt.Sprintf("  %-12s %s %10s", r.Name, status, r.Duration.Round(time.Millisecond))
+		if r.Err != nil {
+			line += "  " + color.HiBlackString(r.Err.Error())
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("\n  Total wall time: %s\n\n", elapsed.Round(time.Millisecond))
+}
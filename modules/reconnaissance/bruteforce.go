@@ -0,0 +1,280 @@
+package reconnaissance
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sentinel/modules/config"
+	"sentinel/modules/resolver"
+	"sentinel/modules/scope"
+	"sentinel/modules/utils"
+)
+
+// Sources recorded for subdomains found by active discovery.
+const (
+	sourceBruteforce  = "bruteforce"
+	sourcePermutation = "permutation"
+)
+
+// defaultMaxCandidates caps the names resolved per target when
+// recon.bruteforce.max_candidates is not set.
+const defaultMaxCandidates = 50000
+
+// defaultSubdomainWordlists are tried in order when recon.bruteforce.wordlist
+// is not set: the list installed with the package, then the one in the source tree.
+var defaultSubdomainWordlists = []string{
+	"/usr/share/sentinel/wordlists/subdomains.txt",
+	"wordlists/subdomains.txt",
+}
+
+// permutationWords are combined with known subdomains when
+// recon.bruteforce.words is not set.
+var permutationWords = []string{
+	"dev", "development", "staging", "stage", "test", "qa", "uat", "preprod", "prod",
+	"internal", "int", "corp", "admin", "api", "beta", "demo", "sandbox",
+	"old", "new", "backup", "v1", "v2", "1", "2",
+}
+
+// runBruteforce discovers subdomains of target that passive sources miss. It
+// resolves <word>.<target> for every word of the wordlist and altdns-style
+// permutations of the known subdomains, and discards names that only resolve
+// because of wildcard DNS. Permutations are made of the passive results and
+// the known subdomains; only the passive results are not resolved again, so
+// that names found actively in earlier runs are seen again. It returns the
+// names found with the source that records how they were found, and the
+// sources all of whose candidates were resolved: only their earlier finds
+// that are missing now can be taken as gone.
+func runBruteforce(ctx context.Context, target string, passive, known []string, options utils.Options, cfg *config.Config, sc *scope.Scope) (map[string]string, []string, error) {
+	bf := cfg.Recon.Bruteforce
+	if !bf.Enabled && !bf.Permutations {
+		return nil, nil, nil
+	}
+	utils.Banner("Running Active Subdomain Discovery (brute-force and permutations)")
+
+	domain := strings.ToLower(strings.TrimPrefix(target, "*."))
+	if net.ParseIP(domain) != nil || strings.ContainsAny(domain, "/:") || !validName(domain) {
+		utils.Log(fmt.Sprintf("Skipping active subdomain discovery: %s is not a domain.", target))
+		return nil, nil, nil
+	}
+
+	seen := map[string]bool{domain: true}
+	for _, sub := range passive {
+		seen[normalizeName(sub)] = true
+	}
+	var seeds []string
+	seeded := make(map[string]bool)
+	for _, sub := range append(passive, known...) {
+		sub = normalizeName(sub)
+		if !seeded[sub] && strings.HasSuffix(sub, "."+domain) {
+			seeded[sub] = true
+			seeds = append(seeds, sub)
+		}
+	}
+
+	max := bf.MaxCandidates
+	if max <= 0 {
+		max = defaultMaxCandidates
+	}
+	sources := make(map[string]string)
+	var candidates []string
+	// complete records the sources that ran; a source loses its place when
+	// some of its candidates are left out.
+	complete := make(map[string]bool)
+	truncated := false
+	add := func(name, source string) {
+		if seen[name] || !validName(name) {
+			return
+		}
+		seen[name] = true
+		if len(candidates) >= max {
+			truncated = true
+			complete[source] = false
+			return
+		}
+		if !sc.Allow(source, name) {
+			return
+		}
+		sources[name] = source
+		candidates = append(candidates, name)
+	}
+
+	if bf.Enabled {
+		words, err := loadWords(bf.Wordlist, defaultSubdomainWordlists)
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Skipping subdomain brute-forcing: %v", err))
+		} else {
+			complete[sourceBruteforce] = true
+		}
+		for _, w := range words {
+			add(w+"."+domain, sourceBruteforce)
+		}
+	}
+	if bf.Permutations {
+		words := permutationWords
+		if bf.Words != "" {
+			var err error
+			if words, err = loadWords(bf.Words, nil); err != nil {
+				return nil, nil, err
+			}
+		}
+		complete[sourcePermutation] = true
+		for _, name := range permutations(seeds, domain, words) {
+			add(name, sourcePermutation)
+		}
+	}
+	if truncated {
+		utils.Warn(fmt.Sprintf("More than %d candidates; only the first %d are resolved (see recon.bruteforce.max_candidates).", max, max))
+	}
+	var ran []string
+	for _, source := range []string{sourceBruteforce, sourcePermutation} {
+		if complete[source] {
+			ran = append(ran, source)
+		}
+	}
+	if len(candidates) == 0 {
+		utils.Log("No candidates to resolve.")
+		return nil, ran, nil
+	}
+
+	r, err := newResolver(options, cfg.Recon.DNS)
+	if err != nil {
+		return nil, nil, err
+	}
+	parents := make(map[string]bool)
+	var parentList []string
+	for _, name := range candidates {
+		if p := parent(name); !parents[p] {
+			parents[p] = true
+			parentList = append(parentList, p)
+		}
+	}
+	wildcards := r.Wildcards(ctx, parentList)
+	for p := range wildcards {
+		utils.Warn(fmt.Sprintf("Wildcard DNS detected for *.%s; names resolving only to its addresses are discarded.", p))
+	}
+
+	utils.Log(fmt.Sprintf("Resolving %d candidate subdomains of %s.", len(candidates), domain))
+	found := make(map[string]string)
+	discarded := 0
+	for name, ips := range r.Resolve(ctx, candidates) {
+		if resolver.IsWildcard(ips, wildcards[parent(name)]) {
+			discarded++
+			continue
+		}
+		found[name] = sources[name]
+	}
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	if discarded > 0 {
+		utils.Log(fmt.Sprintf("Discarded %d candidates that only matched wildcard DNS.", discarded))
+	}
+	return found, ran, nil
+}
+
+// numberPattern finds the numbers in a label, such as the 1 in api1.
+var numberPattern = regexp.MustCompile(`\d+`)
+
+// permutations generates altdns-style variants of the known subdomains of
+// domain. Every word is joined to the first label (dev-api, api-dev, devapi,
+// apidev), added in front of the subdomain (dev.api) and, for deeper names,
+// put in place of the first label (dev.eu for api.eu). Numbers in the first
+// label are counted up and down (api1 gives api0 and api2).
+func permutations(known []string, domain string, words []string) []string {
+	var out []string
+	for _, sub := range known {
+		label, rest, ok := strings.Cut(sub, ".")
+		if !ok || !strings.HasSuffix("."+rest, "."+domain) {
+			continue
+		}
+		for _, w := range words {
+			for _, l := range []string{w + "-" + label, label + "-" + w, w + label, label + w} {
+				out = append(out, l+"."+rest)
+			}
+			out = append(out, w+"."+sub)
+			if rest != domain {
+				out = append(out, w+"."+rest)
+			}
+		}
+		for _, loc := range numberPattern.FindAllStringIndex(label, -1) {
+			n, err := strconv.Atoi(label[loc[0]:loc[1]])
+			if err != nil {
+				continue
+			}
+			for _, m := range []int{n - 1, n + 1} {
+				if m >= 0 {
+					out = append(out, label[:loc[0]]+strconv.Itoa(m)+label[loc[1]:]+"."+rest)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// loadWords reads a word list, one word per line, skipping blank lines and
+// # comments. Without a path, the first of the defaults that exists is used.
+func loadWords(path string, defaults []string) ([]string, error) {
+	if path == "" {
+		for _, p := range defaults {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("no wordlist found (looked for %s)", strings.Join(defaults, ", "))
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read wordlist: %w", err)
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		w := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if w == "" || strings.HasPrefix(w, "#") || seen[w] {
+			continue
+		}
+		seen[w] = true
+		words = append(words, w)
+	}
+	return words, scanner.Err()
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// parent returns the domain a name is directly under.
+func parent(name string) string {
+	_, rest, _ := strings.Cut(name, ".")
+	return rest
+}
+
+// validName reports whether name is a valid DNS host name.
+func validName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package reconnaissance
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'recon'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "recon" }
+
+func (Module) Description() string {
+	return "Perform asset discovery and reconnaissance for all targets"
+}
+
+func (Module) RequiredTools() []string { return []string{"subfinder", "gau", "naabu", "httpx"} }
+
+// OptionalTools lists dnsx, which recon replaces with its built-in resolver when it is missing.
+func (Module) OptionalTools() []string { return []string{"dnsx"} }
+
+func (Module) Inputs() []string { return []string{registry.Targets} }
+
+func (Module) Outputs() []string {
+	return []string{registry.Subdomains, registry.IPs, registry.Ports, registry.URLs, registry.LiveURLs}
+}
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunReconnaissance(ctx, cfg, db)
+}
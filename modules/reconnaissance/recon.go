@@ -8,47 +8,122 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"sentinel/modules/checkpoint"
 	"sentinel/modules/config"
 	"sentinel/modules/database"
+	"sentinel/modules/notify"
+	"sentinel/modules/resolver"
+	"sentinel/modules/scope"
 	"sentinel/modules/utils"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // RunReconnaissance orchestrates the full reconnaissance workflow.
-func RunReconnaissance(ctx context.Context, cfg *config.Config, db *sql.DB) {
+// It returns an error if any target could not be processed.
+func RunReconnaissance(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	sc, err := scope.New(cfg)
+	if err != nil {
+		utils.Error("Invalid scope configuration", err)
+		return err
+	}
+
+	// When resuming an interrupted run, targets that were already completed are skipped.
+	tracker := checkpoint.For(ctx, "recon")
+	var failed []string
 	for _, target := range cfg.Targets {
-		runForTarget(ctx, target, cfg, db)
+		if tracker.TargetDone(target) {
+			utils.Log(fmt.Sprintf("Skipping %s: reconnaissance already completed in this run.", target))
+			continue
+		}
+		tracker.StartTarget(target)
+		err := runForTarget(ctx, target, cfg, db, sc)
+		tracker.FinishTarget(target, err)
+		if err != nil {
+			failed = append(failed, target)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("reconnaissance failed for %d target(s): %s", len(failed), strings.Join(failed, ", "))
 	}
+	return nil
 }
 
-func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sql.DB) {
+func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sql.DB, sc *scope.Scope) error {
 	utils.Banner(fmt.Sprintf("Starting reconnaissance for target: %s", target))
 
 	options := utils.Options{
-		Output:  cfg.Workspace,
-		Threads: cfg.Recon.Threads,
+		Output:    cfg.Workspace,
+		Threads:   cfg.Recon.Threads,
+		RateLimit: cfg.RateLimit,
+		Proxy:     cfg.Proxy,
 	}
 
 	targetID, err := database.AddTarget(db, target)
 	if err != nil {
 		utils.Error(fmt.Sprintf("Could not add or get target ID for %s", target), err)
-		return
+		return err
 	}
 
+	// Assets of this target that are not seen again during this pass are marked as removed.
+	started := time.Now()
+	lastEvent, err := database.LastAssetEventID(db)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not read asset history: %v", err))
+	}
+	defer notifyNewAssets(ctx, cfg, db, target, lastEvent)
+
 	// --- Phase 1: Subdomain Enumeration ---
 	subdomains, err := runSubfinder(ctx, target, options, cfg)
 	if err != nil {
-		return // Error already logged
+		utils.Error("Subdomain enumeration failed", err)
+		return err
 	}
+	subdomains = sc.Filter("subfinder", subdomains)
 	for _, sub := range subdomains {
-		_, err := db.Exec("INSERT OR IGNORE INTO subdomains(target_id, subdomain) VALUES(?, ?)", targetID, sub)
-		if err != nil {
+		if _, err := database.AddSubdomain(db, targetID, sub, "subfinder"); err != nil {
 			utils.Warn(fmt.Sprintf("Failed to insert subdomain %s: %v", sub, err))
 		}
 	}
 	utils.Success(fmt.Sprintf("Found %d subdomains.", len(subdomains)))
+	passiveCount := len(subdomains)
+
+	// --- Phase 1.2: Active Subdomain Discovery ---
+	// Subdomains of earlier runs are permuted too.
+	known, err := getSubdomainsForTarget(db, targetID)
+	if err != nil {
+		utils.Warn("Could not get known subdomains from database for permutations.")
+	}
+	found, ran, err := runBruteforce(ctx, target, subdomains, known, options, cfg, sc)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		utils.Warn(fmt.Sprintf("Active subdomain discovery failed: %v", err))
+	}
+	if len(found) > 0 {
+		for sub, source := range found {
+			if _, err := database.AddSubdomain(db, targetID, sub, source); err != nil {
+				utils.Warn(fmt.Sprintf("Failed to insert subdomain %s: %v", sub, err))
+				continue
+			}
+			subdomains = append(subdomains, sub)
+		}
+		utils.Success(fmt.Sprintf("Found %d subdomains by brute-force and permutations.", len(found)))
+	}
+
+	// Only the subdomains of sources that ran in full this pass can be missed.
+	// An empty passive result is more likely a network or API problem than
+	// every subdomain vanishing.
+	if passiveCount > 0 {
+		ran = append(ran, "subfinder")
+	}
+	markStale("subdomains", func() (int, error) { return database.MarkStaleSubdomains(db, targetID, started, ran) })
 
 	// --- Phase 1.5: Passive URL Discovery ---
 	gauURLs, err := runGau(ctx, target, options)
@@ -56,9 +131,9 @@ func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sq
 		// This is a soft error, passive discovery might fail
 		utils.Warn(fmt.Sprintf("gau passive discovery failed: %v", err))
 	} else {
+		gauURLs = sc.Filter("gau", gauURLs)
 		for _, u := range gauURLs {
-			_, err := db.Exec("INSERT OR IGNORE INTO urls(target_id, url, source) VALUES(?, ?, ?)", targetID, u, "gau")
-			if err != nil {
+			if _, err := database.AddURL(db, int(targetID), u, "gau"); err != nil {
 				utils.Warn(fmt.Sprintf("Failed to insert gau URL %s: %v", u, err))
 			}
 		}
@@ -66,9 +141,10 @@ func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sq
 	}
 
 	// --- Phase 2: DNS Resolution ---
-	liveSubdomains, err := runDnsx(ctx, subdomains, options)
+	liveSubdomains, dnsSource, err := resolveSubdomains(ctx, subdomains, options, cfg)
 	if err != nil {
-		return
+		utils.Error("DNS resolution failed", err)
+		return err
 	}
 	for sub, ips := range liveSubdomains {
 		var subID int64
@@ -76,14 +152,16 @@ func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sq
 		if err != nil {
 			continue // Skip if subdomain not in DB
 		}
-		for _, ip := range ips {
-			_, err := db.Exec("INSERT OR IGNORE INTO ips(subdomain_id, ip_address) VALUES(?, ?)", subID, ip)
-			if err != nil {
+		for _, ip := range sc.Filter(dnsSource, ips) {
+			if _, err := database.AddIP(db, subID, ip, dnsSource); err != nil {
 				utils.Warn(fmt.Sprintf("Failed to insert IP %s for %s: %v", ip, sub, err))
 			}
 		}
 	}
 	utils.Success(fmt.Sprintf("Resolved IPs for %d live subdomains.", len(liveSubdomains)))
+	if len(liveSubdomains) > 0 {
+		markStale("IPs", func() (int, error) { return database.MarkStaleIPs(db, targetID, started) })
+	}
 
 	// --- Phase 3: Port Scanning ---
 	var openPorts = make(map[string][]int)
@@ -91,11 +169,13 @@ func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sq
 	if err != nil {
 		utils.Warn(fmt.Sprintf("Could not get IPs for target %d from db", targetID))
 	}
+	ips = sc.Filter("naabu input", ips)
 
 	if len(ips) > 0 {
 		openPorts, err = runNaabu(ctx, ips, options)
 		if err != nil {
-			return // Naabu error is critical enough to stop
+			utils.Error("Port scanning failed", err)
+			return err // Naabu error is critical enough to stop
 		}
 		for host, ports := range openPorts {
 			var ipID int64
@@ -104,13 +184,18 @@ func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sq
 				continue
 			}
 			for _, port := range ports {
-				_, err := db.Exec("INSERT OR IGNORE INTO ports(ip_id, port) VALUES(?, ?)", ipID, port)
-				if err != nil {
+				if !sc.Allow("naabu", fmt.Sprintf("%s:%d", host, port)) {
+					continue
+				}
+				if _, err := database.AddPort(db, ipID, port, "", "naabu"); err != nil {
 					utils.Warn(fmt.Sprintf("Failed to insert port %d for %s: %v", port, host, err))
 				}
 			}
 		}
 		utils.Success(fmt.Sprintf("Found open ports for %d hosts.", len(openPorts)))
+		if len(openPorts) > 0 {
+			markStale("ports", func() (int, error) { return database.MarkStalePorts(db, targetID, started) })
+		}
 	} else {
 		utils.Warn("No IPs found for port scanning. Proceeding with web discovery on subdomains.")
 	}
@@ -129,13 +214,15 @@ func runForTarget(ctx context.Context, target string, cfg *config.Config, db *sq
 		urls = []string{}
 	}
 
-	liveURLs, err := runHttpx(ctx, openPorts, allSubdomains, urls, options, db, targetID)
+	liveURLs, err := runHttpx(ctx, openPorts, allSubdomains, urls, options, db, targetID, sc)
 	if err != nil {
-		return
+		utils.Error("Web server discovery failed", err)
+		return err
 	}
 	utils.Success(fmt.Sprintf("Found and processed %d live web services.", len(liveURLs)))
 
 	utils.Banner(fmt.Sprintf("Reconnaissance complete for: %s", target))
+	return nil
 }
 
 func runSubfinder(ctx context.Context, target string, options utils.Options, cfg *config.Config) ([]string, error) {
@@ -166,6 +253,74 @@ func runGau(ctx context.Context, target string, options utils.Options) ([]string
 	return strings.Split(strings.TrimSpace(output), "\n"), nil
 }
 
+// nativeDNSSource is the source recorded for addresses found by the built-in resolver.
+const nativeDNSSource = "native-dns"
+
+// resolveSubdomains resolves subdomains with dnsx or the built-in resolver, as
+// selected by recon.dns.engine, and returns the addresses and their source.
+// With "auto", dnsx is used when it is installed.
+func resolveSubdomains(ctx context.Context, subdomains []string, options utils.Options, cfg *config.Config) (map[string][]string, string, error) {
+	dns := cfg.Recon.DNS
+	switch dns.Engine {
+	case "", "auto":
+		if utils.CommandExists("dnsx") {
+			results, err := runDnsx(ctx, subdomains, options)
+			return results, "dnsx", err
+		}
+		utils.Log("dnsx is not installed; using the built-in resolver.")
+	case "dnsx":
+		if !utils.CommandExists("dnsx") {
+			return nil, "", fmt.Errorf("dnsx is selected in recon.dns.engine but is not installed")
+		}
+		results, err := runDnsx(ctx, subdomains, options)
+		return results, "dnsx", err
+	case "native":
+	default:
+		return nil, "", fmt.Errorf("unknown recon.dns.engine '%s' (expected auto, dnsx or native)", dns.Engine)
+	}
+	results, err := runNativeDNS(ctx, subdomains, options, dns)
+	return results, nativeDNSSource, err
+}
+
+// runNativeDNS resolves subdomains with the built-in resolver, producing the
+// same results as runDnsx.
+func runNativeDNS(ctx context.Context, subdomains []string, options utils.Options, dns config.DNS) (map[string][]string, error) {
+	utils.Banner("Running DNS Resolution (built-in resolver)")
+	r, err := newResolver(options, dns)
+	if err != nil {
+		return nil, err
+	}
+	servers := "the system resolvers"
+	if len(r.Servers) > 0 {
+		servers = strings.Join(r.Servers, ", ")
+	}
+	utils.Log(fmt.Sprintf("Resolving %d subdomains with %s (%d retries, %s timeout).", len(subdomains), servers, r.Retries, r.Timeout))
+	results := r.Resolve(ctx, subdomains)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if len(results) == 0 {
+		utils.Log("The built-in resolver could not resolve any subdomain.")
+	}
+	return results, nil
+}
+
+// newResolver returns the built-in resolver configured by recon.dns.
+func newResolver(options utils.Options, dns config.DNS) (*resolver.Resolver, error) {
+	r, err := resolver.New(dns.Resolvers, dns.Retries, time.Duration(dns.Timeout)*time.Second, options.Threads)
+	if err != nil {
+		return nil, err
+	}
+	// Queries go to the resolvers rather than the targets, so only the global limit applies.
+	if rate := options.RateLimit.RequestsPerSecond; rate > 0 {
+		r.Limiter = utils.NewLimiter(rate, 0)
+	}
+	if options.Proxy != "" {
+		utils.Warn("The built-in resolver cannot use the proxy; DNS queries are sent directly.")
+	}
+	return r, nil
+}
+
 type DnsxResult struct {
 	Host string   `json:"host"`
 	IPs  []string `json:"ip"`
@@ -218,12 +373,14 @@ func runDnsx(ctx context.Context, subdomains []string, options utils.Options) (m
 	return results, nil
 }
 
+// getIPsForTarget returns the addresses a target's subdomains still resolve to,
+// leaving out those that are no longer seen so that naabu does not scan them.
 func getIPsForTarget(db *sql.DB, targetID int64) ([]string, error) {
 	rows, err := db.Query(`
 		SELECT DISTINCT i.ip_address
 		FROM ips i
 		JOIN subdomains s ON i.subdomain_id = s.id
-		WHERE s.target_id = ?`, targetID)
+		WHERE s.target_id = ? AND i.removed_at IS NULL AND s.removed_at IS NULL`, targetID)
 	if err != nil {
 		return nil, err
 	}
@@ -307,7 +464,7 @@ type HttpxResult struct {
 	Tech       []string `json:"tech"`
 }
 
-func runHttpx(ctx context.Context, ports map[string][]int, subdomains []string, passiveURLs []string, options utils.Options, db *sql.DB, targetID int64) ([]HttpxResult, error) {
+func runHttpx(ctx context.Context, ports map[string][]int, subdomains []string, passiveURLs []string, options utils.Options, db *sql.DB, targetID int64, sc *scope.Scope) ([]HttpxResult, error) {
 	utils.Banner("Running Web Server Discovery (httpx)")
 	targets := passiveURLs
 	targets = append(targets, subdomains...)
@@ -317,6 +474,8 @@ func runHttpx(ctx context.Context, ports map[string][]int, subdomains []string,
 		}
 	}
 
+	targets = sc.Filter("httpx input", targets)
+
 	tempDir := filepath.Join(options.Output, "temp")
 	os.MkdirAll(tempDir, 0755)
 	tempInputFile := filepath.Join(tempDir, "httpx-input.txt")
@@ -352,32 +511,98 @@ func runHttpx(ctx context.Context, ports map[string][]int, subdomains []string,
 			utils.Warn(fmt.Sprintf("Could not unmarshal httpx output line: %s", line))
 			continue // Ignore lines that aren't valid JSON
 		}
+		// Redirects can land outside the scope, so check the final URL too.
+		if !sc.Allow("httpx", res.URL) {
+			continue
+		}
 
 		// Combine technologies into a single string
 		techStr := strings.Join(res.Tech, ", ")
 
 		// Insert or Update the URL in the database
-		// We use INSERT OR IGNORE and then UPDATE to handle both new and existing (from gau) URLs.
-		_, err := db.Exec("INSERT OR IGNORE INTO urls(target_id, url, source) VALUES(?, ?, ?)",
-			targetID, res.URL, "httpx")
-		if err != nil {
+		// We add the URL first and then update it to handle both new and existing (from gau) URLs.
+		if _, err := database.AddURL(db, int(targetID), res.URL, "httpx"); err != nil {
 			utils.Warn(fmt.Sprintf("Failed to insert URL %s: %v", res.URL, err))
 			continue
 		}
 
 		// Now update the details for the URL.
-		_, err = db.Exec("UPDATE urls SET status_code = ?, title = ?, tech = ? WHERE url = ?",
-			res.StatusCode, res.Title, techStr, res.URL)
-		if err != nil {
+		if err := database.UpdateURLDetails(db, res.URL, res.Title, techStr, res.StatusCode); err != nil {
 			utils.Warn(fmt.Sprintf("Failed to update details for URL %s: %v", res.URL, err))
 			continue
 		}
 
 		results = append(results, res)
 	}
+
+	if len(results) > 0 {
+		live := make(map[string]bool)
+		for _, res := range results {
+			if res.StatusCode > 0 {
+				live[res.URL] = true
+			}
+		}
+		markStale("live URLs", func() (int, error) { return database.MarkStaleLiveURLs(db, targetID, live) })
+	}
 	return results, nil
 }
 
+// notifyNewAssets sends one notification per kind of asset that appeared
+// since the asset event lastEvent, rather than one per asset.
+func notifyNewAssets(ctx context.Context, cfg *config.Config, db *sql.DB, target string, lastEvent int64) {
+	notifier := notify.Load(cfg)
+	if !notifier.Enabled() {
+		return
+	}
+	changes, err := database.DiffAssetsSince(db, lastEvent)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not read new assets for notifications: %v", err))
+		return
+	}
+	var subdomains, ports []string
+	for _, c := range changes {
+		if c.Change != database.EventAdded {
+			continue
+		}
+		switch c.Type {
+		case database.AssetSubdomain:
+			subdomains = append(subdomains, c.Asset)
+		case database.AssetPort:
+			ports = append(ports, c.Asset)
+		}
+	}
+	if len(subdomains) > 0 {
+		notifier.Send(ctx, notify.Event{
+			Kind:     notify.KindNewSubdomain,
+			Severity: "info",
+			Title:    fmt.Sprintf("%d new subdomain(s) for %s", len(subdomains), target),
+			Target:   target,
+			Items:    subdomains,
+		})
+	}
+	if len(ports) > 0 {
+		notifier.Send(ctx, notify.Event{
+			Kind:     notify.KindNewPort,
+			Severity: "info",
+			Title:    fmt.Sprintf("%d new open port(s) for %s", len(ports), target),
+			Target:   target,
+			Items:    ports,
+		})
+	}
+}
+
+// markStale runs one of the database.MarkStale* functions and reports the outcome.
+func markStale(kind string, mark func() (int, error)) {
+	n, err := mark()
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not update history of %s: %v", kind, err))
+		return
+	}
+	if n > 0 {
+		utils.Log(fmt.Sprintf("%d %s are no longer seen and were marked as removed.", n, kind))
+	}
+}
+
 func getSubdomainsForTarget(db *sql.DB, targetID int64) ([]string, error) {
 	rows, err := db.Query(`
 		SELECT s.subdomain
@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+
+	"sentinel/modules/config"
+	"sentinel/modules/utils"
+)
+
+// Data kinds that modules consume and produce through the workspace database.
+// The stages reading JavaScript (secrets, jsextract) take all URLs as input,
+// so that they also see the scripts that crawling and fuzzing find in the same
+// run. Endpoints are kept apart from URLs so that those stages can consume
+// every URL without depending on each other.
+const (
+	Targets         = "targets"
+	Subdomains      = "subdomains"
+	IPs             = "ips"
+	Ports           = "ports"
+	URLs            = "urls"
+	LiveURLs        = "live_urls"
+	Endpoints       = "endpoints" // URLs found in JavaScript files, stored with the other URLs
+	Parameters      = "parameters"
+	Secrets         = "secrets"
+	Vulnerabilities = "vulnerabilities"
+	Exploits        = "exploits"
+	Screenshots     = "screenshots"
+	Report          = "report"
+)
+
+// Module is a self-contained unit of work that can be run from the shell,
+// the CLI or a pipeline.
+type Module interface {
+	// Name is the identifier used by 'run <name>'.
+	Name() string
+	// Description is a one-line summary shown in help and completions.
+	Description() string
+	// RequiredTools lists the external binaries that must be in PATH.
+	RequiredTools() []string
+	// Inputs lists the data kinds the module reads from the database.
+	Inputs() []string
+	// Outputs lists the data kinds the module writes to the database.
+	Outputs() []string
+	// Run executes the module against the current workspace.
+	Run(ctx context.Context, cfg *config.Config, db *sql.DB) error
+}
+
+// ToolUser is implemented by modules that use more tools when they are
+// installed but can run without them.
+type ToolUser interface {
+	// OptionalTools lists the external binaries the module uses if they are in PATH.
+	OptionalTools() []string
+}
+
+// OptionalTools returns the optional tools of m, if it has any.
+func OptionalTools(m Module) []string {
+	if u, ok := m.(ToolUser); ok {
+		return u.OptionalTools()
+	}
+	return nil
+}
+
+var (
+	mu      sync.RWMutex
+	modules = make(map[string]Module)
+)
+
+// Register makes a module available by name. It is meant to be called from an
+// init function and panics if the name is empty or already taken.
+func Register(m Module) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	name := m.Name()
+	if name == "" {
+		panic("registry: module with empty name")
+	}
+	if _, exists := modules[name]; exists {
+		panic(fmt.Sprintf("registry: module '%s' registered twice", name))
+	}
+	modules[name] = m
+}
+
+// Get returns the module registered under name.
+func Get(name string) (Module, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	m, ok := modules[name]
+	return m, ok
+}
+
+// All returns every registered module sorted by name.
+func All() []Module {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	all := make([]Module, 0, len(modules))
+	for _, m := range modules {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	return all
+}
+
+// MissingTools returns the required tools of m that are not in PATH.
+func MissingTools(m Module) []string {
+	var missing []string
+	for _, tool := range m.RequiredTools() {
+		if !utils.CommandExists(tool) {
+			missing = append(missing, tool)
+		}
+	}
+	return missing
+}
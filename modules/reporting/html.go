@@ -0,0 +1,214 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxEmbeddedImage caps the size of a screenshot embedded in the HTML report.
+// Larger files are linked by path instead.
+const maxEmbeddedImage = 5 << 20
+
+// htmlReport is the view rendered by htmlTemplate.
+type htmlReport struct {
+	*ReportData
+	Severities []string
+	MaxCount   int
+}
+
+// buildHTML renders a single self-contained HTML file: styles are inline and
+// screenshots are embedded as data URIs, so the report can be sent as is.
+func buildHTML(data *ReportData) ([]byte, error) {
+	view := htmlReport{ReportData: data, Severities: Severities}
+	for _, count := range data.SeverityCounts {
+		if count > view.MaxCount {
+			view.MaxCount = count
+		}
+	}
+
+	images := make(map[string]template.URL)
+	funcs := template.FuncMap{
+		"title": severityTitle,
+		"join":  strings.Join,
+		"pct": func(n, max int) int {
+			if max == 0 {
+				return 0
+			}
+			return n * 100 / max
+		},
+		// image returns a screenshot as a data URI, or "" if it cannot be embedded.
+		"image": func(path string) template.URL {
+			if uri, ok := images[path]; ok {
+				return uri
+			}
+			uri := embedImage(path)
+			images[path] = uri
+			return uri
+		},
+	}
+	tmpl, err := template.New("report").Funcs(funcs).Parse(htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, view); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func embedImage(path string) template.URL {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxEmbeddedImage {
+		return ""
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	mime := http.DetectContentType(content)
+	if !strings.HasPrefix(mime, "image/") {
+		return ""
+	}
+	return template.URL(fmt.Sprintf("data:%s;base64,%s", mime, base64.StdEncoding.EncodeToString(content)))
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sentinel Engagement Report: {{.Workspace}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; background: #f4f5f7; color: #1f2328; }
+  header { background: #161b22; color: #fff; padding: 24px 40px; }
+  header h1 { margin: 0 0 4px; font-size: 24px; }
+  header p { margin: 0; color: #9da5b0; }
+  main { max-width: 1100px; margin: 0 auto; padding: 24px 40px; }
+  h2 { border-bottom: 1px solid #d0d7de; padding-bottom: 6px; }
+  .cards { display: flex; gap: 12px; flex-wrap: wrap; }
+  .card { flex: 1; min-width: 120px; background: #fff; border-radius: 6px; padding: 12px 16px; border-top: 4px solid; }
+  .card .count { font-size: 28px; font-weight: 600; }
+  .chart { background: #fff; border-radius: 6px; padding: 16px; margin-top: 16px; }
+  .bar-row { display: flex; align-items: center; margin: 6px 0; }
+  .bar-row .label { width: 80px; }
+  .bar-row .track { flex: 1; background: #eaeef2; border-radius: 3px; height: 18px; }
+  .bar-row .bar { height: 18px; border-radius: 3px; }
+  .bar-row .value { width: 40px; text-align: right; }
+  details { background: #fff; border-radius: 6px; margin: 8px 0; border-left: 4px solid; }
+  summary { cursor: pointer; padding: 10px 14px; font-weight: 600; }
+  .finding { padding: 0 14px 14px; }
+  .finding dt { font-weight: 600; margin-top: 8px; }
+  .finding dd { margin: 2px 0 0; word-break: break-all; }
+  .badge { display: inline-block; padding: 1px 8px; border-radius: 10px; color: #fff; font-size: 12px; text-transform: uppercase; margin-right: 8px; }
+  code { background: #eaeef2; padding: 1px 4px; border-radius: 3px; }
+  img.shot { max-width: 100%; border: 1px solid #d0d7de; margin-top: 8px; }
+  .gallery { display: grid; grid-template-columns: repeat(auto-fill, minmax(320px, 1fr)); gap: 12px; padding: 14px; }
+  .gallery figure { margin: 0; }
+  .gallery figcaption { font-size: 12px; word-break: break-all; }
+  .critical { border-color: #8b0000; } .bg-critical { background: #8b0000; }
+  .high { border-color: #d1242f; } .bg-high { background: #d1242f; }
+  .medium { border-color: #d4a72c; } .bg-medium { background: #d4a72c; }
+  .low { border-color: #0969da; } .bg-low { background: #0969da; }
+  .info { border-color: #6e7781; } .bg-info { background: #6e7781; }
+</style>
+</head>
+<body>
+<header>
+  <h1>Sentinel Engagement Report: {{.Workspace}}</h1>
+  <p>Generated {{.Timestamp}}</p>
+</header>
+<main>
+<h2>Executive Summary</h2>
+<p>This report details the findings from an automated security assessment conducted by the Sentinel framework. A total of <strong>{{.TotalVulns}} vulnerabilities</strong> were identified across {{len .Targets}} target(s).</p>
+<div class="cards">
+{{- range .Severities}}
+  <div class="card {{.}}"><div>{{title .}}</div><div class="count">{{index $.SeverityCounts .}}</div></div>
+{{- end}}
+</div>
+<div class="chart">
+{{- range .Severities}}{{$count := index $.SeverityCounts .}}
+  <div class="bar-row"><span class="label">{{title .}}</span><div class="track"><div class="bar bg-{{.}}" style="width: {{pct $count $.MaxCount}}%"></div></div><span class="value">{{$count}}</span></div>
+{{- end}}
+</div>
+
+<h2>Detailed Findings</h2>
+{{- if not .TotalVulns}}
+<p>No vulnerabilities to report.</p>
+{{- end}}
+{{- range .Targets}}{{if .Vulnerabilities}}
+<h3>Target: <code>{{.Name}}</code></h3>
+{{- range .Vulnerabilities}}
+<details class="{{.Severity}}">
+  <summary><span class="badge bg-{{.Severity}}">{{.Severity}}</span>{{.Name}} &mdash; {{.URL}}</summary>
+  <dl class="finding">
+    <dt>Template</dt><dd><code>{{.TemplateID}}</code></dd>
+    <dt>URL</dt><dd><a href="{{.URL}}">{{.URL}}</a></dd>
+    {{- if .Description}}
+    <dt>Description</dt><dd>{{.Description}}</dd>
+    {{- end}}
+    {{- if .CVSSScore}}
+    <dt>CVSS</dt><dd>{{printf "%.1f" .CVSSScore}}{{if .CVSSMetrics}} <code>{{.CVSSMetrics}}</code>{{end}}</dd>
+    {{- end}}
+    {{- if .CVEIDs}}
+    <dt>CVE</dt><dd>{{join .CVEIDs ", "}}</dd>
+    {{- end}}
+    {{- if .CWEIDs}}
+    <dt>CWE</dt><dd>{{join .CWEIDs ", "}}</dd>
+    {{- end}}
+    {{- if .References}}
+    <dt>References</dt>
+    {{- range .References}}
+    <dd><a href="{{.}}">{{.}}</a></dd>
+    {{- end}}
+    {{- end}}
+    {{- if ne .Status "new"}}
+    <dt>Status</dt><dd>{{.Status}}</dd>
+    {{- end}}
+    {{- if .Assignee}}
+    <dt>Assignee</dt><dd>{{.Assignee}}</dd>
+    {{- end}}
+    {{- if .Notes}}
+    <dt>Notes</dt><dd style="white-space: pre-line">{{.Notes}}</dd>
+    {{- end}}
+    {{- if .FirstSeen}}
+    <dt>First seen</dt><dd>{{.FirstSeen.Format "2006-01-02 15:04"}}</dd>
+    {{- end}}
+    {{- if .Exploits}}
+    <dt>Potential Exploits</dt>
+    {{- range .Exploits}}
+    <dd>{{.Title}} (EDB-ID {{.EDB_ID}}) <code>{{.Path}}</code></dd>
+    {{- end}}
+    {{- end}}
+    {{- if .Screenshot}}{{$img := image .Screenshot}}
+    <dt>Screenshot</dt>
+    <dd>{{if $img}}<img class="shot" src="{{$img}}" alt="Screenshot of {{.URL}}">{{else}}<code>{{.Screenshot}}</code>{{end}}</dd>
+    {{- end}}
+  </dl>
+</details>
+{{- end}}
+{{- end}}{{end}}
+
+{{- if .Screenshots}}
+<h2>Screenshots</h2>
+<details>
+  <summary>{{len .Screenshots}} screenshot(s)</summary>
+  <div class="gallery">
+  {{- range .Screenshots}}{{$img := image .Path}}
+    <figure>
+      <figcaption><a href="{{.URL}}">{{.URL}}</a>{{if .StatusCode}} [{{.StatusCode}}]{{end}}{{if .Title}} &mdash; {{.Title}}{{end}}</figcaption>
+      {{if $img}}<img class="shot" src="{{$img}}" alt="Screenshot of {{.URL}}">{{else}}<code>{{.Path}}</code>{{end}}
+    </figure>
+  {{- end}}
+  </div>
+</details>
+{{- end}}
+</main>
+</body>
+</html>
+`
@@ -0,0 +1,43 @@
+package reporting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"sentinel/modules/config"
+	"sentinel/modules/nvd"
+	"sentinel/modules/registry"
+	"sentinel/modules/utils"
+)
+
+// Module exposes this package to the module registry as 'report'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "report" }
+
+func (Module) Description() string {
+	return "Generate a summary report of all findings"
+}
+
+func (Module) RequiredTools() []string { return nil }
+
+// Inputs are everything the report renders, so that it runs after every stage
+// that adds to it.
+func (Module) Inputs() []string {
+	return []string{registry.Vulnerabilities, registry.Exploits, registry.Secrets, registry.URLs, registry.Endpoints, registry.Parameters, registry.Screenshots}
+}
+
+func (Module) Outputs() []string { return []string{registry.Report} }
+
+func (Module) Run(_ context.Context, cfg *config.Config, db *sql.DB) error {
+	// Feeds added to the workspace since the last scan apply to the report too.
+	if _, err := nvd.Enrich(db, cfg.Workspace); err != nil {
+		utils.Warn(fmt.Sprintf("Could not enrich vulnerabilities: %v", err))
+	}
+	return GenerateReport(cfg, db)
+}
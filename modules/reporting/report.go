@@ -2,104 +2,209 @@ package reporting
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"sentinel/modules/config"
+	"sentinel/modules/database"
 	"sentinel/modules/utils"
 )
 
-// ReportData holds all the structured information for a report.
+// SchemaVersion is the version of the JSON report schema. Fields may be added
+// to the report without changing it; renaming or removing one requires a bump.
+const SchemaVersion = 1
+
+// Severities lists the known severities from most to least severe.
+var Severities = []string{"critical", "high", "medium", "low", "info"}
+
+// ReportData holds all the structured information for a report. It is written
+// as-is for the JSON format, so its json tags are the documented report schema.
 type ReportData struct {
-	Workspace      string
-	Timestamp      string
-	Targets        []TargetData
-	TotalVulns     int
-	SeverityCounts map[string]int
+	SchemaVersion  int              `json:"schema_version"`
+	Workspace      string           `json:"workspace"`
+	GeneratedAt    time.Time        `json:"generated_at"`
+	Timestamp      string           `json:"-"`
+	Targets        []TargetData     `json:"targets"`
+	TotalVulns     int              `json:"total_vulnerabilities"`
+	SeverityCounts map[string]int   `json:"severity_counts"`
+	Screenshots    []ScreenshotInfo `json:"screenshots"`
 }
 
 type TargetData struct {
-	Name           string
-	Vulnerabilities []VulnInfo
+	Name            string       `json:"name"`
+	Vulnerabilities []VulnInfo   `json:"vulnerabilities"`
+	Secrets         []SecretInfo `json:"secrets"`
 }
 
 type VulnInfo struct {
-	Name        string
-	Severity    string
-	Description string
-	URL         string
-	Exploits    []ExploitInfo
+	TemplateID  string        `json:"template_id"`
+	Name        string        `json:"name"`
+	Severity    string        `json:"severity"`
+	Description string        `json:"description"`
+	URL         string        `json:"url"`
+	FirstSeen   *time.Time    `json:"first_seen,omitempty"`
+	LastSeen    *time.Time    `json:"last_seen,omitempty"`
+	Screenshot  string        `json:"screenshot,omitempty"`
+	Exploits    []ExploitInfo `json:"exploits"`
+	Triage
+	database.Classification
+}
+
+// Triage is the triage state of a finding. False positives are left out of reports.
+type Triage struct {
+	Status   string `json:"status"`
+	Assignee string `json:"assignee,omitempty"`
+	Notes    string `json:"notes,omitempty"`
 }
 
 type ExploitInfo struct {
-	Title  string
-	EDB_ID string
-	Path   string
+	Title  string `json:"title"`
+	EDB_ID string `json:"edb_id"`
+	Path   string `json:"path"`
 }
 
-// GenerateReport creates a markdown report from the database.
-func GenerateReport(cfg *config.Config, db *sql.DB) {
-	utils.Log("Generating professional engagement report...")
+// SecretInfo is a secret found in a JavaScript file. The value itself never
+// leaves the database; reports only carry its redacted form.
+type SecretInfo struct {
+	Type      string     `json:"type"`
+	Redacted  string     `json:"redacted"`
+	Source    string     `json:"source"`
+	URL       string     `json:"url"`
+	RuleID    string     `json:"rule_id,omitempty"`
+	Line      int        `json:"line,omitempty"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+	Triage
+}
+
+// ScreenshotInfo is a screenshot taken by the visual module.
+type ScreenshotInfo struct {
+	URL        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Path       string `json:"path"`
+}
+
+// formats maps each supported report format to its file extension and renderer.
+var formats = map[string]func(*ReportData) ([]byte, error){
+	"md":    func(d *ReportData) ([]byte, error) { return []byte(buildMarkdown(d)), nil },
+	"json":  buildJSON,
+	"html":  buildHTML,
+	"sarif": buildSARIF,
+}
 
-	data, err := gatherData(db, cfg.Workspace)
+// IsFormat reports whether format is a supported report format.
+func IsFormat(format string) bool {
+	_, ok := formats[strings.ToLower(format)]
+	return ok
+}
+
+// Render gathers the current findings of a workspace and renders them in the
+// given report format.
+func Render(db *sql.DB, workspace, format string) ([]byte, error) {
+	render, ok := formats[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format '%s'", format)
+	}
+	data, err := gatherData(db, workspace)
 	if err != nil {
-		utils.Error("Failed to gather data for report", err)
-		return
+		return nil, err
+	}
+	return render(data)
+}
+
+// GenerateReport writes a report of every finding in the format set by
+// cfg.Reporting.Format ("md" when empty).
+func GenerateReport(cfg *config.Config, db *sql.DB) error {
+	format := strings.ToLower(cfg.Reporting.Format)
+	if format == "" {
+		format = "md"
 	}
+	if !IsFormat(format) {
+		err := fmt.Errorf("unknown report format '%s' (expected md, json, html or sarif)", cfg.Reporting.Format)
+		utils.Error("Cannot generate report", err)
+		return err
+	}
+	utils.Log(fmt.Sprintf("Generating professional engagement report (%s)...", format))
 
-	reportContent := buildMarkdown(data)
+	reportContent, err := Render(db, cfg.Workspace, format)
+	if err != nil {
+		utils.Error("Failed to generate report", err)
+		return err
+	}
 	reportsDir := filepath.Join(cfg.Workspace, "reports")
 	if err := os.MkdirAll(reportsDir, 0755); err != nil {
 		utils.Error("Failed to create reports directory", err)
-		return
+		return err
 	}
-	reportPath := filepath.Join(reportsDir, "summary_report.md")
+	reportPath := filepath.Join(reportsDir, "summary_report."+format)
 
-	err = os.WriteFile(reportPath, []byte(reportContent), 0644)
+	err = os.WriteFile(reportPath, reportContent, 0644)
 	if err != nil {
 		utils.Error("Failed to write report to file", err)
-		return
+		return err
 	}
 
 	utils.Success(fmt.Sprintf("Report successfully generated at: %s", reportPath))
+	return nil
 }
 
+// gatherData collects the current findings. Findings that a later scan no
+// longer reported are left out.
 func gatherData(db *sql.DB, workspace string) (*ReportData, error) {
+	now := time.Now()
 	data := &ReportData{
+		SchemaVersion:  SchemaVersion,
 		Workspace:      workspace,
-		Timestamp:      time.Now().Format(time.RFC822),
+		GeneratedAt:    now,
+		Timestamp:      now.Format(time.RFC822),
+		Targets:        []TargetData{},
 		SeverityCounts: make(map[string]int),
+		Screenshots:    []ScreenshotInfo{},
+	}
+	for _, sev := range Severities {
+		data.SeverityCounts[sev] = 0
 	}
 
 	rows, err := db.Query(`
-		SELECT t.target, v.name, v.severity, v.description, u.url, e.title, e.edb_id, e.path
+		SELECT t.target, v.template_id, v.name, v.severity, v.description, u.url, u.screenshot_path,
+			v.first_seen, v.last_seen, v.status, COALESCE(v.assignee, ''), COALESCE(v.notes, ''),
+			COALESCE(v.cve_id, ''), COALESCE(v.cwe_id, ''), COALESCE(v.cvss_score, 0), COALESCE(v.cvss_metrics, ''), COALESCE(v.reference_urls, ''),
+			e.title, e.edb_id, e.path
 		FROM vulnerabilities v
 		JOIN urls u ON v.url_id = u.id
 		JOIN targets t ON u.target_id = t.id
 		LEFT JOIN exploits e ON v.id = e.vulnerability_id
+		WHERE v.removed_at IS NULL AND v.status != ?
 		ORDER BY t.target, v.severity, v.name
-	`)
+	`, database.StatusFalsePositive)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query report data: %w", err)
 	}
 	defer rows.Close()
 
 	vulnMap := make(map[string]map[string]*VulnInfo)
-	targetMap := make(map[string]bool)
 
 	for rows.Next() {
-		var targetName, vulnName, severity, description, url, exploitTitle, edbID, exploitPath sql.NullString
-		if err := rows.Scan(&targetName, &vulnName, &severity, &description, &url, &exploitTitle, &edbID, &exploitPath); err != nil {
+		var targetName, templateID, vulnName, severity, description, url, screenshot, exploitTitle, edbID, exploitPath sql.NullString
+		var firstSeen, lastSeen sql.NullTime
+		var triage Triage
+		var cveIDs, cweIDs, references string
+		var class database.Classification
+		if err := rows.Scan(&targetName, &templateID, &vulnName, &severity, &description, &url, &screenshot,
+			&firstSeen, &lastSeen, &triage.Status, &triage.Assignee, &triage.Notes,
+			&cveIDs, &cweIDs, &class.CVSSScore, &class.CVSSMetrics, &references, &exploitTitle, &edbID, &exploitPath); err != nil {
 			return nil, fmt.Errorf("failed to scan report row: %w", err)
 		}
 
 		if !targetName.Valid || !vulnName.Valid {
 			continue
 		}
-		targetMap[targetName.String] = true
 
 		if _, ok := vulnMap[targetName.String]; !ok {
 			vulnMap[targetName.String] = make(map[string]*VulnInfo)
@@ -107,15 +212,32 @@ func gatherData(db *sql.DB, workspace string) (*ReportData, error) {
 
 		vulnKey := fmt.Sprintf("%s|%s", url.String, vulnName.String)
 		if _, ok := vulnMap[targetName.String][vulnKey]; !ok {
-			vulnMap[targetName.String][vulnKey] = &VulnInfo{
+			v := &VulnInfo{
+				TemplateID:  templateID.String,
 				Name:        vulnName.String,
-				Severity:    severity.String,
+				Severity:    strings.ToLower(severity.String),
 				Description: description.String,
 				URL:         url.String,
+				Screenshot:  screenshot.String,
 				Exploits:    []ExploitInfo{},
+				Triage:      triage,
+				Classification: database.Classification{
+					CVEIDs:      strings.Split(cveIDs, ","),
+					CWEIDs:      strings.Split(cweIDs, ","),
+					CVSSScore:   class.CVSSScore,
+					CVSSMetrics: class.CVSSMetrics,
+					References:  strings.Split(references, "\n"),
+				}.Normalize(),
+			}
+			if firstSeen.Valid {
+				v.FirstSeen = &firstSeen.Time
 			}
+			if lastSeen.Valid {
+				v.LastSeen = &lastSeen.Time
+			}
+			vulnMap[targetName.String][vulnKey] = v
 			data.TotalVulns++
-			data.SeverityCounts[severity.String]++
+			data.SeverityCounts[v.Severity]++
 		}
 
 		if exploitTitle.Valid {
@@ -126,16 +248,114 @@ func gatherData(db *sql.DB, workspace string) (*ReportData, error) {
 			})
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read report data: %w", err)
+	}
+
+	secrets, err := gatherSecrets(db)
+	if err != nil {
+		return nil, err
+	}
+	for targetName := range secrets {
+		if _, ok := vulnMap[targetName]; !ok {
+			vulnMap[targetName] = make(map[string]*VulnInfo)
+		}
+	}
 
 	for targetName, vulns := range vulnMap {
-		target := TargetData{Name: targetName}
+		target := TargetData{Name: targetName, Vulnerabilities: []VulnInfo{}, Secrets: secrets[targetName]}
+		if target.Secrets == nil {
+			target.Secrets = []SecretInfo{}
+		}
 		for _, v := range vulns {
 			target.Vulnerabilities = append(target.Vulnerabilities, *v)
 		}
+		// Most severe first, then by CVSS score, so the order is stable between reports.
+		sort.Slice(target.Vulnerabilities, func(i, j int) bool {
+			a, b := target.Vulnerabilities[i], target.Vulnerabilities[j]
+			if ra, rb := utils.SeverityRank(a.Severity), utils.SeverityRank(b.Severity); ra != rb {
+				return ra > rb
+			}
+			if a.CVSSScore != b.CVSSScore {
+				return a.CVSSScore > b.CVSSScore
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.URL < b.URL
+		})
 		data.Targets = append(data.Targets, target)
 	}
+	sort.Slice(data.Targets, func(i, j int) bool { return data.Targets[i].Name < data.Targets[j].Name })
 
-	return data, nil
+	shots, err := db.Query(`SELECT url, COALESCE(title, ''), COALESCE(status_code, 0), screenshot_path FROM urls
+		WHERE screenshot_path IS NOT NULL AND screenshot_path != '' AND removed_at IS NULL ORDER BY url`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query screenshots: %w", err)
+	}
+	defer shots.Close()
+	for shots.Next() {
+		var s ScreenshotInfo
+		if err := shots.Scan(&s.URL, &s.Title, &s.StatusCode, &s.Path); err != nil {
+			return nil, fmt.Errorf("failed to scan screenshot row: %w", err)
+		}
+		data.Screenshots = append(data.Screenshots, s)
+	}
+
+	return data, shots.Err()
+}
+
+// gatherSecrets returns the secrets of every target, keyed by target name.
+func gatherSecrets(db *sql.DB) (map[string][]SecretInfo, error) {
+	rows, err := db.Query(`
+		SELECT t.target, s.type, COALESCE(s.redacted, ''), s.source, u.url, COALESCE(s.rule_id, ''), COALESCE(s.line, 0),
+			s.status, COALESCE(s.assignee, ''), COALESCE(s.notes, ''), s.first_seen, s.last_seen
+		FROM secrets s
+		JOIN urls u ON s.url_id = u.id
+		JOIN targets t ON u.target_id = t.id
+		WHERE s.status != ?
+		ORDER BY t.target, s.type, u.url, s.id
+	`, database.StatusFalsePositive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query secrets: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := make(map[string][]SecretInfo)
+	for rows.Next() {
+		var target string
+		var s SecretInfo
+		var firstSeen, lastSeen sql.NullTime
+		if err := rows.Scan(&target, &s.Type, &s.Redacted, &s.Source, &s.URL, &s.RuleID, &s.Line,
+			&s.Status, &s.Assignee, &s.Notes, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan secret row: %w", err)
+		}
+		if firstSeen.Valid {
+			s.FirstSeen = &firstSeen.Time
+		}
+		if lastSeen.Valid {
+			s.LastSeen = &lastSeen.Time
+		}
+		secrets[target] = append(secrets[target], s)
+	}
+	return secrets, rows.Err()
+}
+
+func buildJSON(data *ReportData) ([]byte, error) {
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// severityTitle capitalises a severity for display, "high" becoming "High".
+// Severities are ASCII, so the first byte is the first letter.
+func severityTitle(severity string) string {
+	if severity == "" {
+		return severity
+	}
+	return strings.ToUpper(severity[:1]) + severity[1:]
 }
 
 func buildMarkdown(data *ReportData) string {
@@ -149,25 +369,56 @@ func buildMarkdown(data *ReportData) string {
 	// Severity Table
 	sb.WriteString("| Severity | Count |\n")
 	sb.WriteString("|----------|-------|\n")
-	severities := []string{"critical", "high", "medium", "low", "info"}
-	for _, sev := range severities {
-		if count, ok := data.SeverityCounts[sev]; ok {
-			sb.WriteString(fmt.Sprintf("| %s | %d |\n", strings.Title(sev), count))
+	for _, sev := range Severities {
+		if count := data.SeverityCounts[sev]; count > 0 {
+			sb.WriteString(fmt.Sprintf("| %s | %d |\n", severityTitle(sev), count))
 		}
 	}
 	sb.WriteString(fmt.Sprintf("\nA total of **%d vulnerabilities** were identified.\n\n", data.TotalVulns))
 
 	sb.WriteString("## Detailed Findings\n\n")
-	if len(data.Targets) == 0 {
+	if data.TotalVulns == 0 {
 		sb.WriteString("No vulnerabilities to report.\n")
 	} else {
 		for _, target := range data.Targets {
+			if len(target.Vulnerabilities) == 0 {
+				continue
+			}
 			sb.WriteString(fmt.Sprintf("### Target: `%s`\n\n", target.Name))
 			for _, vuln := range target.Vulnerabilities {
 				sb.WriteString(fmt.Sprintf("#### %s\n\n", vuln.Name))
-				sb.WriteString(fmt.Sprintf("- **Severity:** %s\n", strings.Title(vuln.Severity)))
+				sb.WriteString(fmt.Sprintf("- **Severity:** %s\n", severityTitle(vuln.Severity)))
 				sb.WriteString(fmt.Sprintf("- **URL:** `%s`\n", vuln.URL))
 				sb.WriteString(fmt.Sprintf("- **Description:** %s\n", vuln.Description))
+				if vuln.CVSSScore > 0 {
+					sb.WriteString(fmt.Sprintf("- **CVSS:** %.1f", vuln.CVSSScore))
+					if vuln.CVSSMetrics != "" {
+						sb.WriteString(fmt.Sprintf(" (`%s`)", vuln.CVSSMetrics))
+					}
+					sb.WriteString("\n")
+				}
+				if len(vuln.CVEIDs) > 0 {
+					sb.WriteString(fmt.Sprintf("- **CVE:** %s\n", strings.Join(vuln.CVEIDs, ", ")))
+				}
+				if len(vuln.CWEIDs) > 0 {
+					sb.WriteString(fmt.Sprintf("- **CWE:** %s\n", strings.Join(vuln.CWEIDs, ", ")))
+				}
+				if vuln.Status != database.StatusNew {
+					sb.WriteString(fmt.Sprintf("- **Status:** %s\n", vuln.Status))
+				}
+				if vuln.Assignee != "" {
+					sb.WriteString(fmt.Sprintf("- **Assignee:** %s\n", vuln.Assignee))
+				}
+				if vuln.Notes != "" {
+					sb.WriteString(fmt.Sprintf("- **Notes:** %s\n", strings.ReplaceAll(vuln.Notes, "\n", "; ")))
+				}
+
+				if len(vuln.References) > 0 {
+					sb.WriteString("- **References:**\n")
+					for _, ref := range vuln.References {
+						sb.WriteString(fmt.Sprintf("  - %s\n", ref))
+					}
+				}
 
 				if len(vuln.Exploits) > 0 {
 					sb.WriteString("- **Potential Exploits:**\n")
@@ -0,0 +1,282 @@
+package reporting
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sentinel/modules/database"
+)
+
+// SARIF 2.1.0 output, as consumed by code-scanning dashboards. Only the parts
+// of the format Sentinel has data for are modelled.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      *sarifMessage      `json:"fullDescription,omitempty"`
+	HelpURI              string             `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifProperties    `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifProperties struct {
+	Tags             []string `json:"tags,omitempty"`
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+	Severity         string   `json:"severity,omitempty"`
+	Target           string   `json:"target,omitempty"`
+	FirstSeen        string   `json:"firstSeen,omitempty"`
+	Status           string   `json:"status,omitempty"`
+	Assignee         string   `json:"assignee,omitempty"`
+	CVE              []string `json:"cve,omitempty"`
+	CVSSMetrics      string   `json:"cvssMetrics,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           int                `json:"ruleIndex"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	Properties          sarifProperties    `json:"properties"`
+}
+
+// sarifSuppression marks a result that was triaged as an accepted risk.
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifLevels maps nuclei severities to SARIF result levels.
+var sarifLevels = map[string]string{
+	"critical": "error",
+	"high":     "error",
+	"medium":   "warning",
+	"low":      "note",
+	"info":     "note",
+}
+
+// securitySeverities are the CVSS-like scores GitHub code scanning uses to
+// rank security results.
+var securitySeverities = map[string]string{
+	"critical": "9.5",
+	"high":     "8.0",
+	"medium":   "5.5",
+	"low":      "3.0",
+	"info":     "0.0",
+}
+
+// secretSeverity is the severity given to secrets, which carry none of their own.
+const secretSeverity = "high"
+
+func sarifLevel(severity string) string {
+	if level, ok := sarifLevels[severity]; ok {
+		return level
+	}
+	return "warning"
+}
+
+// buildSARIF turns the report into a SARIF log with one rule per nuclei
+// template (and per secret type) and one result per finding, located at its URL.
+func buildSARIF(data *ReportData) ([]byte, error) {
+	var rules []sarifRule
+	ruleIndex := make(map[string]int)
+	addRule := func(r sarifRule) {
+		if _, ok := ruleIndex[r.ID]; !ok {
+			ruleIndex[r.ID] = len(rules)
+			rules = append(rules, r)
+		}
+	}
+
+	results := []sarifResult{}
+	for _, target := range data.Targets {
+		for _, v := range target.Vulnerabilities {
+			rule := sarifRule{
+				ID:                   v.TemplateID,
+				Name:                 v.Name,
+				ShortDescription:     sarifMessage{Text: v.Name},
+				DefaultConfiguration: sarifConfiguration{Level: sarifLevel(v.Severity)},
+				Properties: sarifProperties{
+					Tags:             []string{"security", "nuclei"},
+					SecuritySeverity: securitySeverities[v.Severity],
+					Severity:         v.Severity,
+					CVE:              v.CVEIDs,
+					CVSSMetrics:      v.CVSSMetrics,
+				},
+			}
+			if v.Description != "" {
+				rule.FullDescription = &sarifMessage{Text: v.Description}
+			}
+			// The CVSS score ranks a finding more precisely than its severity does.
+			if v.CVSSScore > 0 {
+				rule.Properties.SecuritySeverity = fmt.Sprintf("%.1f", v.CVSSScore)
+			}
+			for _, cwe := range v.CWEIDs {
+				rule.Properties.Tags = append(rule.Properties.Tags, "external/cwe/"+strings.ToLower(cwe))
+			}
+			if len(v.References) > 0 {
+				rule.HelpURI = v.References[0]
+			}
+			text := fmt.Sprintf("%s (%s) found at %s", v.Name, v.Severity, v.URL)
+			if v.Description != "" {
+				text += ": " + v.Description
+			}
+			addRule(rule)
+			results = append(results, sarifResult{
+				RuleID:              v.TemplateID,
+				Level:               sarifLevel(v.Severity),
+				Message:             sarifMessage{Text: text},
+				Locations:           sarifLocations(v.URL),
+				PartialFingerprints: map[string]string{"sentinelFinding/v1": v.TemplateID + "|" + v.URL},
+				Suppressions:        sarifSuppressions(v.Triage),
+				Properties: sarifProperties{Severity: v.Severity, Target: target.Name, FirstSeen: formatSeen(v.FirstSeen),
+					Status: v.Status, Assignee: v.Assignee},
+			})
+		}
+
+		for _, s := range target.Secrets {
+			id := "secret/" + s.Type
+			rule := sarifRule{
+				ID:                   id,
+				Name:                 s.Type + " secret",
+				ShortDescription:     sarifMessage{Text: fmt.Sprintf("Hardcoded %s secret", s.Type)},
+				DefaultConfiguration: sarifConfiguration{Level: sarifLevel(secretSeverity)},
+				Properties: sarifProperties{
+					Tags:             []string{"security", "secret"},
+					SecuritySeverity: securitySeverities[secretSeverity],
+					Severity:         secretSeverity,
+				},
+			}
+			addRule(rule)
+			results = append(results, sarifResult{
+				RuleID:              id,
+				Level:               sarifLevel(secretSeverity),
+				Message:             sarifMessage{Text: fmt.Sprintf("%s secret %s found in %s (detected by %s)", s.Type, s.Redacted, s.URL, s.Source)},
+				Locations:           secretLocations(s),
+				PartialFingerprints: map[string]string{"sentinelFinding/v1": id + "|" + s.URL + "|" + s.Redacted},
+				Suppressions:        sarifSuppressions(s.Triage),
+				Properties: sarifProperties{Severity: secretSeverity, Target: target.Name, FirstSeen: formatSeen(s.FirstSeen),
+					Status: s.Status, Assignee: s.Assignee},
+			})
+		}
+	}
+
+	// Rules are listed by ID so the output is stable; results refer to them by index.
+	order := make([]string, 0, len(rules))
+	for _, r := range rules {
+		order = append(order, r.ID)
+	}
+	sort.Strings(order)
+	sorted := make([]sarifRule, len(rules))
+	for i, id := range order {
+		sorted[i] = rules[ruleIndex[id]]
+	}
+	for i := range results {
+		results[i].RuleIndex = sort.SearchStrings(order, results[i].RuleID)
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "Sentinel",
+				InformationURI: "https://github.com/H4ck3rKing/Sentinel",
+				Rules:          sorted,
+			}},
+			Results: results,
+		}},
+	}
+	out, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func sarifLocations(url string) []sarifLocation {
+	return []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: url}}}}
+}
+
+// secretLocations locates a secret at its line in the file, when the scanner
+// that found it reports one.
+func secretLocations(s SecretInfo) []sarifLocation {
+	locations := sarifLocations(s.URL)
+	if s.Line > 0 {
+		locations[0].PhysicalLocation.Region = &sarifRegion{StartLine: s.Line}
+	}
+	return locations
+}
+
+// sarifSuppressions reports accepted risks as suppressed, so that dashboards
+// do not raise them again.
+func sarifSuppressions(t Triage) []sarifSuppression {
+	if t.Status != database.StatusAcceptedRisk {
+		return nil
+	}
+	return []sarifSuppression{{Kind: "external", Status: "accepted", Justification: t.Notes}}
+}
+
+func formatSeen(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
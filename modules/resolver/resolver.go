@@ -0,0 +1,226 @@
+// Package resolver is a concurrent DNS resolver. Recon uses it instead of dnsx
+// when dnsx is not installed or not selected in config.yaml, and to resolve
+// the candidates of subdomain brute-forcing.
+package resolver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sentinel/modules/utils"
+)
+
+// Defaults used when config.yaml does not set them.
+const (
+	DefaultRetries     = 2
+	DefaultTimeout     = 3 * time.Second
+	DefaultConcurrency = 50
+)
+
+// Resolver resolves host names to their A and AAAA records, following CNAMEs.
+type Resolver struct {
+	// Servers are the DNS servers to query, as ip or ip:port, in turn.
+	// When empty, the servers of the system configuration are used.
+	Servers []string
+	// Retries is how many times a query that timed out or failed is retried,
+	// each time with the next server.
+	Retries int
+	// Timeout bounds a single query.
+	Timeout time.Duration
+	// Concurrency is how many host names are resolved at once.
+	Concurrency int
+	// Limiter, when set, spaces out queries according to the rate limits.
+	Limiter *utils.Limiter
+
+	next     atomic.Uint64
+	resolver *net.Resolver
+}
+
+// New returns a resolver for the given servers. Zero values select the defaults.
+func New(servers []string, retries int, timeout time.Duration, concurrency int) (*Resolver, error) {
+	r := &Resolver{Retries: retries, Timeout: timeout, Concurrency: concurrency}
+	if r.Retries < 0 {
+		r.Retries = 0
+	} else if r.Retries == 0 {
+		r.Retries = DefaultRetries
+	}
+	if r.Timeout <= 0 {
+		r.Timeout = DefaultTimeout
+	}
+	if r.Concurrency <= 0 {
+		r.Concurrency = DefaultConcurrency
+	}
+	for _, s := range servers {
+		addr, err := serverAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		r.Servers = append(r.Servers, addr)
+	}
+	r.resolver = &net.Resolver{PreferGo: true}
+	if len(r.Servers) > 0 {
+		r.resolver.Dial = r.dial
+	}
+	return r, nil
+}
+
+// serverAddress normalizes a resolver address to ip:port, defaulting to port 53.
+func serverAddress(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(strings.Trim(s, "[]")); ip != nil {
+		return net.JoinHostPort(ip.String(), "53"), nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || net.ParseIP(host) == nil || port == "" {
+		return "", fmt.Errorf("invalid DNS resolver '%s' (expected ip or ip:port)", s)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// dial sends every connection of the Go resolver to the configured servers,
+// one after another, so that retries go to a different server.
+func (r *Resolver) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	server := r.Servers[(r.next.Add(1)-1)%uint64(len(r.Servers))]
+	d := net.Dialer{Timeout: r.Timeout}
+	return d.DialContext(ctx, network, server)
+}
+
+// Resolve resolves every host and returns the addresses of those that
+// resolved, keyed by host name. Hosts that do not exist or could not be
+// resolved are left out, just as dnsx leaves them out of its output.
+func (r *Resolver) Resolve(ctx context.Context, hosts []string) map[string][]string {
+	results := make(map[string][]string)
+	var mu sync.Mutex
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < r.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				ips, err := r.Lookup(ctx, host)
+				if err != nil || len(ips) == 0 {
+					continue
+				}
+				mu.Lock()
+				results[host] = ips
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+feed:
+	for _, host := range hosts {
+		host = strings.TrimSuffix(strings.TrimSpace(host), ".")
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		select {
+		case jobs <- host:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// Lookup returns the IPv4 and IPv6 addresses of host, sorted, following
+// CNAMEs. A host that does not exist is not retried.
+func (r *Resolver) Lookup(ctx context.Context, host string) ([]string, error) {
+	var err error
+	for attempt := 0; attempt <= r.Retries; attempt++ {
+		if err := r.Limiter.Wait(ctx, host); err != nil {
+			return nil, err
+		}
+		var addrs []net.IPAddr
+		lookupCtx, cancel := context.WithTimeout(ctx, r.Timeout)
+		addrs, err = r.resolver.LookupIPAddr(lookupCtx, host)
+		cancel()
+		if err == nil {
+			return addressList(addrs), nil
+		}
+		var dnsErr *net.DNSError
+		if ctx.Err() != nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// wildcardProbes is how many random names are resolved per domain to detect
+// wildcard DNS. Several catch wildcards that answer with rotating addresses.
+const wildcardProbes = 3
+
+// Wildcards detects wildcard DNS records. For every domain it resolves a few
+// random names directly under it and returns the addresses they resolved to,
+// keyed by domain. Domains without a wildcard record are left out.
+func (r *Resolver) Wildcards(ctx context.Context, domains []string) map[string]map[string]bool {
+	probeDomain := make(map[string]string)
+	var probes []string
+	for _, domain := range domains {
+		for i := 0; i < wildcardProbes; i++ {
+			probe := randomLabel() + "." + domain
+			probeDomain[probe] = domain
+			probes = append(probes, probe)
+		}
+	}
+	wildcards := make(map[string]map[string]bool)
+	for probe, ips := range r.Resolve(ctx, probes) {
+		domain := probeDomain[probe]
+		if wildcards[domain] == nil {
+			wildcards[domain] = make(map[string]bool)
+		}
+		for _, ip := range ips {
+			wildcards[domain][ip] = true
+		}
+	}
+	return wildcards
+}
+
+// IsWildcard reports whether every address of a name is one its parent
+// domain's wildcard record answers with, so that the name need not exist.
+func IsWildcard(ips []string, wildcard map[string]bool) bool {
+	if len(wildcard) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !wildcard[ip] {
+			return false
+		}
+	}
+	return true
+}
+
+// randomLabel returns a DNS label that is very unlikely to exist.
+func randomLabel() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "sentinel-wc-" + hex.EncodeToString(b)
+}
+
+func addressList(addrs []net.IPAddr) []string {
+	seen := make(map[string]bool, len(addrs))
+	ips := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		ip := a.IP.String()
+		if !seen[ip] {
+			seen[ip] = true
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
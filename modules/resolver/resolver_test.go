@@ -0,0 +1,388 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DNS record types and response codes the test server knows.
+const (
+	typeA     = 1
+	typeCNAME = 5
+	typeAAAA  = 28
+
+	rcodeNameError = 3
+)
+
+// testServer is a minimal recursive DNS server on a local UDP port. It answers
+// from its records, following CNAMEs the way a recursive resolver does, and
+// counts the queries it receives by name and type.
+type testServer struct {
+	conn net.PacketConn
+	// records holds the A, AAAA and CNAME values of names, keyed by lower-case
+	// name without the trailing dot. A "*." name is a wildcard record.
+	records map[string]map[uint16][]string
+	mu      sync.Mutex
+	// drop is how many queries of a name and type go unanswered before the
+	// server answers them.
+	drop map[string]int
+	// silent names are never answered.
+	silent  map[string]bool
+	queries map[string]int
+}
+
+func startServer(t *testing.T, records map[string]map[uint16][]string) *testServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	s := &testServer{conn: conn, records: records, drop: make(map[string]int), silent: make(map[string]bool), queries: make(map[string]int)}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *testServer) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// dropFirst leaves the first n queries of each type for name unanswered.
+func (s *testServer) dropFirst(name string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop[queryKey(name, typeA)] = n
+	s.drop[queryKey(name, typeAAAA)] = n
+}
+
+// silence leaves every query for name unanswered.
+func (s *testServer) silence(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silent[name] = true
+}
+
+// count returns how many queries of a name and type were received.
+func (s *testServer) count(name string, qtype uint16) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[queryKey(name, qtype)]
+}
+
+func queryKey(name string, qtype uint16) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "/" + map[uint16]string{typeA: "A", typeAAAA: "AAAA", typeCNAME: "CNAME"}[qtype]
+}
+
+func (s *testServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if reply := s.handle(buf[:n]); reply != nil {
+			s.conn.WriteTo(reply, from)
+		}
+	}
+}
+
+// handle answers a query, or returns nil to leave it unanswered.
+func (s *testServer) handle(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	name, end, ok := readName(query, 12)
+	if !ok || end+4 > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end:])
+	question := query[12 : end+4]
+
+	key := queryKey(name, qtype)
+	s.mu.Lock()
+	s.queries[key]++
+	dropped := s.queries[key] <= s.drop[key] || s.silent[strings.ToLower(name)]
+	s.mu.Unlock()
+	if dropped {
+		return nil
+	}
+
+	var answers [][]byte
+	rcode := uint16(rcodeNameError)
+	for owner, hops := name, 0; hops < 8; hops++ {
+		rrs, found := s.lookup(owner)
+		if !found {
+			break
+		}
+		rcode = 0
+		if cname := rrs[typeCNAME]; len(cname) > 0 {
+			answers = append(answers, record(owner, typeCNAME, encodeName(cname[0])))
+			owner = cname[0]
+			continue
+		}
+		for _, value := range rrs[qtype] {
+			ip := net.ParseIP(value)
+			if qtype == typeA {
+				ip = ip.To4()
+			}
+			answers = append(answers, record(owner, qtype, ip))
+		}
+		break
+	}
+
+	reply := make([]byte, 12, 512)
+	copy(reply, query[:2])
+	// QR, RD and RA are set, as a recursive server would.
+	binary.BigEndian.PutUint16(reply[2:], 0x8180|rcode)
+	binary.BigEndian.PutUint16(reply[4:], 1)
+	binary.BigEndian.PutUint16(reply[6:], uint16(len(answers)))
+	reply = append(reply, question...)
+	for _, a := range answers {
+		reply = append(reply, a...)
+	}
+	return reply
+}
+
+// lookup returns the records of a name, or those of the wildcard record of
+// its parent domain.
+func (s *testServer) lookup(name string) (map[uint16][]string, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if rrs, ok := s.records[name]; ok {
+		return rrs, true
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		rrs, ok := s.records["*."+parent]
+		return rrs, ok
+	}
+	return nil, false
+}
+
+func readName(msg []byte, off int) (string, int, bool) {
+	var labels []string
+	for off < len(msg) {
+		n := int(msg[off])
+		off++
+		if n == 0 {
+			return strings.Join(labels, "."), off, true
+		}
+		if n&0xC0 != 0 || off+n > len(msg) {
+			return "", 0, false
+		}
+		labels = append(labels, string(msg[off:off+n]))
+		off += n
+	}
+	return "", 0, false
+}
+
+func encodeName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+func record(owner string, rtype uint16, data []byte) []byte {
+	b := encodeName(owner)
+	b = binary.BigEndian.AppendUint16(b, rtype)
+	b = binary.BigEndian.AppendUint16(b, 1) // IN
+	b = binary.BigEndian.AppendUint32(b, 60)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+func newTestResolver(t *testing.T, s *testServer, retries int, timeout time.Duration) *Resolver {
+	t.Helper()
+	r, err := New([]string{s.addr()}, retries, timeout, 4)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+var testRecords = map[string]map[uint16][]string{
+	"v4.example.test":    {typeA: {"192.0.2.1", "192.0.2.2"}},
+	"v6.example.test":    {typeAAAA: {"2001:db8::1"}},
+	"dual.example.test":  {typeA: {"192.0.2.3"}, typeAAAA: {"2001:db8::3"}},
+	"www.example.test":   {typeCNAME: {"edge.example.test"}},
+	"edge.example.test":  {typeCNAME: {"origin.cdn.test"}},
+	"origin.cdn.test":    {typeA: {"198.51.100.7"}},
+	"flaky.example.test": {typeA: {"192.0.2.9"}},
+	"*.wild.test":        {typeA: {"203.0.113.5", "203.0.113.6"}},
+	"real.wild.test":     {typeA: {"192.0.2.50"}},
+	"plain.test":         {typeA: {"192.0.2.60"}},
+}
+
+func TestLookup(t *testing.T) {
+	s := startServer(t, testRecords)
+	r := newTestResolver(t, s, 1, time.Second)
+
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"v4.example.test", []string{"192.0.2.1", "192.0.2.2"}},
+		{"v6.example.test", []string{"2001:db8::1"}},
+		{"dual.example.test", []string{"192.0.2.3", "2001:db8::3"}},
+		{"DUAL.example.test", []string{"192.0.2.3", "2001:db8::3"}},
+		// CNAME chains are followed to the addresses of the canonical name.
+		{"www.example.test", []string{"198.51.100.7"}},
+		{"edge.example.test", []string{"198.51.100.7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := r.Lookup(context.Background(), tt.host)
+			if err != nil {
+				t.Fatalf("Lookup(%q): %v", tt.host, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupNotFoundIsNotRetried(t *testing.T) {
+	s := startServer(t, testRecords)
+	r := newTestResolver(t, s, 3, time.Second)
+
+	_, err := r.Lookup(context.Background(), "missing.example.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("Lookup of a missing name: got %v, want a not-found DNS error", err)
+	}
+	if n := s.count("missing.example.test", typeA); n != 1 {
+		t.Errorf("missing name was queried %d times, want 1", n)
+	}
+}
+
+func TestLookupRetries(t *testing.T) {
+	s := startServer(t, testRecords)
+	// The first query of each type goes unanswered, so only a retry succeeds.
+	s.dropFirst("flaky.example.test", 1)
+
+	r := newTestResolver(t, s, 2, 200*time.Millisecond)
+	got, err := r.Lookup(context.Background(), "flaky.example.test")
+	if err != nil {
+		t.Fatalf("Lookup with one retry: %v", err)
+	}
+	if want := []string{"192.0.2.9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup = %v, want %v", got, want)
+	}
+	if n := s.count("flaky.example.test", typeA); n != 2 {
+		t.Errorf("flaky name was queried %d times, want 2", n)
+	}
+
+	// Without retries the dropped query is the only one.
+	s = startServer(t, testRecords)
+	s.dropFirst("flaky.example.test", 1)
+	r = newTestResolver(t, s, -1, 200*time.Millisecond)
+	if _, err := r.Lookup(context.Background(), "flaky.example.test"); err == nil {
+		t.Error("Lookup without retries succeeded, want a timeout")
+	}
+}
+
+func TestLookupTimeout(t *testing.T) {
+	s := startServer(t, testRecords)
+	s.silence("v4.example.test")
+
+	const retries, timeout = 2, 150 * time.Millisecond
+	r := newTestResolver(t, s, retries, timeout)
+	start := time.Now()
+	_, err := r.Lookup(context.Background(), "v4.example.test")
+	elapsed := time.Since(start)
+	if err == nil {
+		t.Fatal("Lookup of an unanswered name succeeded")
+	}
+	if n := s.count("v4.example.test", typeA); n != retries+1 {
+		t.Errorf("unanswered name was queried %d times, want %d", n, retries+1)
+	}
+	// Every attempt is bounded by the timeout.
+	if max := time.Duration(retries+1)*timeout + time.Second; elapsed > max {
+		t.Errorf("Lookup took %v, want at most %v", elapsed, max)
+	}
+
+	// A cancelled context stops the retries.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Lookup(ctx, "v4.example.test"); err == nil {
+		t.Error("Lookup with a cancelled context succeeded")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := startServer(t, testRecords)
+	r := newTestResolver(t, s, 1, time.Second)
+
+	got := r.Resolve(context.Background(), []string{"v4.example.test", " www.example.test. ", "missing.example.test", "v4.example.test", ""})
+	want := map[string][]string{
+		"v4.example.test":  {"192.0.2.1", "192.0.2.2"},
+		"www.example.test": {"198.51.100.7"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve = %v, want %v", got, want)
+	}
+	if n := s.count("v4.example.test", typeA); n != 1 {
+		t.Errorf("duplicate host was queried %d times, want 1", n)
+	}
+}
+
+func TestWildcards(t *testing.T) {
+	s := startServer(t, testRecords)
+	r := newTestResolver(t, s, 1, time.Second)
+
+	got := r.Wildcards(context.Background(), []string{"wild.test", "plain.test"})
+	want := map[string]map[string]bool{
+		"wild.test": {"203.0.113.5": true, "203.0.113.6": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Wildcards = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		ips  []string
+		want bool
+	}{
+		{"random.wild.test", []string{"203.0.113.5"}, true},
+		{"rotating.wild.test", []string{"203.0.113.5", "203.0.113.6"}, true},
+		{"real.wild.test", []string{"192.0.2.50"}, false},
+		{"partly.wild.test", []string{"203.0.113.5", "192.0.2.50"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsWildcard(tt.ips, want["wild.test"]); got != tt.want {
+			t.Errorf("IsWildcard(%s %v) = %v, want %v", tt.name, tt.ips, got, tt.want)
+		}
+	}
+	if IsWildcard([]string{"192.0.2.60"}, got["plain.test"]) {
+		t.Error("IsWildcard is true for a domain without a wildcard record")
+	}
+}
+
+func TestServerAddress(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"1.1.1.1", "1.1.1.1:53", true},
+		{" 9.9.9.9:5353 ", "9.9.9.9:5353", true},
+		{"2606:4700::1111", "[2606:4700::1111]:53", true},
+		{"[2606:4700::1111]:853", "[2606:4700::1111]:853", true},
+		{"dns.example.com", "", false},
+		{"1.1.1.1:", "", false},
+	}
+	for _, tt := range tests {
+		got, err := serverAddress(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("serverAddress(%q) = %q, %v; want %q, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
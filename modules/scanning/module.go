@@ -0,0 +1,32 @@
+package scanning
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'scan'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "scan" }
+
+func (Module) Description() string {
+	return "Run vulnerability scans on discovered web services"
+}
+
+func (Module) RequiredTools() []string { return []string{"nuclei"} }
+
+func (Module) Inputs() []string { return []string{registry.LiveURLs} }
+
+func (Module) Outputs() []string { return []string{registry.Vulnerabilities} }
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunScan(ctx, cfg, db)
+}
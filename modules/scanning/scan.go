@@ -8,9 +8,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"sentinel/modules/config"
 	"sentinel/modules/database"
+	"sentinel/modules/notify"
+	"sentinel/modules/nvd"
+	"sentinel/modules/scope"
 	"sentinel/modules/utils"
 )
 
@@ -18,41 +22,90 @@ import (
 type NucleiResult struct {
 	TemplateID string `json:"template-id"`
 	Info       struct {
-		Name        string `json:"name"`
-		Severity    string `json:"severity"`
-		Description string `json:"description"`
+		Name           string     `json:"name"`
+		Severity       string     `json:"severity"`
+		Description    string     `json:"description"`
+		Reference      StringList `json:"reference"`
+		Classification struct {
+			CVEID       StringList `json:"cve-id"`
+			CWEID       StringList `json:"cwe-id"`
+			CVSSMetrics string     `json:"cvss-metrics"`
+			CVSSScore   float64    `json:"cvss-score"`
+		} `json:"classification"`
 	} `json:"info"`
 	Host      string `json:"host"`
 	MatchedAt string `json:"matched-at"`
 }
 
+// Classification returns the CVE, CWE and CVSS details and the references of
+// the template that matched.
+func (r NucleiResult) Classification() database.Classification {
+	c := r.Info.Classification
+	return database.Classification{
+		CVEIDs:      c.CVEID,
+		CWEIDs:      c.CWEID,
+		CVSSScore:   c.CVSSScore,
+		CVSSMetrics: c.CVSSMetrics,
+		References:  r.Info.Reference,
+	}.Normalize()
+}
+
+// StringList is a list that nuclei writes either as an array or as a single,
+// possibly comma separated, string, depending on how the template defines it.
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*l = strings.Split(s, ",")
+	return nil
+}
+
 // RunScan orchestrates the vulnerability scanning workflow.
-func RunScan(ctx context.Context, cfg *config.Config, db *sql.DB) {
+func RunScan(ctx context.Context, cfg *config.Config, db *sql.DB) error {
 	options := utils.Options{
-		Output:  cfg.Workspace,
-		Threads: cfg.Recon.Threads, // Nuclei uses its own concurrency settings
+		Output:    cfg.Workspace,
+		Threads:   cfg.Recon.Threads, // Nuclei uses its own concurrency settings
+		RateLimit: cfg.RateLimit,
+		Proxy:     cfg.Proxy,
 	}
 	utils.Banner("Starting Vulnerability Scanning phase")
 
+	sc, err := scope.New(cfg)
+	if err != nil {
+		utils.Error("Invalid scope configuration", err)
+		return err
+	}
+
 	// 1. Get all live URLs from the database
 	urls, err := database.GetLiveURLs(db)
 	if err != nil {
 		utils.Error("Could not retrieve URLs from database", err)
-		return
+		return err
 	}
+	urls = sc.Filter("nuclei input", urls)
 	if len(urls) == 0 {
 		utils.Warn("No live URLs found in the database to scan. Run 'recon' and 'crawl' first.")
-		return
+		return nil
 	}
 
 	// 2. Run Nuclei on the discovered URLs
+	started := time.Now()
 	results, err := runNuclei(ctx, urls, options, cfg)
 	if err != nil {
 		utils.Error("Error running Nuclei scan", err)
-		return
+		return err
 	}
 
-	// 3. Save findings to the database
+	// 3. Save findings to the database, notifying about the ones not seen before
+	notifier := notify.Load(cfg)
 	savedCount := 0
 	for _, res := range results {
 		// Find the URL ID to associate with the finding
@@ -66,12 +119,50 @@ func RunScan(ctx context.Context, cfg *config.Config, db *sql.DB) {
 				continue
 			}
 		}
-		if err := database.AddVulnerability(db, urlID, res.TemplateID, res.Info.Name, res.Info.Severity, res.Info.Description); err == nil {
-			savedCount++
+		added, err := database.AddVulnerability(db, urlID, res.TemplateID, res.Info.Name, res.Info.Severity, res.Info.Description, "nuclei", res.Classification())
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Failed to save finding '%s': %v", res.Info.Name, err))
+			continue
+		}
+		savedCount++
+		if added {
+			notifier.Send(ctx, notify.Event{
+				Kind:     notify.KindVulnerability,
+				Severity: res.Info.Severity,
+				Title:    fmt.Sprintf("New vulnerability: %s (%s)", res.Info.Name, res.TemplateID),
+				URL:      res.MatchedAt,
+				Details:  res.Info.Description,
+			})
 		}
 	}
 
+	// 4. CVE findings get what the workspace's offline NVD feeds know about them.
+	if _, err := nvd.Enrich(db, cfg.Workspace); err != nil {
+		utils.Warn(fmt.Sprintf("Could not enrich vulnerabilities: %v", err))
+	}
+
+	// 5. Findings on the scanned URLs that nuclei no longer reports are marked as removed.
+	removed, err := database.MarkStaleVulnerabilities(db, urls, scanSeverities(cfg.Scanning.Intensity), started)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not update vulnerability history: %v", err))
+	} else if removed > 0 {
+		utils.Log(fmt.Sprintf("%d previously reported vulnerabilities were not found again and were marked as removed.", removed))
+	}
+
 	utils.Success(fmt.Sprintf("Vulnerability scan complete. Found and saved %d potential vulnerabilities.", savedCount))
+	return nil
+}
+
+// scanSeverities returns the severities nuclei is limited to for an intensity, or nil for all of them.
+func scanSeverities(intensity string) []string {
+	switch intensity {
+	case "deep":
+		return nil
+	case "light":
+		return []string{"high", "critical"}
+	default: // "normal"
+		return []string{"medium", "high", "critical"}
+	}
 }
 
 func runNuclei(ctx context.Context, urls []string, options utils.Options, cfg *config.Config) ([]NucleiResult, error) {
@@ -96,10 +187,11 @@ func runNuclei(ctx context.Context, urls []string, options utils.Options, cfg *c
 		// Default behavior is all templates
 	case "light":
 		utils.Log("Running light scan with high and critical severity templates.")
-		args = append(args, "-severity", "high,critical")
 	default: // "normal"
 		utils.Log("Running normal scan with medium, high, and critical severity templates.")
-		args = append(args, "-severity", "medium,high,critical")
+	}
+	if severities := scanSeverities(cfg.Scanning.Intensity); severities != nil {
+		args = append(args, "-severity", strings.Join(severities, ","))
 	}
 
 	output, err := utils.RunCommandAndCapture(ctx, options, "nuclei", args...)
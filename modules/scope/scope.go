@@ -0,0 +1,316 @@
+package scope
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sentinel/modules/config"
+	"sentinel/modules/utils"
+)
+
+// Rule syntax, used by both config.Targets and config.Exclude:
+//
+//	example.com            exact host (in targets it also covers every subdomain)
+//	*.example.com          any subdomain of example.com, at any depth
+//	10.0.0.0/8, 1.2.3.4    CIDR range or single IP address
+//	re:^https://[^/]+/api  regular expression matched against the full item
+//	host:8443, host/admin  any host rule may be narrowed to a port and/or a path prefix
+//
+// A target narrowed to a port or path still covers the bare host names found
+// for it, which carry neither; an exclusion narrowed that way never does.
+type rule struct {
+	raw      string
+	host     string // lower-cased host, without a leading "*."
+	wildcard bool   // host rule started with "*."
+	subdoms  bool   // host rule also matches subdomains (root domain targets)
+	target   bool   // include rule rather than exclusion
+	network  *net.IPNet
+	re       *regexp.Regexp
+	port     int    // 0 means any port
+	path     string // "" means any path
+}
+
+// item is a normalised host, port and path extracted from a host, host:port or URL string.
+// A bare host has no port (0) and no path (""); a URL always has a path.
+type item struct {
+	raw  string
+	host string
+	port int
+	path string
+}
+
+// Scope decides whether discovered hosts and URLs belong to the engagement.
+type Scope struct {
+	include []rule
+	exclude []rule
+	hasIPs  bool // at least one include rule is an IP or CIDR
+
+	logPath string
+	mu      sync.Mutex
+}
+
+// New builds a Scope from the configured targets and exclusions. Out-of-scope
+// items are appended to <workspace>/logs/out-of-scope.log.
+func New(cfg *config.Config) (*Scope, error) {
+	s := &Scope{logPath: filepath.Join(cfg.Workspace, "logs", "out-of-scope.log")}
+	for _, t := range cfg.Targets {
+		r, err := parseRule(t, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target '%s': %w", t, err)
+		}
+		if r.network != nil {
+			s.hasIPs = true
+		}
+		s.include = append(s.include, r)
+	}
+	for _, e := range cfg.Exclude {
+		r, err := parseRule(e, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclusion '%s': %w", e, err)
+		}
+		s.exclude = append(s.exclude, r)
+	}
+	return s, nil
+}
+
+func parseRule(raw string, isTarget bool) (rule, error) {
+	raw = strings.TrimSpace(raw)
+	r := rule{raw: raw, target: isTarget}
+	if raw == "" {
+		return r, fmt.Errorf("empty rule")
+	}
+
+	if strings.HasPrefix(raw, "re:") {
+		re, err := regexp.Compile(strings.TrimPrefix(raw, "re:"))
+		if err != nil {
+			return r, err
+		}
+		r.re = re
+		return r, nil
+	}
+
+	if _, network, err := net.ParseCIDR(raw); err == nil {
+		r.network = network
+		return r, nil
+	}
+
+	// Strip a scheme if someone pasted a URL into the config.
+	if i := strings.Index(raw, "://"); i != -1 {
+		raw = raw[i+3:]
+	}
+	hostPort := raw
+	if i := strings.Index(raw, "/"); i != -1 {
+		hostPort, r.path = raw[:i], raw[i:]
+		r.path = strings.TrimSuffix(r.path, "*")
+	}
+	host := hostPort
+	if h, p, err := net.SplitHostPort(hostPort); err == nil {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return r, fmt.Errorf("invalid port '%s'", p)
+		}
+		host, r.port = h, port
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		bits := 32
+		if ip.To4() == nil {
+			bits = 128
+		}
+		r.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		return r, nil
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if strings.HasPrefix(host, "*.") {
+		r.wildcard = true
+		host = strings.TrimPrefix(host, "*.")
+	}
+	if host == "" {
+		return r, fmt.Errorf("missing host")
+	}
+	r.host = host
+	// A plain target is a root domain, so its subdomains are in scope too.
+	r.subdoms = isTarget && !r.wildcard
+	return r, nil
+}
+
+func parseItem(raw string) item {
+	it := item{raw: strings.TrimSpace(raw)}
+	s := it.raw
+	isURL := strings.Contains(s, "://")
+	if !isURL {
+		s = "scheme://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		it.host = strings.ToLower(it.raw)
+		return it
+	}
+	it.host = strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	it.path = u.EscapedPath()
+	if it.path == "" && isURL {
+		it.path = "/"
+	}
+	if p := u.Port(); p != "" {
+		it.port, _ = strconv.Atoi(p)
+	} else if u.Scheme == "https" {
+		it.port = 443
+	} else if u.Scheme == "http" {
+		it.port = 80
+	}
+	return it
+}
+
+func (r rule) matches(it item) bool {
+	if r.re != nil {
+		return r.re.MatchString(it.raw)
+	}
+	// A target's port and path only narrow items that have one: the host
+	// names recon finds for it carry neither and must stay in scope. An
+	// exclusion's port or path never covers a whole host.
+	if r.port != 0 && r.port != it.port && (it.port != 0 || !r.target) {
+		return false
+	}
+	if r.path != "" && !strings.HasPrefix(it.path, r.path) && (it.path != "" || !r.target) {
+		return false
+	}
+	if r.network != nil {
+		ip := net.ParseIP(it.host)
+		return ip != nil && r.network.Contains(ip)
+	}
+	if it.host == r.host {
+		return !r.wildcard
+	}
+	if r.wildcard || r.subdoms {
+		return strings.HasSuffix(it.host, "."+r.host)
+	}
+	return false
+}
+
+// Excluded returns the exclusion rule that matches raw, if any.
+func (s *Scope) Excluded(raw string) (string, bool) {
+	it := parseItem(raw)
+	for _, r := range s.exclude {
+		if r.matches(it) {
+			return r.raw, true
+		}
+	}
+	return "", false
+}
+
+// Check reports whether raw (a host, host:port or URL) is in scope, and why not.
+// Bare IP addresses are accepted without an include rule when no target is an
+// IP or CIDR, since Sentinel only reaches them by resolving in-scope names.
+func (s *Scope) Check(raw string) (bool, string) {
+	if rule, excluded := s.Excluded(raw); excluded {
+		return false, fmt.Sprintf("matches exclusion '%s'", rule)
+	}
+	it := parseItem(raw)
+	if it.host == "" {
+		return false, "no host"
+	}
+	if !s.hasIPs && net.ParseIP(it.host) != nil {
+		return true, ""
+	}
+	for _, r := range s.include {
+		if r.matches(it) {
+			return true, ""
+		}
+	}
+	return false, "matches no target"
+}
+
+// InScope reports whether raw is in scope without logging anything.
+func (s *Scope) InScope(raw string) bool {
+	ok, _ := s.Check(raw)
+	return ok
+}
+
+// Allow checks a single item and logs it as out of scope if it is rejected.
+func (s *Scope) Allow(source, raw string) bool {
+	ok, reason := s.Check(raw)
+	if !ok {
+		s.logDropped(source, []string{raw}, []string{reason})
+	}
+	return ok
+}
+
+// Filter returns the in-scope items, logging every rejected one along with the source that produced it.
+func (s *Scope) Filter(source string, items []string) []string {
+	var kept, dropped, reasons []string
+	for _, raw := range items {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if ok, reason := s.Check(raw); ok {
+			kept = append(kept, raw)
+		} else {
+			dropped = append(dropped, raw)
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(dropped) > 0 {
+		utils.Warn(fmt.Sprintf("Skipped %d out-of-scope item(s) from %s (logged to %s).", len(dropped), source, s.logPath))
+		s.logDropped(source, dropped, reasons)
+	}
+	return kept
+}
+
+func (s *Scope) logDropped(source string, items, reasons []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.logPath), 0755); err != nil {
+		utils.Warn(fmt.Sprintf("Could not create scope log directory: %v", err))
+		return
+	}
+	f, err := os.OpenFile(s.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Could not open scope log: %v", err))
+		return
+	}
+	defer f.Close()
+
+	now := time.Now().Format(time.RFC3339)
+	for i, it := range items {
+		fmt.Fprintf(f, "%s\t%s\t%s\t%s\n", now, source, it, reasons[i])
+	}
+}
+
+// TargetFor returns the ID of the target that host belongs to, or -1. Unlike a
+// plain suffix check, "evilexample.com" does not match "example.com"; the most
+// specific target wins when targets are nested. An IP address belongs to an
+// IP or CIDR target that contains it.
+func TargetFor(host string, targets map[int]string) int {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	bestID, bestLen := -1, 0
+	for id, target := range targets {
+		target = strings.ToLower(target)
+		if ip != nil && target != host {
+			// The narrowest containing network wins; an exact address match beats any network.
+			if _, network, err := net.ParseCIDR(target); err == nil && network.Contains(ip) {
+				if ones, _ := network.Mask.Size(); ones > bestLen {
+					bestID, bestLen = id, ones
+				}
+			}
+			continue
+		}
+		if host == target && ip != nil {
+			return id
+		}
+		if (host == target || strings.HasSuffix(host, "."+target)) && len(target) > bestLen {
+			bestID, bestLen = id, len(target)
+		}
+	}
+	return bestID
+}
@@ -0,0 +1,190 @@
+package scope
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sentinel/modules/config"
+)
+
+func newScope(t *testing.T, targets, exclude []string) *Scope {
+	t.Helper()
+	s, err := New(&config.Config{Workspace: t.TempDir(), Targets: targets, Exclude: exclude})
+	if err != nil {
+		t.Fatalf("New(%v, %v): %v", targets, exclude, err)
+	}
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []string
+		exclude []string
+		item    string
+		want    bool
+	}{
+		// A root domain target covers itself and every subdomain, but not other
+		// domains that merely end in the same letters.
+		{"root domain", []string{"example.com"}, nil, "example.com", true},
+		{"root domain subdomain", []string{"example.com"}, nil, "a.b.example.com", true},
+		{"root domain URL", []string{"example.com"}, nil, "https://api.example.com/v1?x=1", true},
+		{"root domain case and dot", []string{"Example.COM"}, nil, "WWW.example.com.", true},
+		{"root domain suffix", []string{"example.com"}, nil, "evilexample.com", false},
+		{"root domain other", []string{"example.com"}, nil, "example.org", false},
+
+		// A wildcard covers subdomains at any depth, but not the domain itself.
+		{"wildcard subdomain", []string{"*.example.com"}, nil, "www.example.com", true},
+		{"wildcard deep subdomain", []string{"*.example.com"}, nil, "a.b.example.com", true},
+		{"wildcard apex", []string{"*.example.com"}, nil, "example.com", false},
+		{"wildcard suffix", []string{"*.example.com"}, nil, "wwwexample.com", false},
+
+		// CIDR ranges and single addresses.
+		{"cidr", []string{"10.0.0.0/8"}, nil, "10.1.2.3", true},
+		{"cidr URL", []string{"10.0.0.0/8"}, nil, "http://10.1.2.3:8080/admin", true},
+		{"cidr outside", []string{"10.0.0.0/8"}, nil, "11.0.0.1", false},
+		{"single ip", []string{"192.0.2.10"}, nil, "192.0.2.10", true},
+		{"single ip other", []string{"192.0.2.10"}, nil, "192.0.2.11", false},
+		{"ipv6 cidr", []string{"2001:db8::/32"}, nil, "[2001:db8::1]:443", true},
+		// Without IP targets, addresses are only reached through in-scope names.
+		{"bare ip without ip targets", []string{"example.com"}, nil, "198.51.100.1", true},
+
+		// Regular expressions are matched against the whole item.
+		{"regexp", []string{"re:^https://[^/]+\\.example\\.com/api"}, nil, "https://x.example.com/api/users", true},
+		{"regexp other path", []string{"re:^https://[^/]+\\.example\\.com/api"}, nil, "https://x.example.com/static", false},
+
+		// Ports and paths narrow host rules.
+		{"port", []string{"example.com:8443"}, nil, "https://example.com:8443/", true},
+		{"port other", []string{"example.com:8443"}, nil, "https://example.com/", false},
+		{"port default https", []string{"example.com:443"}, nil, "https://example.com/login", true},
+		{"port default http", []string{"example.com:80"}, nil, "http://www.example.com/", true},
+		{"path", []string{"example.com/app"}, nil, "https://example.com/app/login", true},
+		{"path other", []string{"example.com/app"}, nil, "https://example.com/admin", false},
+		{"path wildcard", []string{"example.com/app*"}, nil, "https://example.com/apps", true},
+		{"port and path", []string{"example.com:8080/api"}, nil, "http://example.com:8080/api/v2", true},
+		{"port and path other port", []string{"example.com:8080/api"}, nil, "http://example.com/api/v2", false},
+		{"pasted URL target", []string{"https://example.com/app"}, nil, "https://example.com/app/x", true},
+		// Bare host names, as recon finds them, carry no port or path to narrow.
+		{"port bare subdomain", []string{"example.com:8443"}, nil, "www.example.com", true},
+		{"port bare host other port", []string{"example.com:8443"}, nil, "www.example.com:8080", false},
+		{"path bare subdomain", []string{"example.com/app"}, nil, "api.example.com", true},
+		{"path URL without path", []string{"example.com/app"}, nil, "https://example.com", false},
+		{"port and path bare host", []string{"example.com:8080/api"}, nil, "example.com", true},
+		{"port bare other domain", []string{"example.com:8443"}, nil, "evilexample.com", false},
+
+		// Exclusions win over targets; a plain excluded host leaves its subdomains alone.
+		{"excluded host", []string{"example.com"}, []string{"admin.example.com"}, "admin.example.com", false},
+		{"excluded host subdomain", []string{"example.com"}, []string{"admin.example.com"}, "x.admin.example.com", true},
+		{"excluded wildcard", []string{"example.com"}, []string{"*.corp.example.com"}, "vpn.corp.example.com", false},
+		{"excluded path", []string{"example.com"}, []string{"example.com/logout"}, "https://example.com/logout?all=1", false},
+		{"excluded path bare host", []string{"example.com"}, []string{"example.com/logout"}, "example.com", true},
+		{"excluded port bare host", []string{"example.com"}, []string{"example.com:8443"}, "example.com", true},
+		{"excluded cidr", []string{"10.0.0.0/8"}, []string{"10.0.5.0/24"}, "10.0.5.7", false},
+		{"excluded regexp", []string{"example.com"}, []string{"re:\\.pdf$"}, "https://example.com/a.pdf", false},
+		{"excluded bare ip", []string{"example.com"}, []string{"198.51.100.1"}, "198.51.100.1", false},
+
+		{"no host", []string{"example.com"}, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScope(t, tt.targets, tt.exclude)
+			got, reason := s.Check(tt.item)
+			if got != tt.want {
+				t.Errorf("Check(%q) with targets %v and exclusions %v = %v (%s), want %v", tt.item, tt.targets, tt.exclude, got, reason, tt.want)
+			}
+			if !got && reason == "" {
+				t.Errorf("Check(%q) rejected the item without a reason", tt.item)
+			}
+		})
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name             string
+		targets, exclude []string
+	}{
+		{"empty target", []string{" "}, nil},
+		{"bad regexp", []string{"re:("}, nil},
+		{"bad port", []string{"example.com:https"}, nil},
+		{"missing host", []string{":8443/admin"}, nil},
+		{"bad exclusion", []string{"example.com"}, []string{"re:[z-a]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(&config.Config{Workspace: t.TempDir(), Targets: tt.targets, Exclude: tt.exclude}); err == nil {
+				t.Errorf("New(%v, %v) succeeded, want an error", tt.targets, tt.exclude)
+			}
+		})
+	}
+}
+
+func TestFilterLogsDroppedItems(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(&config.Config{Workspace: dir, Targets: []string{"example.com"}, Exclude: []string{"admin.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Filter("test", []string{"www.example.com", "", "evilexample.com", "admin.example.com"})
+	if want := []string{"www.example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter = %v, want %v", got, want)
+	}
+
+	log, err := os.ReadFile(filepath.Join(dir, "logs", "out-of-scope.log"))
+	if err != nil {
+		t.Fatalf("could not read the scope log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("scope log has %d lines, want 2:\n%s", len(lines), log)
+	}
+	for i, want := range []string{"test\tevilexample.com\tmatches no target", "test\tadmin.example.com\tmatches exclusion 'admin.example.com'"} {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("scope log line %d = %q, want it to end in %q", i+1, lines[i], want)
+		}
+	}
+}
+
+func TestTargetFor(t *testing.T) {
+	targets := map[int]string{
+		1: "example.com",
+		2: "api.example.com",
+		3: "Example.org",
+		4: "10.0.0.0/8",
+		5: "10.1.0.0/16",
+		6: "10.1.2.3",
+		7: "2001:db8::/32",
+	}
+	tests := []struct {
+		host string
+		want int
+	}{
+		{"example.com", 1},
+		{"www.example.com", 1},
+		{"WWW.Example.COM.", 1},
+		// Regression: a plain suffix check put these under example.com.
+		{"evilexample.com", -1},
+		{"notexample.com", -1},
+		{"example.com.evil.net", -1},
+		// The most specific target wins.
+		{"api.example.com", 2},
+		{"v1.api.example.com", 2},
+		{"myapi.example.com", 1},
+		{"www.example.org", 3},
+		{"example.net", -1},
+		// Addresses belong to the narrowest network, or their own target.
+		{"10.200.0.1", 4},
+		{"10.1.9.9", 5},
+		{"10.1.2.3", 6},
+		{"11.0.0.1", -1},
+		{"2001:db8::5", 7},
+	}
+	for _, tt := range tests {
+		if got := TargetFor(tt.host, targets); got != tt.want {
+			t.Errorf("TargetFor(%q) = %d, want %d", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package secrets
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'secrets'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "secrets" }
+
+func (Module) Description() string {
+	return "Scan JavaScript files for hardcoded secrets and credentials"
+}
+
+func (Module) RequiredTools() []string { return nil }
+
+// OptionalTools lists trufflehog, which runs alongside the built-in rules when it is installed.
+func (Module) OptionalTools() []string { return []string{"trufflehog"} }
+
+func (Module) Inputs() []string { return []string{registry.URLs} }
+
+// Outputs include the endpoints and parameters found in the sources of source maps.
+func (Module) Outputs() []string {
+	return []string{registry.Secrets, registry.Endpoints, registry.Parameters}
+}
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunSecrets(ctx, cfg, db)
+}
@@ -0,0 +1,201 @@
+package secrets
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// builtinRules is the rule pack shipped with Sentinel.
+//
+//go:embed rules.yaml
+var builtinRules []byte
+
+// WorkspaceRulesFile is the name of the custom rule file that is loaded from
+// a workspace directory when it exists.
+const WorkspaceRulesFile = "secrets-rules.yaml"
+
+// keywordWindow is how far from a match one of its rule's keywords may appear.
+// JavaScript is often minified onto a single line, so lines are too coarse.
+const keywordWindow = 80
+
+// RuleFile is the YAML format of a rule pack.
+type RuleFile struct {
+	Rules     []Rule    `yaml:"rules"`
+	Allowlist Allowlist `yaml:"allowlist,omitempty"`
+}
+
+// Rule describes one kind of secret.
+type Rule struct {
+	ID          string    `yaml:"id"`
+	Name        string    `yaml:"name"`
+	Regex       string    `yaml:"regex"`
+	SecretGroup int       `yaml:"secret_group,omitempty"`
+	Entropy     float64   `yaml:"entropy,omitempty"`
+	Keywords    []string  `yaml:"keywords,omitempty"`
+	Allowlist   Allowlist `yaml:"allowlist,omitempty"`
+	Disabled    bool      `yaml:"disabled,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Allowlist marks matches as false positives: those matching one of the
+// regexes, or containing one of the stopwords (case-insensitive).
+type Allowlist struct {
+	Regexes   []string `yaml:"regexes,omitempty"`
+	Stopwords []string `yaml:"stopwords,omitempty"`
+
+	res []*regexp.Regexp
+}
+
+// Ruleset is a compiled set of rules and the allowlist shared by all of them.
+type Ruleset struct {
+	Rules     []*Rule
+	Allowlist Allowlist
+}
+
+// LoadRules compiles the built-in rule pack followed by the given custom rule
+// files. A custom rule replaces the rule with the same ID, and disabled rules
+// are dropped. Allowlists of all files are combined.
+func LoadRules(files ...string) (*Ruleset, error) {
+	var packs []RuleFile
+	var builtin RuleFile
+	if err := yaml.Unmarshal(builtinRules, &builtin); err != nil {
+		return nil, fmt.Errorf("invalid built-in secret rules: %w", err)
+	}
+	packs = append(packs, builtin)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read secret rules: %w", err)
+		}
+		var pack RuleFile
+		if err := yaml.Unmarshal(data, &pack); err != nil {
+			return nil, fmt.Errorf("invalid secret rules in %s: %w", path, err)
+		}
+		packs = append(packs, pack)
+	}
+
+	rs := &Ruleset{}
+	byID := make(map[string]int)
+	for _, pack := range packs {
+		for i := range pack.Rules {
+			r := pack.Rules[i]
+			if r.ID == "" {
+				return nil, fmt.Errorf("secret rule without an id")
+			}
+			if idx, ok := byID[r.ID]; ok {
+				rs.Rules[idx] = &r
+				continue
+			}
+			byID[r.ID] = len(rs.Rules)
+			rs.Rules = append(rs.Rules, &r)
+		}
+		rs.Allowlist.Regexes = append(rs.Allowlist.Regexes, pack.Allowlist.Regexes...)
+		rs.Allowlist.Stopwords = append(rs.Allowlist.Stopwords, pack.Allowlist.Stopwords...)
+	}
+
+	enabled := rs.Rules[:0]
+	for _, r := range rs.Rules {
+		if r.Disabled {
+			continue
+		}
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+		enabled = append(enabled, r)
+	}
+	rs.Rules = enabled
+	if err := rs.Allowlist.compile(); err != nil {
+		return nil, fmt.Errorf("invalid global secret allowlist: %w", err)
+	}
+	return rs, nil
+}
+
+// RuleFiles returns the custom rule files to load: those listed in
+// config.yaml, then the workspace's own file if it exists.
+func RuleFiles(configured []string, workspace string) []string {
+	files := append([]string{}, configured...)
+	path := filepath.Join(workspace, WorkspaceRulesFile)
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		r.Name = r.ID
+	}
+	re, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex of secret rule %s: %w", r.ID, err)
+	}
+	if r.SecretGroup < 0 || r.SecretGroup > re.NumSubexp() {
+		return fmt.Errorf("secret rule %s has no capture group %d", r.ID, r.SecretGroup)
+	}
+	r.re = re
+	for i, k := range r.Keywords {
+		r.Keywords[i] = strings.ToLower(k)
+	}
+	if err := r.Allowlist.compile(); err != nil {
+		return fmt.Errorf("invalid allowlist of secret rule %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+func (a *Allowlist) compile() error {
+	a.res = nil
+	for _, expr := range a.Regexes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		a.res = append(a.res, re)
+	}
+	for i, w := range a.Stopwords {
+		a.Stopwords[i] = strings.ToLower(w)
+	}
+	return nil
+}
+
+// allows reports whether the allowlist marks secret as a false positive.
+func (a *Allowlist) allows(secret string) bool {
+	for _, re := range a.res {
+		if re.MatchString(secret) {
+			return true
+		}
+	}
+	lower := strings.ToLower(secret)
+	for _, w := range a.Stopwords {
+		if strings.Contains(lower, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+
+	"sentinel/modules/utils"
+)
+
+// contextRadius is how many characters around a secret are kept as its context.
+const contextRadius = 40
+
+// Finding is a secret found by the native scanner.
+type Finding struct {
+	RuleID string
+	Type   string
+	Value  string
+	// Line is the 1-based line of the secret in the scanned content.
+	Line int
+	// Context is the text around the secret, with the secret itself redacted.
+	Context string
+}
+
+// Scan runs every rule over content and returns the secrets found, in order
+// of appearance. A value is reported once, by the first rule that finds it,
+// so specific rules take precedence over the generic ones after them.
+func (rs *Ruleset) Scan(content []byte) []Finding {
+	text := string(content)
+	lower := strings.ToLower(text)
+	lineStarts := []int{0}
+	for i, c := range content {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var found []span
+	for _, r := range rs.Rules {
+		for _, m := range r.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*r.SecretGroup], m[2*r.SecretGroup+1]
+			if start < 0 {
+				continue
+			}
+			value := text[start:end]
+			if seen[value] {
+				continue
+			}
+			if r.Entropy > 0 && entropy(value) < r.Entropy {
+				continue
+			}
+			if len(r.Keywords) > 0 && !nearKeyword(lower, m[0], m[1], r.Keywords) {
+				continue
+			}
+			if r.Allowlist.allows(value) || rs.Allowlist.allows(value) {
+				continue
+			}
+			seen[value] = true
+			found = append(found, span{rule: r, start: start, end: end})
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].start < found[j].start })
+	findings := make([]Finding, len(found))
+	for i, f := range found {
+		findings[i] = Finding{
+			RuleID:  f.rule.ID,
+			Type:    f.rule.Name,
+			Value:   text[f.start:f.end],
+			Line:    sort.SearchInts(lineStarts, f.start+1),
+			Context: snippet(text, f, found),
+		}
+	}
+	return findings
+}
+
+// span is where a rule matched a secret in the scanned content.
+type span struct {
+	rule       *Rule
+	start, end int
+}
+
+// nearKeyword reports whether one of the keywords appears in lower, the
+// lower-cased content, within keywordWindow characters of the match.
+func nearKeyword(lower string, start, end int, keywords []string) bool {
+	from := start - keywordWindow
+	if from < 0 {
+		from = 0
+	}
+	to := end + keywordWindow
+	if to > len(lower) {
+		to = len(lower)
+	}
+	window := lower[from:to]
+	for _, k := range keywords {
+		if strings.Contains(window, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// snippet returns the text around secret on the same line. The secret and
+// any other secret in that text are replaced by their redacted form, so the
+// context can be stored and shown.
+func snippet(text string, secret span, all []span) string {
+	from := secret.start - contextRadius
+	if from < 0 {
+		from = 0
+	}
+	if nl := strings.LastIndexByte(text[from:secret.start], '\n'); nl >= 0 {
+		from += nl + 1
+	}
+	to := secret.end + contextRadius
+	if to > len(text) {
+		to = len(text)
+	}
+	if nl := strings.IndexByte(text[secret.end:to], '\n'); nl >= 0 {
+		to = secret.end + nl
+	}
+	// Secrets cut by the edges of the context are left out rather than shown in part.
+	for _, s := range all {
+		if s.start < from && s.end > from {
+			from = s.end
+		}
+		if s.start < to && s.end > to {
+			to = s.start
+		}
+	}
+
+	var b strings.Builder
+	pos := from
+	for _, s := range all {
+		if s.start < pos {
+			// Overlaps a secret already written: hide the rest of it too.
+			if s.end > pos {
+				pos = s.end
+			}
+			continue
+		}
+		if s.end > to {
+			continue
+		}
+		b.WriteString(text[pos:s.start])
+		b.WriteString(utils.Redact(text[s.start:s.end]))
+		pos = s.end
+	}
+	if pos < to {
+		b.WriteString(text[pos:to])
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(b.String(), ""))
+}
@@ -5,18 +5,30 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"sentinel/modules/checkpoint"
 	"sentinel/modules/config"
 	"sentinel/modules/database"
+	"sentinel/modules/jsextract"
+	"sentinel/modules/notify"
+	"sentinel/modules/scope"
+	"sentinel/modules/sourcemap"
 	"sentinel/modules/utils"
+	"sentinel/modules/vault"
 	"github.com/fatih/color"
 )
 
+// downloadTimeout bounds the download of a single JavaScript file.
+const downloadTimeout = 30 * time.Second
+
 // TruffleHogOutput defines the structure for a single secret found by truffleHog
 type TruffleHogOutput struct {
 	SourceMetadata struct {
@@ -43,87 +55,362 @@ type TruffleHogOutput struct {
 	StructuredData any    `json:"structured_data"`
 }
 
-func RunSecrets(ctx context.Context, config *config.Config, db *sql.DB) {
+// scan holds what every file of a secrets run is scanned with.
+type scan struct {
+	db         *sql.DB
+	key        *vault.Key // encrypts the values stored
+	options    utils.Options
+	rules      *Ruleset // nil when the native scanner is not used
+	trufflehog bool
+	notifier   *notify.Notifier
+	found      int // secrets not stored before this run
+}
+
+// RunSecrets downloads every live JavaScript file and scans it with the
+// built-in rules, trufflehog, or both, as selected by secrets.engine. The
+// sources of the files' source maps are unpacked into the workspace and
+// scanned too, and the endpoints they reference are stored like those of
+// the jsextract module. Secret values are stored encrypted with the workspace
+// key, so the run fails early when no key is set.
+func RunSecrets(ctx context.Context, config *config.Config, db *sql.DB) error {
 	options := utils.Options{
-		Output:  config.Workspace,
-		Threads: config.Recon.Threads,
+		Output:    config.Workspace,
+		Threads:   config.Recon.Threads,
+		RateLimit: config.RateLimit,
+		Proxy:     config.Proxy,
 	}
 	color.Cyan("[*] Starting Secrets scanning phase")
 
-	if !utils.CommandExists("trufflehog") {
-		color.Red("trufflehog not found. Please install it first.")
-		color.Yellow("Hint: go install github.com/trufflesecurity/trufflehog/v3@latest")
-		return
+	sc, err := scope.New(config)
+	if err != nil {
+		color.Red("Invalid scope configuration: %v", err)
+		return err
+	}
+
+	useNative, useTrufflehog, err := engines(config.Secrets.Engine)
+	if err != nil {
+		color.Red("%v", err)
+		return err
+	}
+	var rules *Ruleset
+	if useNative {
+		files := RuleFiles(config.Secrets.Rules, config.Workspace)
+		if rules, err = LoadRules(files...); err != nil {
+			color.Red("Error loading secret rules: %v", err)
+			return err
+		}
+		color.White("Native scanner loaded %d rules (%d custom rule files).", len(rules.Rules), len(files))
+	}
+	if useTrufflehog {
+		color.White("Using trufflehog.")
+	}
+	mapsEnabled, probeMaps, err := sourceMapMode(config.Secrets.SourceMaps)
+	if err != nil {
+		color.Red("%v", err)
+		return err
+	}
+	// Values are stored encrypted, so fail before scanning anything if the key is unusable.
+	key, err := database.SecretKey(db, config.Workspace)
+	if err != nil {
+		color.Red("Cannot store secrets: %v", err)
+		return err
 	}
 
 	utils.Banner("Fetching JavaScript URLs from database")
 	jsURLs, err := database.GetJavaScriptURLs(db)
 	if err != nil {
 		color.Red("Error getting JavaScript URLs from database: %v", err)
-		return
+		return err
+	}
+
+	for urlID, jsURL := range jsURLs {
+		if !sc.Allow("secrets input", jsURL) {
+			delete(jsURLs, urlID)
+		}
 	}
 
 	if len(jsURLs) == 0 {
 		color.Yellow("No JavaScript files found in the database to scan.")
-		return
+		return nil
 	}
 	color.Green("Found %d JavaScript files to scan.", len(jsURLs))
 
+	targets, err := database.GetTargets(db)
+	if err != nil {
+		color.Red("Error getting targets from database: %v", err)
+		return err
+	}
+
 	tempDir := filepath.Join(options.Output, "temp", "secrets")
 	os.MkdirAll(tempDir, 0755)
 	defer os.RemoveAll(tempDir)
 
-	secretsFoundCount := 0
+	// When resuming an interrupted run, files that were already scanned are skipped.
+	tracker := checkpoint.For(ctx, "secrets")
+	s := &scan{db: db, key: key, options: options, rules: rules, trufflehog: useTrufflehog, notifier: notify.Load(config)}
+	// The client uses the configured proxy and spaces out downloads according to the rate limits.
+	client, err := utils.HTTPClient(options, downloadTimeout)
+	if err != nil {
+		color.Red("Invalid proxy configuration: %v", err)
+		return err
+	}
 	for urlID, jsURL := range jsURLs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if tracker.Done(jsURL) {
+			continue
+		}
 		color.White("Scanning: %s", jsURL)
-		resp, err := http.Get(jsURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, jsURL, nil)
+		if err != nil {
+			color.Yellow("Failed to download %s: %v", jsURL, err)
+			continue
+		}
+		resp, err := client.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			color.Yellow("Failed to download %s: %v", jsURL, err)
 			continue
 		}
-		defer resp.Body.Close()
 
 		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
 			color.Yellow("Failed to read content of %s: %v", jsURL, err)
 			continue
 		}
 
-		tmpFile, err := ioutil.TempFile(tempDir, "trufflehog-*.js")
+		if s.rules != nil {
+			s.scanNative(ctx, urlID, jsURL, "", body)
+		}
+		if s.trufflehog {
+			tmpFile, err := ioutil.TempFile(tempDir, "trufflehog-*.js")
+			if err != nil {
+				color.Red("Failed to create temp file: %v", err)
+				continue
+			}
+			tmpFile.Write(body)
+			tmpFile.Close()
+			s.scanTrufflehog(ctx, urlID, jsURL, tmpFile.Name())
+		}
+
+		if mapsEnabled {
+			if base, err := url.Parse(jsURL); err == nil {
+				s.scanSourceMap(ctx, client, sc, targets, urlID, base, resp.Header, body, probeMaps)
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tracker.Mark(jsURL)
+	}
+
+	color.Green("Secrets scanning phase completed. Found %d new secrets.", s.found)
+	return nil
+}
+
+// scanNative scans content with the built-in rules and stores what it finds
+// for urlID. For the sources of a source map, file is the source's name; it
+// is prepended to the context of the findings.
+func (s *scan) scanNative(ctx context.Context, urlID int, fileURL, file string, content []byte) {
+	for _, f := range s.rules.Scan(content) {
+		where := f.Context
+		if file != "" {
+			where = file + ": " + where
+		}
+		color.HiRed("[!] Secret Found in %s!", fileURL)
+		if file != "" {
+			color.Yellow("  > Source: %s", file)
+		}
+		color.Yellow("  > Type: %s (rule %s, line %d)", f.Type, f.RuleID, f.Line)
+		color.Yellow("  > Value: %s", utils.Redact(f.Value))
+		added, err := database.AddSecret(s.db, s.key, urlID, f.Type, f.Value, nativeSource, f.RuleID, f.Line, where)
 		if err != nil {
-			color.Red("Failed to create temp file: %v", err)
+			color.Red("Failed to save secret from %s: %v", fileURL, err)
 			continue
 		}
-		tmpFile.Write(body)
-		tmpFile.Close()
+		if added {
+			s.found++
+			s.notifier.Send(ctx, notify.Event{
+				Kind:     notify.KindSecret,
+				Severity: notify.SecretSeverity(false),
+				Title:    fmt.Sprintf("New %s secret (rule %s)", f.Type, f.RuleID),
+				URL:      fileURL,
+				Details:  fmt.Sprintf("Line %d: %s", f.Line, where),
+			})
+		}
+	}
+}
+
+// scanTrufflehog runs trufflehog over path, a file or a directory, and
+// stores what it finds for urlID.
+func (s *scan) scanTrufflehog(ctx context.Context, urlID int, fileURL, path string) {
+	// We use RunCommandAndCapture since trufflehog might print a lot of stuff to stderr
+	// that we don't want to pollute the main UI with. The output is what matters.
+	output, err := utils.RunCommandAndCapture(ctx, s.options, "trufflehog", "filesystem", path, "--json")
+	if err != nil {
+		// trufflehog exits with non-zero if it finds secrets, so we can't rely on the exit code
+		// but we should still log if there's a different kind of error.
+		if len(output) == 0 {
+			color.Red("Error running trufflehog on %s: %v", path, err)
+			return
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		var secret TruffleHogOutput
+		line := scanner.Text()
+		if err := json.Unmarshal([]byte(line), &secret); err == nil {
+			if secret.DetectorName != "" && secret.Raw != "" {
+				color.HiRed("[!] Secret Found in %s!", fileURL)
+				color.Yellow("  > Type: %s", secret.DetectorName)
+				color.Yellow("  > Value: %s", secret.Redacted) // Show redacted value for safety
+				added, err := database.AddSecret(s.db, s.key, urlID, secret.DetectorName, secret.Raw, "trufflehog", "", 0, "")
+				if err != nil {
+					color.Red("Failed to save secret from %s: %v", fileURL, err)
+					continue
+				}
+				if added {
+					s.found++
+					s.notifier.Send(ctx, notify.Event{
+						Kind:     notify.KindSecret,
+						Severity: notify.SecretSeverity(secret.Verified),
+						Title:    fmt.Sprintf("New %s secret (verified: %t)", secret.DetectorName, secret.Verified),
+						URL:      fileURL,
+						Details:  redactedDetails(secret.Redacted),
+					})
+				}
+			}
+		}
+	}
+}
 
-		// We use RunCommandAndCapture since trufflehog might print a lot of stuff to stderr
-		// that we don't want to pollute the main UI with. The output is what matters.
-		output, err := utils.RunCommandAndCapture(ctx, options, "trufflehog", "filesystem", tmpFile.Name(), "--json")
+// scanSourceMap looks for the source map of the script at jsURL. If there is
+// one, it unpacks its sources into the workspace, records the map, scans the
+// sources for secrets and stores the endpoints they reference. Secrets are
+// stored for the map URL, or for the script if the map is inline.
+func (s *scan) scanSourceMap(ctx context.Context, client *http.Client, sc *scope.Scope, targets map[int]string, urlID int, jsURL *url.URL, header http.Header, body []byte, probe bool) {
+	mapURLs := sourcemap.Locate(jsURL, header, body)
+	probed := false
+	if len(mapURLs) == 0 && probe {
+		mapURLs, probed = []string{sourcemap.Sibling(jsURL)}, true
+	}
+	for _, mapURL := range mapURLs {
+		inline := strings.HasPrefix(mapURL, "data:")
+		if !inline && !sc.Allow("source maps", mapURL) {
+			continue
+		}
+		m, err := sourcemap.Fetch(ctx, client, mapURL)
 		if err != nil {
-			// trufflehog exits with non-zero if it finds secrets, so we can't rely on the exit code
-			// but we should still log if there's a different kind of error.
-			if len(output) == 0 {
-				color.Red("Error running trufflehog on %s: %v", tmpFile.Name(), err)
-				continue
+			// Probed siblings are expected to be missing; only referenced maps are worth a warning.
+			if ctx.Err() == nil && !probed {
+				utils.Warn(fmt.Sprintf("Failed to fetch the source map of %s: %v", jsURL, err))
 			}
+			continue
+		}
+
+		files := m.Files()
+		dir := sourcemap.Dir(s.options.Output, jsURL)
+		if err := sourcemap.Unpack(dir, files); err != nil {
+			utils.Warn(fmt.Sprintf("Failed to unpack the source map of %s: %v", jsURL, err))
 		}
 
-		scanner := bufio.NewScanner(strings.NewReader(output))
-		for scanner.Scan() {
-			var secret TruffleHogOutput
-			line := scanner.Text()
-			if err := json.Unmarshal([]byte(line), &secret); err == nil {
-				if secret.DetectorName != "" && secret.Raw != "" {
-					color.HiRed("[!] Secret Found in %s!", jsURL)
-					color.Yellow("  > Type: %s", secret.DetectorName)
-					color.Yellow("  > Value: %s", secret.Redacted) // Show redacted value for safety
-					database.AddSecret(db, urlID, secret.DetectorName, secret.Raw, "trufflehog")
-					secretsFoundCount++
+		mapID, recorded := urlID, sourcemap.Inline
+		if !inline {
+			recorded = mapURL
+			if targetID := scope.TargetFor(jsURL.Hostname(), targets); targetID != -1 {
+				id, err := database.AddURL(s.db, targetID, mapURL, sourceMapSource)
+				if err != nil {
+					color.Red("Failed to save source map %s: %v", mapURL, err)
+					return
 				}
+				mapID = int(id)
 			}
 		}
+		if err := database.AddSourceMap(s.db, urlID, recorded, len(files), dir); err != nil {
+			color.Red("Failed to save source map %s: %v", recorded, err)
+			return
+		}
+		utils.Success(fmt.Sprintf("  [+] Source map %s: %d of %d sources embedded, unpacked to %s", recorded, len(files), len(m.Sources), dir))
+
+		urls, params := 0, 0
+		for _, f := range files {
+			if sourcemap.Vendored(f) {
+				continue
+			}
+			if s.rules != nil {
+				s.scanNative(ctx, mapID, mapURLOrScript(recorded, jsURL), f.Name, f.Content)
+			}
+			u, p, err := jsextract.Store(s.db, sc, targets, jsURL, jsextract.Extract(f.Content, jsURL))
+			if err != nil {
+				color.Red("Failed to save endpoints from %s: %v", f.Name, err)
+				continue
+			}
+			urls += u
+			params += p
+		}
+		if s.trufflehog && len(files) > 0 {
+			s.scanTrufflehog(ctx, mapID, mapURLOrScript(recorded, jsURL), dir)
+		}
+		if urls > 0 || params > 0 {
+			utils.Success(fmt.Sprintf("  [+] %d endpoints and %d parameters in the sources of %s", urls, params, jsURL))
+		}
+		return
+	}
+}
+
+func mapURLOrScript(mapURL string, jsURL *url.URL) string {
+	if mapURL == sourcemap.Inline {
+		return jsURL.String()
 	}
+	return mapURL
+}
 
-	color.Green("Secrets scanning phase completed. Found %d new secrets.", secretsFoundCount)
-} 
\ No newline at end of file
+// nativeSource is the source recorded for secrets found by the built-in rules.
+const nativeSource = "native"
+
+// sourceMapSource is the source recorded for the URLs of source maps.
+const sourceMapSource = "sourcemap"
+
+// engines resolves secrets.engine to the scanners to run. Without an engine,
+// trufflehog runs alongside the native scanner when it is installed.
+func engines(engine string) (native, trufflehog bool, err error) {
+	switch engine {
+	case "":
+		return true, utils.CommandExists("trufflehog"), nil
+	case "native":
+		return true, false, nil
+	case "trufflehog", "both":
+		if !utils.CommandExists("trufflehog") {
+			color.Yellow("Hint: go install github.com/trufflesecurity/trufflehog/v3@latest")
+			return false, false, fmt.Errorf("secrets.engine is '%s' but trufflehog is not installed", engine)
+		}
+		return engine == "both", true, nil
+	}
+	return false, false, fmt.Errorf("unknown secrets.engine '%s' (expected native, trufflehog or both)", engine)
+}
+
+// sourceMapMode resolves secrets.source_maps.
+func sourceMapMode(mode string) (enabled, probe bool, err error) {
+	switch mode {
+	case "", "probe":
+		return true, true, nil
+	case "referenced":
+		return true, false, nil
+	case "off":
+		return false, false, nil
+	}
+	return false, false, fmt.Errorf("unknown secrets.source_maps '%s' (expected probe, referenced or off)", mode)
+}
+
+// redactedDetails describes a secret for notifications without revealing it.
+func redactedDetails(redacted string) string {
+	if redacted == "" {
+		return ""
+	}
+	return "Value: " + redacted
+}
@@ -0,0 +1,223 @@
+// Package sourcemap finds the source maps of JavaScript files and unpacks the
+// original sources they embed, so that they can be analysed like the bundles.
+package sourcemap
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MaxSize bounds the size of a source map that is downloaded or decoded.
+const MaxSize = 50 << 20
+
+// Inline is recorded as the map URL of maps embedded in their script as a data URI.
+const Inline = "inline"
+
+// commentPattern finds the sourceMappingURL comment of a script. Old tools
+// wrote //@ instead of //#.
+var commentPattern = regexp.MustCompile(`(?m)(?://|/\*)[#@][ \t]*sourceMappingURL=([^\s'"*]+)`)
+
+// Map is a source map (revision 3). Only the embedded sources are used.
+type Map struct {
+	Version        int       `json:"version"`
+	File           string    `json:"file"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+}
+
+// Source is one original file of a source map.
+type Source struct {
+	// Name is the name given by the map, such as webpack://app/./src/index.ts.
+	Name string
+	// Path is a clean relative path to unpack the file to.
+	Path    string
+	Content []byte
+}
+
+// Locate returns the map URLs a script points to: the SourceMap (or older
+// X-SourceMap) response header, then its last sourceMappingURL comment, both
+// resolved against the script URL. Inline maps are returned as data URIs.
+func Locate(jsURL *url.URL, header http.Header, body []byte) []string {
+	var refs []string
+	for _, h := range []string{"SourceMap", "X-SourceMap"} {
+		if v := strings.TrimSpace(header.Get(h)); v != "" {
+			refs = append(refs, v)
+			break
+		}
+	}
+	if m := commentPattern.FindAllSubmatch(body, -1); len(m) > 0 {
+		refs = append(refs, string(m[len(m)-1][1]))
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, "data:") {
+			if !seen[ref] {
+				seen[ref] = true
+				urls = append(urls, ref)
+			}
+			continue
+		}
+		u, err := jsURL.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			urls = append(urls, s)
+		}
+	}
+	return urls
+}
+
+// Sibling returns <script>.map, where bundlers put maps by default. It is
+// worth trying for scripts that do not name their map.
+func Sibling(jsURL *url.URL) string {
+	sibling := *jsURL
+	sibling.RawQuery, sibling.Fragment = "", ""
+	sibling.Path += ".map"
+	return sibling.String()
+}
+
+// Fetch downloads and parses the source map at mapURL, or decodes it if it
+// is a data URI.
+func Fetch(ctx context.Context, client *http.Client, mapURL string) (*Map, error) {
+	var data []byte
+	if strings.HasPrefix(mapURL, "data:") {
+		var err error
+		if data, err = decodeDataURI(mapURL); err != nil {
+			return nil, err
+		}
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, mapURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, MaxSize+1)); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("larger than %d MB", MaxSize>>20)
+	}
+	// Maps may start with )]}' to prevent them from being run as scripts.
+	if i := strings.IndexByte(string(data[:min(len(data), 16)]), '{'); i > 0 {
+		data = data[i:]
+	}
+	var m Map
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("not a source map: %w", err)
+	}
+	if len(m.Sources) == 0 {
+		return nil, fmt.Errorf("not a source map: no sources")
+	}
+	return &m, nil
+}
+
+func decodeDataURI(uri string) ([]byte, error) {
+	meta, payload, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URI")
+	}
+	if len(payload) > MaxSize*4/3+4 {
+		return nil, fmt.Errorf("larger than %d MB", MaxSize>>20)
+	}
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	s, err := url.PathUnescape(payload)
+	return []byte(s), err
+}
+
+// Files returns the sources whose content the map embeds. Sources without
+// content only name files that would have to be fetched one by one.
+func (m *Map) Files() []Source {
+	var files []Source
+	used := make(map[string]bool)
+	for i, name := range m.Sources {
+		if i >= len(m.SourcesContent) || m.SourcesContent[i] == nil {
+			continue
+		}
+		p := cleanPath(name)
+		base := p
+		for n := 2; used[p]; n++ {
+			p = fmt.Sprintf("%s~%d", base, n)
+		}
+		used[p] = true
+		files = append(files, Source{Name: name, Path: p, Content: []byte(*m.SourcesContent[i])})
+	}
+	return files
+}
+
+// cleanPath turns a source name into a relative path that stays inside the
+// directory it is unpacked to: the scheme (webpack://, file://), query and
+// empty or "." segments are dropped and ".." segments are renamed.
+func cleanPath(name string) string {
+	p := strings.ReplaceAll(name, "\\", "/")
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+3:]
+	}
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	var parts []string
+	for _, part := range strings.Split(p, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			part = "__"
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "source"
+	}
+	return strings.Join(parts, "/")
+}
+
+// Unpack writes the sources below dir.
+func Unpack(dir string, files []Source) error {
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, f.Content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dir returns the directory the sources of the script at jsURL are unpacked
+// to: <workspace>/sourcemaps/<host>/<script path>.
+func Dir(workspace string, jsURL *url.URL) string {
+	host := strings.ReplaceAll(jsURL.Host, ":", "_")
+	return filepath.Join(workspace, "sourcemaps", host, filepath.FromSlash(cleanPath(jsURL.Path)))
+}
+
+// Vendored reports whether a source is a third-party package. Their secrets
+// and endpoints are not the target's, so they are unpacked but not analysed.
+func Vendored(s Source) bool {
+	return strings.Contains("/"+s.Path+"/", "/node_modules/")
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ProxySchemes lists the proxy URL schemes Sentinel accepts.
+var ProxySchemes = []string{"http", "https", "socks5"}
+
+// proxyFlag is how a tool is told to use the upstream proxy.
+type proxyFlag struct {
+	flag string
+	// schemes lists the proxy schemes the tool supports; nil means all of ProxySchemes.
+	schemes []string
+	// bare tools take host:port instead of a URL.
+	bare bool
+	// env tools have no flag and honour the HTTP_PROXY variables instead.
+	env bool
+	// offline tools never use the network, so they need no proxy.
+	offline bool
+}
+
+var httpOnly = []string{"http", "https"}
+
+// proxyFlags lists how every tool Sentinel runs can use the upstream proxy.
+// The proxy environment variables are set for every tool as well.
+var proxyFlags = map[string]proxyFlag{
+	"subfinder":    {flag: "-proxy", schemes: httpOnly},
+	"gau":          {flag: "--proxy"},
+	"dnsx":         {flag: "-proxy", schemes: []string{"socks5"}},
+	"naabu":        {flag: "-proxy", schemes: []string{"socks5"}, bare: true},
+	"httpx":        {flag: "-http-proxy"},
+	"katana":       {flag: "-proxy"},
+	"ffuf":         {flag: "-x"},
+	"nuclei":       {flag: "-proxy"},
+	"gowitness":    {flag: "--chrome-proxy"},
+	"arjun":        {env: true, schemes: httpOnly},
+	"trufflehog":   {env: true},
+	"searchsploit": {offline: true},
+}
+
+// ParseProxy validates a proxy URL such as http://127.0.0.1:8080 or
+// socks5://127.0.0.1:9050.
+func ParseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy '%s': %w", proxy, err)
+	}
+	if !containsString(ProxySchemes, u.Scheme) {
+		return nil, fmt.Errorf("invalid proxy '%s': the scheme must be one of %s", proxy, strings.Join(ProxySchemes, ", "))
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("invalid proxy '%s': expected <scheme>://<host>:<port>", proxy)
+	}
+	return u, nil
+}
+
+// ProxySupport reports whether a tool can send its traffic through the proxy,
+// and how. Tools Sentinel does not know about are assumed not to.
+func ProxySupport(tool, proxy string) (bool, string) {
+	f, ok := proxyFlags[tool]
+	if !ok {
+		return false, "unknown tool"
+	}
+	if f.offline {
+		return true, "no network traffic"
+	}
+	u, err := ParseProxy(proxy)
+	if err != nil {
+		return false, err.Error()
+	}
+	if f.schemes != nil && !containsString(f.schemes, u.Scheme) {
+		return false, fmt.Sprintf("%s proxies are not supported, only %s", u.Scheme, strings.Join(f.schemes, ", "))
+	}
+	if f.env {
+		return true, "HTTP_PROXY environment"
+	}
+	return true, f.flag
+}
+
+// proxyArgs returns the flags that make a tool use the configured proxy.
+func proxyArgs(o Options, tool string) []string {
+	f, ok := proxyFlags[tool]
+	if o.Proxy == "" || !ok || f.flag == "" {
+		return nil
+	}
+	if supported, _ := ProxySupport(tool, o.Proxy); !supported {
+		return nil
+	}
+	value := o.Proxy
+	if f.bare {
+		u, _ := url.Parse(o.Proxy)
+		value = u.Host
+	}
+	return []string{f.flag, value}
+}
+
+// proxyEnv returns the environment variables that point HTTP clients at the proxy.
+func proxyEnv(proxy string) []string {
+	if proxy == "" {
+		return nil
+	}
+	var env []string
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY"} {
+		env = append(env, name+"="+proxy, strings.ToLower(name)+"="+proxy)
+	}
+	return env
+}
+
+// redactProxy masks the credentials of the configured proxy in a command line
+// that is echoed to the user. A user name without a password may be a token,
+// so it is masked as well.
+func redactProxy(o Options, args []string) string {
+	line := strings.Join(args, " ")
+	u, err := url.Parse(o.Proxy)
+	if o.Proxy == "" || err != nil || u.User == nil {
+		return line
+	}
+	masked := *u
+	if _, ok := u.User.Password(); ok {
+		masked.User = url.UserPassword(u.User.Username(), "xxxxx")
+	} else {
+		masked.User = url.User("xxxxx")
+	}
+	return strings.ReplaceAll(line, o.Proxy, masked.String())
+}
+
+// warnedProxy remembers the tools already reported as bypassing the proxy.
+var warnedProxy sync.Map
+
+// warnProxyBypass warns, once per tool, when a tool cannot use the configured proxy.
+func warnProxyBypass(o Options, tool string) {
+	if o.Proxy == "" {
+		return
+	}
+	supported, reason := ProxySupport(tool, o.Proxy)
+	if supported {
+		return
+	}
+	if _, seen := warnedProxy.LoadOrStore(tool, true); !seen {
+		Warn(fmt.Sprintf("%s cannot use the proxy (%s); its traffic is not proxied.", tool, reason))
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
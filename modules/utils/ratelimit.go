@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateFlag is the flag that caps how many requests (or packets, for naabu) a
+// tool sends per second.
+type rateFlag struct {
+	flag string
+	// thirdParty tools talk to data sources or DNS resolvers rather than to
+	// the targets, so only the global limit applies to them.
+	thirdParty bool
+	// threads is set for tools whose flag caps how many requests they have
+	// in flight rather than their rate, and is their default. The flag is
+	// only passed to go below it; since each of their requests takes a second
+	// or more, that many in flight keeps them within the rate.
+	threads int
+}
+
+// rateFlags lists the tools whose rate can be limited. None of the tools that
+// talk to the targets can limit each host separately, so they are held to the
+// per-host limit overall when it is lower than the global one. Tools without
+// a flag cannot be limited, which is reported when a limit applies to them.
+var rateFlags = map[string]rateFlag{
+	"subfinder": {flag: "-rl", thirdParty: true},
+	"dnsx":      {flag: "-rl", thirdParty: true},
+	"naabu":     {flag: "-rate"},
+	"httpx":     {flag: "-rl"},
+	"katana":    {flag: "-rl"},
+	"ffuf":      {flag: "-rate"},
+	"nuclei":    {flag: "-rl"},
+	"arjun":     {flag: "--rate-limit"},
+	"gowitness": {flag: "--threads", threads: 4},
+	"gau":       {thirdParty: true},
+}
+
+// RateLimited reports whether any rate limit is configured.
+func (o Options) RateLimited() bool {
+	if o.RateLimit.RequestsPerSecond > 0 || o.RateLimit.PerHost > 0 {
+		return true
+	}
+	for _, rate := range o.RateLimit.Tools {
+		if rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolRate returns the requests per second a tool is limited to, or 0 if it is
+// not limited. A per-tool override takes precedence over the computed limit.
+func (o Options) ToolRate(tool string) int {
+	if rate, ok := o.RateLimit.Tools[tool]; ok && rate > 0 {
+		return rate
+	}
+	f, ok := rateFlags[tool]
+	if !ok {
+		return 0
+	}
+	rate := o.RateLimit.RequestsPerSecond
+	if perHost := o.RateLimit.PerHost; !f.thirdParty && perHost > 0 && (rate <= 0 || perHost < rate) {
+		rate = perHost
+	}
+	if rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// rateLimitArgs returns the flags that apply the configured rate limit to a tool.
+func rateLimitArgs(o Options, tool string) []string {
+	f, ok := rateFlags[tool]
+	if !ok {
+		return nil
+	}
+	rate := o.ToolRate(tool)
+	switch {
+	case rate == 0:
+		return nil
+	case f.flag == "":
+		if _, seen := warnedRate.LoadOrStore(tool, true); !seen {
+			Warn(fmt.Sprintf("%s has no rate limit flag; it cannot be held to %d requests per second.", tool, rate))
+		}
+		return nil
+	case f.threads > 0:
+		if rate >= f.threads {
+			return nil
+		}
+		if _, seen := warnedRate.LoadOrStore(tool, true); !seen {
+			Warn(fmt.Sprintf("%s has no rate limit flag; it is limited to %d request(s) at a time instead.", tool, rate))
+		}
+	}
+	return []string{f.flag, strconv.Itoa(rate)}
+}
+
+// warnedRate remembers the tools already reported as not fully rate limited.
+var warnedRate sync.Map
+
+// Limiter spaces out requests so that no more than a global number per second
+// are sent overall and no more than a per-host number to any single host.
+// Requests are spread evenly rather than sent in bursts.
+type Limiter struct {
+	mu         sync.Mutex
+	global     time.Duration
+	perHost    time.Duration
+	nextGlobal time.Time
+	nextHost   map[string]time.Time
+}
+
+// NewLimiter returns a limiter for the given requests per second. Zero means no
+// limit; a limiter without any limit never waits.
+func NewLimiter(global, perHost int) *Limiter {
+	l := &Limiter{nextHost: make(map[string]time.Time)}
+	if global > 0 {
+		l.global = time.Second / time.Duration(global)
+	}
+	if perHost > 0 {
+		l.perHost = time.Second / time.Duration(perHost)
+	}
+	return l
+}
+
+// Wait blocks until a request to host may be sent, or the context is done.
+func (l *Limiter) Wait(ctx context.Context, host string) error {
+	if l == nil || (l.global == 0 && l.perHost == 0) {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	slot := time.Now()
+	if l.nextGlobal.After(slot) {
+		slot = l.nextGlobal
+	}
+	if next := l.nextHost[host]; l.perHost > 0 && next.After(slot) {
+		slot = next
+	}
+	if l.global > 0 {
+		l.nextGlobal = slot.Add(l.global)
+	}
+	if l.perHost > 0 {
+		l.nextHost[host] = slot.Add(l.perHost)
+	}
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limitedTransport waits for the limiter before every request.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// HTTPClient returns the client Sentinel uses for its own requests to the
+// targets. It sends them through the configured proxy and honours the
+// configured rate limits, including for redirects.
+func HTTPClient(o Options, timeout time.Duration) (*http.Client, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if o.Proxy != "" {
+		proxyURL, err := ParseProxy(o.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+	var transport http.RoundTripper = base
+	if o.RateLimited() {
+		transport = &limitedTransport{
+			base:    base,
+			limiter: NewLimiter(o.RateLimit.RequestsPerSecond, o.RateLimit.PerHost),
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
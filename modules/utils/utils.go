@@ -8,6 +8,8 @@ import (
 	"os/exec"
 	"strings"
 
+	"sentinel/modules/config"
+
 	"github.com/fatih/color"
 )
 
@@ -16,6 +18,12 @@ type Options struct {
 	Output  string
 	Threads int
 	Env     map[string]string
+	// RateLimit is translated into each tool's own rate flags by RunCommand
+	// and RunCommandAndCapture, and enforced by HTTPClient.
+	RateLimit config.RateLimit
+	// Proxy is the upstream proxy URL passed to every tool through its own
+	// flag and the HTTP_PROXY variables, and used by HTTPClient.
+	Proxy string
 }
 
 // CommandExists checks if a command exists in the system's PATH.
@@ -32,34 +40,18 @@ func Banner(text string) {
 // RunCommand executes an external command and prints its output.
 // It accepts a context to allow for cancellation.
 func RunCommand(ctx context.Context, options Options, name string, args ...string) error {
-	fmt.Println(color.GreenString("▶ Running: %s %s", name, strings.Join(args, " ")))
-	cmd := exec.CommandContext(ctx, name, args...)
-	if options.Env != nil {
-		cmd.Env = os.Environ()
-		for k, v := range options.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
+	cmd := command(ctx, options, name, args)
+	fmt.Println(color.GreenString("▶ Running: %s %s", name, redactProxy(options, cmd.Args[1:])))
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	// The new modules often need to be run from the workspace directory
-	// to handle relative paths for output correctly.
-	cmd.Dir = options.Output
 	return cmd.Run()
 }
 
 // RunCommandAndCapture executes a command and returns its output.
 // It accepts a context to allow for cancellation.
 func RunCommandAndCapture(ctx context.Context, options Options, name string, args ...string) (string, error) {
-	fmt.Println(color.GreenString("▶ Capturing: %s %s", name, strings.Join(args, " ")))
-	cmd := exec.CommandContext(ctx, name, args...)
-	if options.Env != nil {
-		cmd.Env = os.Environ()
-		for k, v := range options.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
-	cmd.Dir = options.Output
+	cmd := command(ctx, options, name, args)
+	fmt.Println(color.GreenString("▶ Capturing: %s %s", name, redactProxy(options, cmd.Args[1:])))
 
 	var out bytes.Buffer
 	// To provide verbose output, we'll pipe stderr to the user's terminal in real-time.
@@ -73,3 +65,49 @@ func RunCommandAndCapture(ctx context.Context, options Options, name string, arg
 	}
 	return out.String(), nil
 }
+
+// command prepares a tool invocation, adding the flags and environment that
+// apply the configured rate limits and proxy.
+func command(ctx context.Context, options Options, name string, args []string) *exec.Cmd {
+	warnProxyBypass(options, name)
+	args = append(args, rateLimitArgs(options, name)...)
+	args = append(args, proxyArgs(options, name)...)
+	cmd := exec.CommandContext(ctx, name, args...)
+	if options.Env != nil || options.Proxy != "" {
+		cmd.Env = os.Environ()
+		for k, v := range options.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		}
+		cmd.Env = append(cmd.Env, proxyEnv(options.Proxy)...)
+	}
+	// The new modules often need to be run from the workspace directory
+	// to handle relative paths for output correctly.
+	cmd.Dir = options.Output
+	return cmd
+}
+
+// severityRanks orders nuclei-style severities from least to most severe.
+var severityRanks = map[string]int{
+	"info":     0,
+	"low":      1,
+	"medium":   2,
+	"high":     3,
+	"critical": 4,
+}
+
+// SeverityRank returns the rank of a severity name, or -1 if it is unknown.
+func SeverityRank(severity string) int {
+	if rank, ok := severityRanks[strings.ToLower(strings.TrimSpace(severity))]; ok {
+		return rank
+	}
+	return -1
+}
+
+// Redact hides a secret value, keeping only a short prefix so findings can
+// still be told apart.
+func Redact(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", 8)
+}
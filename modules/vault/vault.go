@@ -0,0 +1,223 @@
+// Package vault encrypts the secret values stored in a workspace database.
+//
+// Every workspace has its own key, derived from a random salt kept in the
+// workspace and either a passphrase or a raw key taken from the environment,
+// so that a copied database is useless without them. Without either, a random
+// key is generated for the workspace and kept in its KeyFile. Values are sealed with
+// AES-256-GCM; a keyed fingerprint of each value lets known secrets be
+// recognised without decrypting them.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables the key is taken from. KeyEnv wins when both are set.
+const (
+	// KeyEnv holds a base64-encoded 32-byte key, e.g. from `openssl rand -base64 32`.
+	KeyEnv = "SENTINEL_SECRETS_KEY"
+	// PassphraseEnv holds a passphrase the key is derived from.
+	PassphraseEnv = "SENTINEL_SECRETS_PASSPHRASE"
+)
+
+// KeyFile is the file of a workspace directory that holds the key generated
+// for it when neither KeyEnv nor PassphraseEnv is set. It only protects a
+// database copied without it.
+const KeyFile = "secrets.key"
+
+// prefix marks sealed values and the format they are sealed in.
+const prefix = "enc:v1:"
+
+// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+const pbkdf2Iterations = 600000
+
+// SaltSize is the size of the per-workspace salt.
+const SaltSize = 16
+
+// ErrNoKey is returned when neither KeyEnv nor PassphraseEnv is set.
+var ErrNoKey = fmt.Errorf("no secrets key: set %s to a passphrase or %s to a base64-encoded 32-byte key", PassphraseEnv, KeyEnv)
+
+// ErrNoKeyFile is returned when a workspace has no KeyFile to read.
+var ErrNoKeyFile = fmt.Errorf("no %s in the workspace", KeyFile)
+
+// Key encrypts and fingerprints the secrets of one workspace.
+type Key struct {
+	aead  cipher.AEAD
+	mac   []byte
+	check string
+}
+
+// Configured reports whether a key or passphrase is set in the environment.
+func Configured() bool {
+	return os.Getenv(KeyEnv) != "" || os.Getenv(PassphraseEnv) != ""
+}
+
+// NewSalt returns a random salt for a new workspace.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("could not generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// FromEnv derives the key of the workspace with the given salt from the
+// environment.
+func FromEnv(salt []byte) (*Key, error) {
+	if raw := strings.TrimSpace(os.Getenv(KeyEnv)); raw != "" {
+		return fromRaw(raw, salt, KeyEnv)
+	}
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		return nil, ErrNoKey
+	}
+	master, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(master)
+}
+
+// FromFile reads the key generated for the workspace directory with the given
+// salt. When the workspace has none, a new one is generated if create is set,
+// and ErrNoKeyFile is returned otherwise.
+func FromFile(workspace string, salt []byte, create bool) (*Key, error) {
+	path := filepath.Join(workspace, KeyFile)
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		raw, err = createKeyFile(path)
+	}
+	if os.IsNotExist(err) {
+		return nil, ErrNoKeyFile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return fromRaw(strings.TrimSpace(string(raw)), salt, path)
+}
+
+// createKeyFile writes a random base64-encoded key to path, readable only by
+// its owner. When another process creates it first, that key is returned.
+func createKeyFile(path string) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("could not generate key: %w", err)
+	}
+	raw := []byte(base64.StdEncoding.EncodeToString(secret) + "\n")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create %s: %w", path, err)
+	}
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return raw, f.Close()
+}
+
+// fromRaw derives the key of a workspace from a base64-encoded 32-byte key
+// read from source.
+func fromRaw(raw string, salt []byte, source string) (*Key, error) {
+	secret, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(secret) != 32 {
+		return nil, fmt.Errorf("%s must be a base64-encoded 32-byte key", source)
+	}
+	// Mixing in the salt gives every workspace its own key.
+	master, err := hkdf.Key(sha256.New, secret, salt, "sentinel workspace key", 32)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(master)
+}
+
+// newKey derives separate subkeys for encryption, fingerprints and the check value.
+func newKey(master []byte) (*Key, error) {
+	sub := func(info string) ([]byte, error) {
+		return hkdf.Key(sha256.New, master, nil, info, 32)
+	}
+	encKey, err := sub("encryption")
+	if err != nil {
+		return nil, err
+	}
+	macKey, err := sub("fingerprint")
+	if err != nil {
+		return nil, err
+	}
+	checkKey, err := sub("check")
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead, mac: macKey, check: hex.EncodeToString(checkKey[:16])}, nil
+}
+
+// Check returns a value that identifies the key without revealing it. It is
+// stored with the salt so that a wrong passphrase is detected before anything
+// is encrypted with it.
+func (k *Key) Check() string {
+	return k.check
+}
+
+// Encrypt seals a value. Each call uses a fresh nonce.
+func (k *Key) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := k.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt.
+func (k *Key) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("value is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("could not decrypt value: wrong key or corrupted data")
+	}
+	return string(plaintext), nil
+}
+
+// Fingerprint returns a keyed hash of a value. Equal values have equal
+// fingerprints, which is how a secret seen again is recognised.
+func (k *Key) Fingerprint(value string) string {
+	h := hmac.New(sha256.New, k.mac)
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// IsEncrypted reports whether a stored value was sealed by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
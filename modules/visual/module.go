@@ -0,0 +1,32 @@
+package visual
+
+import (
+	"context"
+	"database/sql"
+
+	"sentinel/modules/config"
+	"sentinel/modules/registry"
+)
+
+// Module exposes this package to the module registry as 'visual'.
+type Module struct{}
+
+func init() {
+	registry.Register(Module{})
+}
+
+func (Module) Name() string { return "visual" }
+
+func (Module) Description() string {
+	return "Take screenshots of all live web services"
+}
+
+func (Module) RequiredTools() []string { return []string{"gowitness"} }
+
+func (Module) Inputs() []string { return []string{registry.LiveURLs} }
+
+func (Module) Outputs() []string { return []string{registry.Screenshots} }
+
+func (Module) Run(ctx context.Context, cfg *config.Config, db *sql.DB) error {
+	return RunVisual(ctx, cfg, db)
+}
@@ -9,34 +9,45 @@ import (
 
 	"sentinel/modules/config"
 	"sentinel/modules/database"
+	"sentinel/modules/scope"
 	"sentinel/modules/utils"
 	"github.com/fatih/color"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func RunVisual(ctx context.Context, config *config.Config, db *sql.DB) {
+// RunVisual screenshots every live URL with gowitness and records the image paths.
+func RunVisual(ctx context.Context, config *config.Config, db *sql.DB) error {
 	options := utils.Options{
-		Output:  config.Workspace,
-		Threads: config.Recon.Threads,
+		Output:    config.Workspace,
+		Threads:   config.Recon.Threads,
+		RateLimit: config.RateLimit,
+		Proxy:     config.Proxy,
 	}
 	color.Cyan("[*] Starting Visual Reconnaissance phase")
 
+	sc, err := scope.New(config)
+	if err != nil {
+		color.Red("Invalid scope configuration: %v", err)
+		return err
+	}
+
 	if !utils.CommandExists("gowitness") {
 		color.Red("gowitness not found. Please install it first.")
 		color.Yellow("Hint: go install github.com/sensepost/gowitness@latest")
-		return
+		return fmt.Errorf("gowitness not found")
 	}
 
 	utils.Banner("Fetching live URLs for screenshotting")
 	urls, err := database.GetLiveURLs(db)
 	if err != nil {
 		color.Red("Error getting URLs from database: %v", err)
-		return
+		return err
 	}
 
+	urls = sc.Filter("gowitness input", urls)
 	if len(urls) == 0 {
 		color.Yellow("No live URLs found to screenshot.")
-		return
+		return nil
 	}
 	color.Green("Found %d live URLs to screenshot.", len(urls))
 
@@ -48,7 +59,7 @@ func RunVisual(ctx context.Context, config *config.Config, db *sql.DB) {
 	tempInputFile, err := os.CreateTemp(options.Output, "gowitness-input-*.txt")
 	if err != nil {
 		color.Red("Failed to create temp input file: %v", err)
-		return
+		return err
 	}
 	defer os.Remove(tempInputFile.Name())
 
@@ -59,21 +70,26 @@ func RunVisual(ctx context.Context, config *config.Config, db *sql.DB) {
 
 	utils.Banner("Running gowitness... this may take a while")
 	// gowitness command to use our temp file and output to our designated screenshot directory
-	utils.RunCommand(ctx, options, "gowitness", "file", "-f", tempInputFile.Name(), "-d", screenshotDir, "--db-path", gowitnessDBPath)
+	if err := utils.RunCommand(ctx, options, "gowitness", "file", "-f", tempInputFile.Name(), "-d", screenshotDir, "--db-path", gowitnessDBPath); err != nil {
+		utils.Warn(fmt.Sprintf("gowitness exited with an error: %v", err))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
 
 	utils.Banner("Updating database with screenshot paths")
 	// Now, read the gowitness database to get the paths
 	gwDB, err := sql.Open("sqlite3", gowitnessDBPath)
 	if err != nil {
 		color.Red("Failed to open gowitness database at %s: %v", gowitnessDBPath, err)
-		return
+		return err
 	}
 	defer gwDB.Close()
 
 	rows, err := gwDB.Query("SELECT url, screenshot_path FROM urls WHERE screenshot_path IS NOT NULL")
 	if err != nil {
 		color.Red("Failed to query gowitness database: %v", err)
-		return
+		return err
 	}
 	defer rows.Close()
 
@@ -89,4 +105,5 @@ func RunVisual(ctx context.Context, config *config.Config, db *sql.DB) {
 	}
 	color.Green("Visual recon phase completed. Updated %d screenshot paths in the database.", updateCount)
 	color.Cyan("Screenshots are saved in: %s", screenshotDir)
+	return nil
 } 
\ No newline at end of file
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"sentinel/modules/database"
+
+	"github.com/fatih/color"
+)
+
+const showSecretUsage = "show secret [id] [--reveal]"
+
+// shownSecret is what 'show secret' returns as data. Value is only set when
+// the secret is revealed.
+type shownSecret struct {
+	database.StoredSecret
+	Value string `json:"value,omitempty"`
+}
+
+// runShowSecret lists the stored secrets, redacted, or only the one with the
+// ID given in args. With reveal set, that secret is decrypted with the
+// workspace key; revealing requires an ID so that values are only ever
+// decrypted one at a time.
+func runShowSecret(args []string, reveal bool) ([]shownSecret, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("usage: %s", showSecretUsage)
+	}
+	var id int64
+	if len(args) == 1 {
+		n, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid secret ID '%s'", args[0])
+		}
+		id = n
+	}
+	if reveal && id == 0 {
+		return nil, fmt.Errorf("--reveal needs the ID of a secret (usage: %s)", showSecretUsage)
+	}
+
+	stored, err := database.GetSecrets(db, id)
+	if err != nil {
+		return nil, err
+	}
+	shown := make([]shownSecret, len(stored))
+	for i, s := range stored {
+		shown[i] = shownSecret{StoredSecret: s}
+	}
+	if reveal {
+		key, err := database.SecretKey(db, appConfig.Workspace)
+		if err != nil {
+			return nil, err
+		}
+		if shown[0].Value, err = key.Decrypt(stored[0].Value); err != nil {
+			return nil, fmt.Errorf("could not reveal secret %d: %w", id, err)
+		}
+	}
+
+	if len(shown) == 0 {
+		color.Yellow("No secrets found.")
+		return shown, nil
+	}
+	for _, s := range shown {
+		value := s.Redacted
+		if s.Value != "" {
+			value = s.Value
+		}
+		where := s.Source
+		if s.RuleID != "" {
+			where += ", rule " + s.RuleID
+		}
+		if s.Line > 0 {
+			where += fmt.Sprintf(", line %d", s.Line)
+		}
+		fmt.Printf("%s %s %s\n", color.CyanString("[%d]", s.ID), color.YellowString(s.Type), value)
+		fmt.Printf("     %s (%s)\n", s.URL, where)
+	}
+	return shown, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"sentinel/modules/database"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/fatih/color"
+)
+
+const showUsage = "show [<table> [<column><op><value>...] [--columns a,b] [--sort [-]column] [--limit n] [--page n] [--include-removed] [--include-false-positives]] | " + showSecretUsage
+
+// defaultPageSize is the number of rows 'show <table>' prints without --limit.
+const defaultPageSize = 50
+
+// maxCellWidth bounds the width of a table cell; longer values are cut.
+const maxCellWidth = 60
+
+// showResult is what the CLI returns as data for 'show <table>'.
+type showResult struct {
+	Table   string                 `json:"table"`
+	Columns []string               `json:"columns"`
+	Rows    []map[string]any       `json:"rows"`
+	Total   int                    `json:"total"`
+	Page    int                    `json:"page"`
+	Pages   int                    `json:"pages"`
+	Targets []database.TargetCount `json:"targets,omitempty"`
+}
+
+// showOptionSuggestions suggests the tables 'show' can list.
+func showOptionSuggestions() []prompt.Suggest {
+	options := []prompt.Suggest{{Text: "secret", Description: "Stored secrets by ID; decrypt one with --reveal"}}
+	for _, name := range database.ViewNames() {
+		options = append(options, prompt.Suggest{Text: name, Description: "Columns: " + strings.Join(database.ViewColumns(name), ", ")})
+	}
+	return options
+}
+
+// runShowTable prints a page of a database view as a table, followed by the
+// number of matching rows per target. args are filter expressions such as
+// status=200, which combine with those of --filter.
+func runShowTable(name string, args []string, opts *cliFlags) (*showResult, error) {
+	if database.ViewColumns(name) == nil {
+		return nil, fmt.Errorf("unknown table '%s' (expected secret or one of: %s)", name, strings.Join(database.ViewNames(), ", "))
+	}
+	if opts.limit < 0 {
+		return nil, fmt.Errorf("invalid --limit %d", opts.limit)
+	}
+	if opts.page < 1 {
+		return nil, fmt.Errorf("invalid --page %d", opts.page)
+	}
+
+	q := database.ViewQuery{
+		IncludeRemoved:        opts.includeRemoved,
+		IncludeFalsePositives: opts.includeFP,
+		Columns:               opts.columns,
+		Sort:                  opts.sort,
+		Limit:                 opts.limit,
+		Offset:                (opts.page - 1) * opts.limit,
+	}
+	for _, expr := range append(append([]string{}, opts.filters...), args...) {
+		f, err := database.ParseFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		q.Filters = append(q.Filters, f)
+	}
+	table, err := database.QueryView(db, name, q)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := database.CountViewByTarget(db, name, q)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &showResult{Table: name, Columns: table.Columns, Rows: []map[string]any{}, Total: table.Total, Page: opts.page, Pages: 1, Targets: targets}
+	if opts.limit > 0 && table.Total > 0 {
+		res.Pages = (table.Total + opts.limit - 1) / opts.limit
+	}
+	for _, row := range table.Rows {
+		m := make(map[string]any, len(row))
+		for i, v := range row {
+			m[table.Columns[i]] = v
+		}
+		res.Rows = append(res.Rows, m)
+	}
+
+	printTable(table)
+	if table.Total == 0 {
+		color.Yellow("No %s found.", name)
+		return res, nil
+	}
+	first := q.Offset + 1
+	if len(table.Rows) == 0 {
+		color.Yellow("Page %d is past the last page (%d).", res.Page, res.Pages)
+	} else {
+		fmt.Printf("\nShowing %d-%d of %d %s (page %d of %d).\n", first, first+len(table.Rows)-1, table.Total, name, res.Page, res.Pages)
+	}
+	if len(targets) > 0 {
+		var parts []string
+		for _, t := range targets {
+			target := t.Target
+			if target == "" {
+				target = "(none)"
+			}
+			parts = append(parts, fmt.Sprintf("%s %d", target, t.Count))
+		}
+		fmt.Printf("By target: %s\n", strings.Join(parts, ", "))
+	}
+	return res, nil
+}
+
+// printTable prints the rows of a table in aligned columns.
+func printTable(t *database.Table) {
+	if len(t.Rows) == 0 {
+		return
+	}
+	cells := make([][]string, len(t.Rows))
+	widths := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		widths[i] = utf8.RuneCountInString(c)
+	}
+	for r, row := range t.Rows {
+		cells[r] = make([]string, len(row))
+		for i, v := range row {
+			cell := strings.Join(strings.Fields(database.FormatValue(v)), " ")
+			if utf8.RuneCountInString(cell) > maxCellWidth {
+				cell = string([]rune(cell)[:maxCellWidth-3]) + "..."
+			}
+			cells[r][i] = cell
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	pad := func(s string, width int) string {
+		return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+	}
+	header := make([]string, len(t.Columns))
+	rule := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = pad(strings.ToUpper(c), widths[i])
+		rule[i] = strings.Repeat("-", widths[i])
+	}
+	fmt.Println(color.CyanString(strings.TrimRight(strings.Join(header, "  "), " ")))
+	fmt.Println(color.HiBlackString(strings.Join(rule, "  ")))
+	for _, row := range cells {
+		line := make([]string, len(row))
+		for i, cell := range row {
+			line[i] = pad(cell, widths[i])
+		}
+		fmt.Println(strings.TrimRight(strings.Join(line, "  "), " "))
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sentinel/modules/database"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/fatih/color"
+)
+
+const triageUsage = "triage [<vuln|secret> <id>... [status] [--note text] [--assignee name|-]]"
+
+var triageOptions = []prompt.Suggest{
+	{Text: database.FindingVuln, Description: "Triage vulnerabilities by ID (e.g. 'triage vuln 12 false-positive --note \"WAF page\"')"},
+	{Text: database.FindingSecret, Description: "Triage secrets by ID (e.g. 'triage secret 3 accepted-risk')"},
+}
+
+// triageStatusSuggestions suggests the statuses a finding can be given.
+func triageStatusSuggestions() []prompt.Suggest {
+	var options []prompt.Suggest
+	for _, status := range database.Statuses {
+		options = append(options, prompt.Suggest{Text: strings.ReplaceAll(status, "_", "-")})
+	}
+	return options
+}
+
+// triageRequest is a parsed 'triage' command line.
+type triageRequest struct {
+	kind   string
+	ids    []int64
+	update database.TriageUpdate
+}
+
+// parseTriageArgs splits the arguments of 'triage' into the finding kind, the
+// IDs and the status. Without arguments the request only counts statuses.
+func parseTriageArgs(args []string, note, assignee string) (*triageRequest, error) {
+	req := &triageRequest{update: database.TriageUpdate{Note: note, Assignee: assignee}}
+	if len(args) == 0 {
+		if note != "" || assignee != "" {
+			return nil, fmt.Errorf("usage: %s", triageUsage)
+		}
+		return req, nil
+	}
+	req.kind = args[0]
+	if req.kind != database.FindingVuln && req.kind != database.FindingSecret {
+		return nil, fmt.Errorf("unknown finding kind '%s' (expected %s or %s)", req.kind, database.FindingVuln, database.FindingSecret)
+	}
+	for _, arg := range args[1:] {
+		if id, err := strconv.ParseInt(arg, 10, 64); err == nil && id > 0 {
+			req.ids = append(req.ids, id)
+			continue
+		}
+		if req.update.Status != "" {
+			return nil, fmt.Errorf("usage: %s", triageUsage)
+		}
+		status, err := database.ParseStatus(arg)
+		if err != nil {
+			return nil, err
+		}
+		req.update.Status = status
+	}
+	if len(req.ids) == 0 {
+		return nil, fmt.Errorf("no %s IDs given (usage: %s)", req.kind, triageUsage)
+	}
+	return req, nil
+}
+
+// runTriage shows or changes the triage state of vulnerabilities and
+// secrets. Without arguments it counts the findings per status; with IDs and
+// nothing to change it shows their current state.
+func runTriage(args []string, opts *cliFlags) (any, error) {
+	req, err := parseTriageArgs(args, opts.note, opts.assignee)
+	if err != nil {
+		return nil, err
+	}
+	if req.kind == "" {
+		return printTriageCounts()
+	}
+
+	var triaged []*database.Triage
+	for _, id := range req.ids {
+		t, err := database.TriageFinding(db, req.kind, id, req.update)
+		if err != nil {
+			return triaged, err
+		}
+		triaged = append(triaged, t)
+		printTriage(t)
+	}
+	if req.update != (database.TriageUpdate{}) {
+		color.Green("Triaged %d %s finding(s).", len(triaged), req.kind)
+	}
+	return triaged, nil
+}
+
+func printTriage(t *database.Triage) {
+	status := t.Status
+	switch {
+	case database.Suppressed(status):
+		status = color.HiBlackString(status)
+	case status == database.StatusConfirmed:
+		status = color.RedString(status)
+	case status == database.StatusFixed:
+		status = color.GreenString(status)
+	}
+	fmt.Printf("%s %s %s\n", color.CyanString("[%s %d]", t.Kind, t.ID), status, t.Finding)
+	if c := t.Classification; c != nil {
+		var parts []string
+		if c.CVSSScore > 0 {
+			parts = append(parts, fmt.Sprintf("CVSS %.1f", c.CVSSScore))
+		}
+		parts = append(parts, c.CVEIDs...)
+		parts = append(parts, c.CWEIDs...)
+		if len(parts) > 0 {
+			fmt.Printf("     %s\n", color.YellowString(strings.Join(parts, ", ")))
+		}
+	}
+	if t.Assignee != "" {
+		fmt.Printf("     assignee: %s\n", t.Assignee)
+	}
+	for _, line := range strings.Split(t.Notes, "\n") {
+		if line != "" {
+			fmt.Printf("     %s\n", color.WhiteString(line))
+		}
+	}
+}
+
+func printTriageCounts() (map[string]map[string]int, error) {
+	counts, err := database.TriageCounts(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range []string{database.FindingVuln, database.FindingSecret} {
+		var parts []string
+		for _, status := range database.Statuses {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind][status], status))
+		}
+		fmt.Printf("%-8s %s\n", color.CyanString(kind), strings.Join(parts, ", "))
+	}
+	return counts, nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"sentinel/modules/config"
+	"sentinel/modules/database"
+
+	"github.com/c-bata/go-prompt"
+	"github.com/fatih/color"
+)
+
+const workspaceUsage = "workspace <list|create|switch|rename|delete|archive> [name] [new-name] [--force]"
+
+// archiveDir is where 'workspace archive' puts its archives, next to config.yaml.
+const archiveDir = "archives"
+
+// workspaceName restricts the workspaces that can be created, deleted or
+// archived to plain directory names in the working directory.
+var workspaceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+var workspaceOptions = []prompt.Suggest{
+	{Text: "list", Description: "List the workspaces and their asset counts"},
+	{Text: "create", Description: "Create a workspace (e.g. 'workspace create acme')"},
+	{Text: "switch", Description: "Make another workspace the current one"},
+	{Text: "rename", Description: "Rename a workspace (e.g. 'workspace rename acme acme-2024')"},
+	{Text: "delete", Description: "Delete a workspace and everything in it (needs --force)"},
+	{Text: "archive", Description: "Pack a workspace into archives/ and remove it"},
+}
+
+// workspaceInfo describes a workspace for 'workspace list'.
+type workspaceInfo struct {
+	Name    string         `json:"name"`
+	Current bool           `json:"current"`
+	Assets  map[string]int `json:"assets,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// workspaceResult is what the CLI returns as data for the other subcommands.
+type workspaceResult struct {
+	Action    string `json:"action"`
+	Workspace string `json:"workspace"`
+	Previous  string `json:"previous,omitempty"`
+	Archive   string `json:"archive,omitempty"`
+}
+
+// runWorkspace manages the workspaces next to config.yaml. Switching and
+// renaming the current workspace reopen the db handle and save the new
+// workspace to config.yaml; the shell prompt follows appConfig.Workspace.
+func runWorkspace(args []string, force bool) (any, error) {
+	if err := checkWorkspaceArgs(args); err != nil {
+		return nil, err
+	}
+	action, args := args[0], args[1:]
+	switch action {
+	case "list":
+		return listWorkspaces()
+	case "create":
+		return createWorkspace(args[0])
+	case "switch":
+		return switchWorkspace(args[0])
+	case "rename":
+		return renameWorkspace(args[0], args[1])
+	case "delete":
+		return deleteWorkspace(args[0], force)
+	default:
+		return archiveWorkspace(args[0])
+	}
+}
+
+// workspaceArgs is the number of arguments of every workspace action.
+var workspaceArgs = map[string]int{"list": 0, "create": 1, "switch": 1, "rename": 2, "delete": 1, "archive": 1}
+
+func checkWorkspaceArgs(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", workspaceUsage)
+	}
+	if n, ok := workspaceArgs[args[0]]; !ok || len(args)-1 != n {
+		return fmt.Errorf("usage: %s", workspaceUsage)
+	}
+	return nil
+}
+
+// isWorkspace reports whether dir holds a workspace database.
+func isWorkspace(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, database.FileName))
+	return err == nil && !info.IsDir()
+}
+
+// isCurrentWorkspace reports whether name refers to the workspace in use.
+func isCurrentWorkspace(name string) bool {
+	return filepath.Clean(name) == filepath.Clean(appConfig.Workspace)
+}
+
+// existingWorkspace checks that name is a workspace in the working directory
+// other than the current one, so that removing it cannot reach anywhere else.
+func existingWorkspace(name string) error {
+	if !workspaceName.MatchString(name) || name == archiveDir {
+		return fmt.Errorf("invalid workspace name '%s': only workspaces in the working directory can be deleted or archived", name)
+	}
+	if !isWorkspace(name) {
+		return fmt.Errorf("no workspace '%s'", name)
+	}
+	if isCurrentWorkspace(name) {
+		return fmt.Errorf("'%s' is the current workspace; switch to another one first", name)
+	}
+	return nil
+}
+
+// newWorkspaceName checks that name can be used for a new workspace.
+func newWorkspaceName(name string) error {
+	if !workspaceName.MatchString(name) || name == archiveDir {
+		return fmt.Errorf("invalid workspace name '%s' (use letters, digits, '.', '_' and '-')", name)
+	}
+	if _, err := os.Stat(name); err == nil {
+		return fmt.Errorf("'%s' already exists", name)
+	}
+	return nil
+}
+
+// listWorkspaces finds the workspaces in the working directory, and the
+// current one wherever it is, with the number of assets in each.
+func listWorkspaces() ([]workspaceInfo, error) {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		return nil, fmt.Errorf("could not list workspaces: %w", err)
+	}
+	names := []string{filepath.Clean(appConfig.Workspace)}
+	for _, e := range entries {
+		if e.IsDir() && isWorkspace(e.Name()) && !isCurrentWorkspace(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var list []workspaceInfo
+	for _, name := range names {
+		ws := workspaceInfo{Name: name, Current: isCurrentWorkspace(name)}
+		var counts map[string]int
+		err := withWorkspaceDB(name, func(wdb *sql.DB) error {
+			var err error
+			counts, err = database.CountAssets(wdb)
+			return err
+		})
+		if err != nil {
+			ws.Error = err.Error()
+		}
+		ws.Assets = counts
+		list = append(list, ws)
+	}
+
+	for _, ws := range list {
+		marker := "  "
+		if ws.Current {
+			marker = color.GreenString("* ")
+		}
+		if ws.Error != "" {
+			fmt.Printf("%s%-24s %s\n", marker, ws.Name, color.RedString(ws.Error))
+			continue
+		}
+		var parts []string
+		for _, kind := range []string{"subdomains", "ips", "ports", "urls", "vulns", "secrets"} {
+			parts = append(parts, fmt.Sprintf("%d %s", ws.Assets[kind], kind))
+		}
+		fmt.Printf("%s%-24s %s\n", marker, ws.Name, color.WhiteString(strings.Join(parts, ", ")))
+	}
+	return list, nil
+}
+
+// withWorkspaceDB calls fn with the database of a workspace, reusing the open
+// handle for the current one.
+func withWorkspaceDB(name string, fn func(*sql.DB) error) error {
+	if isCurrentWorkspace(name) && db != nil {
+		return fn(db)
+	}
+	wdb, err := database.Open(name)
+	if err != nil {
+		return err
+	}
+	defer wdb.Close()
+	return fn(wdb)
+}
+
+func createWorkspace(name string) (*workspaceResult, error) {
+	if err := newWorkspaceName(name); err != nil {
+		return nil, err
+	}
+	wdb, err := database.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not create workspace '%s': %w", name, err)
+	}
+	wdb.Close()
+	color.Green("Workspace '%s' created. Use 'workspace switch %s' to work in it.", name, name)
+	return &workspaceResult{Action: "create", Workspace: name}, nil
+}
+
+func switchWorkspace(name string) (*workspaceResult, error) {
+	if isCurrentWorkspace(name) {
+		color.Yellow("'%s' is already the current workspace.", name)
+		return &workspaceResult{Action: "switch", Workspace: name}, nil
+	}
+	if !isWorkspace(name) {
+		return nil, fmt.Errorf("no workspace '%s' (create it with 'workspace create %s')", name, name)
+	}
+	previous := appConfig.Workspace
+	if err := useWorkspace(name); err != nil {
+		return nil, err
+	}
+	color.Green("Switched to workspace '%s'.", name)
+	return &workspaceResult{Action: "switch", Workspace: name, Previous: previous}, nil
+}
+
+// useWorkspace makes name the current workspace: it opens its database in
+// place of the current one, saves it to config.yaml and reloads the config.
+func useWorkspace(name string) error {
+	newDB, err := database.Open(name)
+	if err != nil {
+		return fmt.Errorf("could not open workspace '%s': %w", name, err)
+	}
+	appConfig.Workspace = name
+	if err := config.SaveConfig(appConfig); err != nil {
+		newDB.Close()
+		return fmt.Errorf("could not save config: %w", err)
+	}
+	if err := loadConfig(); err != nil {
+		newDB.Close()
+		return err
+	}
+	if db != nil {
+		db.Close()
+	}
+	db = newDB
+	return nil
+}
+
+func renameWorkspace(from, to string) (*workspaceResult, error) {
+	if !isWorkspace(from) {
+		return nil, fmt.Errorf("no workspace '%s'", from)
+	}
+	if err := newWorkspaceName(to); err != nil {
+		return nil, err
+	}
+	current := isCurrentWorkspace(from)
+	// The database must be closed while its directory moves.
+	if current && db != nil {
+		db.Close()
+		db = nil
+	}
+	if err := os.Rename(from, to); err != nil {
+		if current {
+			// Reopen the workspace where it still is.
+			db, _ = database.Open(from)
+		}
+		return nil, fmt.Errorf("could not rename workspace: %w", err)
+	}
+	if err := withWorkspaceDB(to, func(wdb *sql.DB) error { return database.RelocateWorkspace(wdb, from, to) }); err != nil {
+		color.Yellow("Workspace renamed, but stored file paths still point to '%s': %v", from, err)
+	}
+	if current {
+		cfg := appConfig
+		if err := useWorkspace(to); err != nil {
+			// Keep working in the workspace where it is now, even though
+			// config.yaml could not be updated or reloaded.
+			appConfig = cfg
+			appConfig.Workspace = to
+			db, _ = database.Open(to)
+			return nil, fmt.Errorf("workspace renamed to '%s', but could not switch to it: %w", to, err)
+		}
+	}
+	color.Green("Workspace '%s' renamed to '%s'.", from, to)
+	return &workspaceResult{Action: "rename", Workspace: to, Previous: from}, nil
+}
+
+func deleteWorkspace(name string, force bool) (*workspaceResult, error) {
+	if err := existingWorkspace(name); err != nil {
+		return nil, err
+	}
+	if !force {
+		return nil, fmt.Errorf("deleting '%s' removes its database, reports and every other file in it; repeat with --force to confirm (or use 'workspace archive %s')", name, name)
+	}
+	if err := os.RemoveAll(name); err != nil {
+		return nil, fmt.Errorf("could not delete workspace: %w", err)
+	}
+	color.Green("Workspace '%s' deleted.", name)
+	return &workspaceResult{Action: "delete", Workspace: name}, nil
+}
+
+// archiveWorkspace packs a workspace into archives/<name>-<time>.tar.gz and
+// removes it.
+func archiveWorkspace(name string) (*workspaceResult, error) {
+	if err := existingWorkspace(name); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create %s: %w", archiveDir, err)
+	}
+	path := filepath.Join(archiveDir, fmt.Sprintf("%s-%s.tar.gz", filepath.Base(name), time.Now().Format("20060102-150405")))
+	if err := writeArchive(path, name); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("could not archive workspace: %w", err)
+	}
+	if err := os.RemoveAll(name); err != nil {
+		return nil, fmt.Errorf("workspace archived to %s, but could not be removed: %w", path, err)
+	}
+	color.Green("Workspace '%s' archived to %s.", name, path)
+	return &workspaceResult{Action: "archive", Workspace: name, Archive: path}, nil
+}
+
+// writeArchive writes the files below dir to a gzipped tarball at path, with
+// names relative to the parent of dir.
+func writeArchive(path, dir string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	base := filepath.Dir(filepath.Clean(dir))
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(base, file)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}